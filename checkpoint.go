@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// RESUMABLE SCANS (--resume)
+// ==============================================
+// With --resume, results are written in input order, so the outputs always
+// hold exactly the results of the first N URLs. The state file records N,
+// the input offset just past URL N and the size of every output file at
+// that point. A rerun truncates the outputs back to those sizes (dropping
+// anything written after the last checkpoint), skips the input up to the
+// offset and appends from there, so no result is written twice.
+
+const checkpointVersion = 2
+
+// stdinInput names standard input in the state file
+const stdinInput = "-"
+
+type checkpointState struct {
+	Version      int              `json:"version"`
+	Input        string           `json:"input"` // -l path, or "-" for stdin
+	PatternsHash string           `json:"patterns_hash"`
+	Processed    int64            `json:"processed"` // URLs whose results are all written
+	Offset       int64            `json:"offset"`    // Input bytes covered by Processed
+	Outputs      map[string]int64 `json:"outputs"`   // Output file sizes by path
+	Stats        scanner.Stats    `json:"stats"`     // Totals over the written results
+	FailOn       string           `json:"fail_on,omitempty"`
+	MinScore     int              `json:"min_confidence,omitempty"`
+	Decode       string           `json:"decode,omitempty"`          // DecodeConfig.String()
+	Dedupe       string           `json:"dedupe,omitempty"`          // --dedupe-mode, unless none
+	Scope        string           `json:"scope,omitempty"`           // Hashes of the --scope and --exclude lists
+	PolicyHits   int              `json:"policy_findings,omitempty"` // Findings matching FailOn
+	Completed    bool             `json:"completed"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// checkpointer orders results by input position and decides when to save
+type checkpointer struct {
+	path     string
+	interval time.Duration
+	base     checkpointState // State the run started from
+	next     int64           // Next Seq (relative to this run's input) to write
+	offset   int64           // Relative input offset past the last written URL
+	pending  map[int64]scanner.Result
+	lastSave time.Time
+}
+
+// loadCheckpoint reads the state file, returning a fresh state if it does
+// not exist yet. want holds the settings of this run that decide its
+// results (input, patterns, --fail-on, --min-confidence, --decode,
+// --dedupe-mode, --scope and --exclude); a state written with others is an
+// error: resuming it would mix results.
+func loadCheckpoint(path string, interval time.Duration, want checkpointState) (*checkpointer, error) {
+	c := &checkpointer{
+		path:     path,
+		interval: interval,
+		pending:  make(map[int64]scanner.Result),
+		lastSave: time.Now(),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		c.base = want
+		c.base.Version = checkpointVersion
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading resume state: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.base); err != nil {
+		return nil, fmt.Errorf("resume state '%s' is not valid: %w", path, err)
+	}
+	switch {
+	case c.base.Version != checkpointVersion:
+		return nil, fmt.Errorf("resume state '%s' has unsupported version %d", path, c.base.Version)
+	case c.base.Input != want.Input:
+		return nil, fmt.Errorf("resume state '%s' is for input '%s', not '%s'", path, c.base.Input, want.Input)
+	case c.base.PatternsHash != want.PatternsHash:
+		return nil, fmt.Errorf("resume state '%s' was written with a different pattern set; delete it to start over", path)
+	case c.base.FailOn != want.FailOn:
+		return nil, fmt.Errorf("resume state '%s' was written with a different --fail-on/--fail-on-count policy", path)
+	case c.base.MinScore != want.MinScore:
+		return nil, fmt.Errorf("resume state '%s' was written with --min-confidence %d", path, c.base.MinScore)
+	case c.base.Decode != want.Decode:
+		return nil, fmt.Errorf("resume state '%s' was written with different --decode settings", path)
+	case c.base.Dedupe != want.Dedupe:
+		return nil, fmt.Errorf("resume state '%s' was written with a different --dedupe-mode", path)
+	case c.base.Scope != want.Scope:
+		return nil, fmt.Errorf("resume state '%s' was written with different --scope/--exclude lists", path)
+	}
+	return c, nil
+}
+
+func (c *checkpointer) resuming() bool { return c.base.Offset > 0 }
+
+// prepareOutput truncates an output file back to its checkpointed size and
+// opens it for appending. Without a checkpoint for it the file is created.
+func (c *checkpointer) prepareOutput(path string) (*os.File, error) {
+	if !c.resuming() {
+		return os.Create(path)
+	}
+	size, ok := c.base.Outputs[path]
+	if !ok {
+		return nil, fmt.Errorf("'%s' was not an output of the run being resumed", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < size {
+		return nil, fmt.Errorf("'%s' is shorter than at the last checkpoint (%d < %d bytes); it was modified", path, info.Size(), size)
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// skipInput advances input past the part processed by earlier runs. With
+// an admit function (the scanner's Admit) the skipped URLs are passed to
+// it, so their duplicates later in the input are still skipped and the
+// skip counts cover the whole input.
+func (c *checkpointer) skipInput(input io.Reader, admit func(string) bool) error {
+	if c.base.Offset == 0 {
+		return nil
+	}
+	if admit != nil {
+		skipped := io.LimitReader(input, c.base.Offset)
+		reader := bufio.NewReader(skipped)
+		read := int64(0)
+		for {
+			line, err := reader.ReadString('\n')
+			read += int64(len(line))
+			if url := strings.TrimSpace(line); url != "" && !strings.HasPrefix(url, "#") {
+				admit(url)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if read < c.base.Offset {
+			return fmt.Errorf("input ends before the checkpoint offset %d", c.base.Offset)
+		}
+		return nil
+	}
+	if seeker, ok := input.(io.Seeker); ok && c.base.Input != stdinInput {
+		_, err := seeker.Seek(c.base.Offset, io.SeekStart)
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, input, c.base.Offset); err != nil {
+		return fmt.Errorf("input ends before the checkpoint offset %d: %w", c.base.Offset, err)
+	}
+	return nil
+}
+
+// commit writes results in input order. Results that arrive early wait in
+// pending until every URL before them has been written.
+func (c *checkpointer) commit(result scanner.Result, write func(scanner.Result)) {
+	c.pending[result.Seq] = result
+	for {
+		next, ok := c.pending[c.next]
+		if !ok {
+			return
+		}
+		delete(c.pending, c.next)
+		write(next)
+		c.next++
+		c.offset = next.Offset
+	}
+}
+
+// due reports whether the periodic checkpoint should be saved now
+func (c *checkpointer) due() bool {
+	return c.interval > 0 && time.Since(c.lastSave) >= c.interval
+}
+
+// save atomically replaces the state file. outputs must already be synced.
+func (c *checkpointer) save(outputs map[string]*os.File, stats scanner.Stats, policyFindings int, completed bool) error {
+	state := c.base
+	state.Processed = c.base.Processed + c.next
+	state.Offset = c.base.Offset + c.offset
+	state.Outputs = make(map[string]int64, len(outputs))
+	for path, file := range outputs {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		state.Outputs[path] = info.Size()
+	}
+	state.Stats = stats
+	state.PolicyHits = policyFindings
+	state.Completed = completed
+	state.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.lastSave = time.Now()
+	return nil
+}
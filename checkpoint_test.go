@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cancelReader cancels a scan once more than n bytes of its input are read
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.n -= n; c.n < 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+// resumeTest holds the files of an interrupted and resumed scan
+type resumeTest struct {
+	t      *testing.T
+	dir    string
+	config Config
+}
+
+func newResumeTest(t *testing.T, urls int) *resumeTest {
+	rt := &resumeTest{t: t, dir: t.TempDir()}
+	var input strings.Builder
+	for i := 0; i < urls; i++ {
+		if i%3 == 0 {
+			fmt.Fprintf(&input, "https://a.com/%d?k=key_%06d\n", i, i)
+		} else {
+			fmt.Fprintf(&input, "https://a.com/page/%d\n", i)
+		}
+	}
+	rt.config = Config{
+		PatternsFile:       rt.write("rules.toml", "[[rule]]\nid = \"key\"\nregex = '''key_[0-9]{6}'''\n"),
+		UrlsFile:           rt.write("urls.txt", input.String()),
+		FoundUrlsLogFile:   filepath.Join(rt.dir, "found.txt"),
+		JSONLFile:          filepath.Join(rt.dir, "matches.jsonl"),
+		ResumeFile:         filepath.Join(rt.dir, "state.json"),
+		CheckpointInterval: time.Millisecond,
+		Threads:            4,
+	}
+	rt.config.Outputs = rt.config.outputSpecs(nil)
+	return rt
+}
+
+func (rt *resumeTest) write(name, content string) string {
+	path := filepath.Join(rt.dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		rt.t.Fatal(err)
+	}
+	return path
+}
+
+// scan runs the scan from the state file, cancelling it after cancelAfter
+// bytes of input (0 for never), and returns the exit code
+func (rt *resumeTest) scan(cancelAfter int) int {
+	rt.t.Helper()
+	runner, err := newRunner(rt.config)
+	if err != nil {
+		rt.t.Fatal(err)
+	}
+	defer runner.Close()
+	file, err := os.Open(rt.config.UrlsFile)
+	if err != nil {
+		rt.t.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var input io.Reader = file
+	if cancelAfter > 0 {
+		input = &cancelReader{r: file, n: cancelAfter, cancel: cancel}
+	}
+	return runner.resumeAndRun(ctx, input)
+}
+
+func (rt *resumeTest) state() checkpointState {
+	rt.t.Helper()
+	var state checkpointState
+	data, err := os.ReadFile(rt.config.ResumeFile)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		rt.t.Fatal(err)
+	}
+	return state
+}
+
+// lines returns the lines of an output file; for JSON Lines, their URLs
+func (rt *resumeTest) lines(path string) []string {
+	rt.t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		rt.t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if strings.HasSuffix(path, ".jsonl") {
+		for i, line := range lines {
+			var match struct {
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal([]byte(line), &match); err != nil {
+				rt.t.Fatalf("%s line %d: %v", path, i+1, err)
+			}
+			lines[i] = match.URL
+		}
+	}
+	return lines
+}
+
+func TestResume(t *testing.T) {
+	const urls = 30000
+	rt := newResumeTest(t, urls)
+	var want []string
+	for i := 0; i < urls; i += 3 {
+		want = append(want, fmt.Sprintf("https://a.com/%d?k=key_%06d", i, i))
+	}
+
+	if code := rt.scan(200_000); code != exitInterrupted {
+		t.Fatalf("interrupted scan: exit %d, want %d", code, exitInterrupted)
+	}
+	first := rt.state()
+	if first.Completed || first.Processed == 0 || first.Processed >= urls {
+		t.Fatalf("interrupted state: %d URLs processed, completed %v", first.Processed, first.Completed)
+	}
+
+	// A crash after the checkpoint leaves results the state does not cover
+	found, err := os.OpenFile(rt.config.FoundUrlsLogFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(found, want[len(want)-1])
+	found.Close()
+
+	if code := rt.scan(0); code != exitFindings {
+		t.Fatalf("resumed scan: exit %d, want %d", code, exitFindings)
+	}
+	if state := rt.state(); !state.Completed || state.Processed != urls || state.Stats.Findings != len(want) {
+		t.Errorf("final state: %d URLs processed, %d findings, completed %v", state.Processed, state.Stats.Findings, state.Completed)
+	}
+	for _, path := range []string{rt.config.FoundUrlsLogFile, rt.config.JSONLFile} {
+		got := rt.lines(path)
+		if strings.Join(got, "\n") == strings.Join(want, "\n") {
+			continue
+		}
+		seen := make(map[string]int)
+		for _, line := range got {
+			seen[line]++
+		}
+		for _, line := range want {
+			if seen[line] != 1 {
+				t.Errorf("%s: '%s' written %d times", filepath.Base(path), line, seen[line])
+				break
+			}
+		}
+		t.Errorf("%s: %d lines, want %d in input order", filepath.Base(path), len(got), len(want))
+	}
+
+	// A completed state exits with the code of its scan and writes nothing
+	before, _ := os.ReadFile(rt.config.JSONLFile)
+	if code := rt.scan(0); code != exitFindings {
+		t.Errorf("rerun of a completed scan: exit %d, want %d", code, exitFindings)
+	}
+	if after, _ := os.ReadFile(rt.config.JSONLFile); string(after) != string(before) {
+		t.Errorf("rerun of a completed scan changed the outputs")
+	}
+}
+
+func TestResumeMismatch(t *testing.T) {
+	rt := newResumeTest(t, 3000)
+	if code := rt.scan(20_000); code != exitInterrupted {
+		t.Fatalf("interrupted scan: exit %d, want %d", code, exitInterrupted)
+	}
+
+	for name, change := range map[string]func(*Config){
+		"different --fail-on": func(c *Config) {
+			c.Policy, _ = parseFailPolicy([]string{"severity>=high"}, 0)
+		},
+		"different --min-confidence": func(c *Config) { c.MinScore = 50 },
+		"different patterns": func(c *Config) {
+			c.PatternsFile = rt.write("other.toml", "[[rule]]\nid = \"key\"\nregex = '''key_[0-9]{8}'''\n")
+		},
+		"different input": func(c *Config) { c.UrlsFile = rt.write("other.txt", "https://a.com/\n") },
+	} {
+		config := rt.config
+		change(&config)
+		if runner, err := newRunner(config); err == nil {
+			runner.Close()
+			t.Errorf("%s: resumed, want an error", name)
+		}
+	}
+
+	// An output added since the interrupted run has no checkpointed size
+	rt.config.CSVFile = filepath.Join(rt.dir, "matches.csv")
+	rt.config.Outputs = rt.config.outputSpecs(nil)
+	if runner, err := newRunner(rt.config); err == nil {
+		runner.Close()
+		t.Errorf("new output file: resumed, want an error")
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// CRAWLER (codehunter crawl)
+// ==============================================
+// Discovers URLs starting from seed pages: links from <a>, <script>, <link>
+// and <form> tags plus any sitemaps. The crawl runs level by level so --depth
+// is the number of link hops from a seed. Pages at the last level are
+// reported but not fetched, so --depth 0 lists the seeds and sitemap URLs.
+// Sitemaps are fetched only in scope and count toward --max-pages.
+// Discovered URLs are printed, or streamed into the scanner when -r is given.
+
+type crawlConfig struct {
+	Seeds       []string
+	SeedsFile   string
+	Depth       int
+	MaxPages    int
+	Concurrency int
+	Subdomains  bool               // Also crawl subdomains of the seed hosts
+	Scope       *scanner.ScopeList // --scope, nil if unset
+	Exclude     *scanner.ScopeList // --exclude, nil if unset
+	Sitemaps    bool
+	URLsOut     string
+	Verbose     bool
+}
+
+// seedFlags collects repeatable, comma-separated -u seeds
+type seedFlags []string
+
+func (f *seedFlags) String() string { return strings.Join(*f, ", ") }
+
+func (f *seedFlags) Set(value string) error {
+	*f = append(*f, scanner.SplitList(value)...)
+	return nil
+}
+
+var (
+	crawlTagRegex   = regexp.MustCompile(`(?is)<(a|script|link|form|base)\b[^>]*>`)
+	crawlAttrRegex  = regexp.MustCompile(`(?is)\s([a-z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	sitemapLocRegex = regexp.MustCompile(`(?is)<loc>\s*(.*?)\s*</loc>`)
+)
+
+// crawlAssetExtensions are reported but never fetched: they cannot contain links
+var crawlAssetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".ico": true, ".bmp": true,
+	".svg": true, ".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".otf": true,
+	".mp3": true, ".mp4": true, ".webm": true, ".avi": true, ".mov": true,
+	".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".rar": true, ".7z": true, ".exe": true, ".dmg": true,
+	".css": true,
+}
+
+type crawler struct {
+	cfg     crawlConfig
+	fetcher *scanner.Fetcher
+	scope   []string // Seed hosts, without a leading "www."
+	emit    func(string)
+	emitMu  sync.Mutex // Serializes emit, which may block on the scanner
+
+	mu       sync.Mutex
+	seen     map[string]bool // URLs already reported
+	sitemaps map[string]bool // Sitemaps already parsed
+	pages    int             // Pages fetched, bounded by MaxPages
+	errors   int
+}
+
+func runCrawl(args []string) int {
+	config := Config{Threads: 10, ShowBanner: true}
+	var cc crawlConfig
+	var seeds seedFlags
+
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🕷️  CodeHunter v%s - crawl%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter crawl -u https://example.com [--depth 2] [--urls-out urls.txt]")
+		fmt.Fprintln(os.Stderr, "  codehunter crawl -u https://example.com -r patterns/secrets.txt --fetch --jsonl matches.jsonl")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	fs.Var(&seeds, "u", "Seed URL(s), comma-separated or repeatable")
+	fs.StringVar(&cc.SeedsFile, "l", "", "File with one seed URL per line")
+	fs.IntVar(&cc.Depth, "depth", 2, "Maximum link hops from a seed (0 = seeds and sitemaps only)")
+	fs.IntVar(&cc.MaxPages, "max-pages", 500, "Maximum number of pages and sitemaps to fetch")
+	fs.IntVar(&cc.Concurrency, "c", 5, "Number of concurrent page fetches")
+	fs.BoolVar(&cc.Subdomains, "subdomains", true, "Also follow links to subdomains of the seed hosts")
+	fs.BoolVar(&cc.Sitemaps, "sitemap", true, "Read robots.txt and sitemap.xml for extra URLs")
+	fs.StringVar(&cc.URLsOut, "urls-out", "", "File to write discovered URLs to")
+	finalize := registerScanFlags(fs, &config)
+
+	fs.Parse(args)
+
+	if err := finalize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	cc.Seeds = seeds
+	if cc.SeedsFile != "" {
+		fileSeeds, err := readSeedsFile(cc.SeedsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Cannot read seeds file: %v\n", ColorRed, ColorReset, err)
+			return exitConfigError
+		}
+		cc.Seeds = append(cc.Seeds, fileSeeds...)
+	}
+	if len(cc.Seeds) == 0 {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s At least one seed URL is required! Use -u <url> or -l <file>\n", ColorRed, ColorReset)
+		fs.Usage()
+		return exitConfigError
+	}
+	if cc.Concurrency < 1 {
+		cc.Concurrency = 1
+	}
+	cc.Verbose = config.Verbose
+	cc.Scope, cc.Exclude = config.Scope, config.Exclude
+
+	fetcher, err := scanner.NewFetcher(config.FetchConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	defer fetcher.Close()
+
+	var urlsOut *os.File
+	if cc.URLsOut != "" {
+		if urlsOut, err = os.Create(cc.URLsOut); err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Cannot create --urls-out file: %v\n", ColorRed, ColorReset, err)
+			return exitConfigError
+		}
+		defer urlsOut.Close()
+	}
+
+	// Without -r the crawl only lists URLs on stdout. With -r they are piped
+	// into the scanner, which owns stdout.
+	var runner *Runner
+	var pipeWriter *io.PipeWriter
+	var pipeReader *io.PipeReader
+	if config.PatternsFile != "" {
+		printBanner(config)
+		if runner, err = newRunner(config); err != nil {
+			fmt.Printf("%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+			return exitConfigError
+		}
+		defer runner.Close()
+		pipeReader, pipeWriter = io.Pipe()
+	}
+
+	c := newCrawler(cc, fetcher, func(u string) {
+		if urlsOut != nil {
+			fmt.Fprintln(urlsOut, u)
+		}
+		if pipeWriter != nil {
+			fmt.Fprintln(pipeWriter, u)
+		} else {
+			fmt.Println(u)
+		}
+	})
+
+	ctx, stop := interruptContext()
+	defer stop()
+	exitCode := exitOK
+	if runner == nil {
+		c.run(ctx)
+	} else {
+		crawled := make(chan struct{})
+		go func() {
+			defer close(crawled)
+			c.run(ctx)
+			pipeWriter.Close()
+		}()
+		exitCode = runner.run(ctx, pipeReader)
+		pipeReader.Close() // Unblocks the crawler if the scan stopped early
+		<-crawled
+	}
+
+	fmt.Fprintf(os.Stderr, "%s[INFO]%s Crawl finished: %d URLs discovered, %d pages fetched, %d errors\n",
+		ColorGreen, ColorReset, len(c.seen), c.pages, c.errors)
+	if cc.URLsOut != "" {
+		fmt.Fprintf(os.Stderr, "%s[INFO]%s Discovered URLs saved to: %s\n", ColorGreen, ColorReset, cc.URLsOut)
+	}
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return exitCode
+}
+
+func readSeedsFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var seeds []string
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			seeds = append(seeds, line)
+		}
+	}
+	return seeds, lines.Err()
+}
+
+func newCrawler(cfg crawlConfig, f *scanner.Fetcher, emit func(string)) *crawler {
+	c := &crawler{
+		cfg:      cfg,
+		fetcher:  f,
+		emit:     emit,
+		seen:     make(map[string]bool),
+		sitemaps: make(map[string]bool),
+	}
+	for i, seed := range cfg.Seeds {
+		if !strings.Contains(seed, "://") {
+			seed = "http://" + seed
+			cfg.Seeds[i] = seed
+		}
+		if u, err := url.Parse(seed); err == nil && u.Hostname() != "" {
+			c.scope = append(c.scope, strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+		}
+	}
+	return c
+}
+
+func (c *crawler) run(ctx context.Context) {
+	var level []string
+	for _, seed := range c.cfg.Seeds {
+		if u, ok := c.add(seed, nil); ok {
+			level = append(level, u)
+		}
+	}
+	if c.cfg.Sitemaps {
+		origins := make(map[string]bool)
+		for _, seed := range c.cfg.Seeds {
+			u, err := url.Parse(seed)
+			if err != nil || origins[u.Scheme+"://"+u.Host] {
+				continue
+			}
+			origins[u.Scheme+"://"+u.Host] = true
+			level = append(level, c.discoverSitemaps(ctx, u)...)
+		}
+	}
+
+	for hop := 0; hop < c.cfg.Depth && len(level) > 0 && ctx.Err() == nil; hop++ {
+		if c.cfg.Verbose {
+			c.logf("%s[CRAWL]%s Depth %d: %d page(s) to fetch\n", ColorCyan, ColorReset, hop, len(level))
+		}
+		level = c.crawlLevel(ctx, level)
+	}
+}
+
+// add reports a URL the first time it is seen and returns its normalized form.
+// ok is false for duplicates and URLs outside the crawl scope, --scope or --exclude.
+func (c *crawler) add(rawURL string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	u.Fragment, u.RawFragment = "", ""
+	normalized := u.String()
+	if !c.allowed(u, normalized) {
+		return "", false // Neither fetched nor reported
+	}
+
+	c.mu.Lock()
+	if c.seen[normalized] {
+		c.mu.Unlock()
+		return "", false
+	}
+	c.seen[normalized] = true
+	c.mu.Unlock()
+
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+	c.emit(normalized)
+	return normalized, true
+}
+
+// allowed reports whether u is an http(s) URL on the seed hosts and
+// passes --scope and --exclude
+func (c *crawler) allowed(u *url.URL, rawURL string) bool {
+	if (u.Scheme != "http" && u.Scheme != "https") || !c.inScope(u.Hostname()) {
+		return false
+	}
+	return (c.cfg.Scope == nil || c.cfg.Scope.Contains(rawURL)) && (c.cfg.Exclude == nil || !c.cfg.Exclude.Contains(rawURL))
+}
+
+func (c *crawler) inScope(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, base := range c.scope {
+		if host == base || (c.cfg.Subdomains && strings.HasSuffix(host, "."+base)) {
+			return true
+		}
+	}
+	return false
+}
+
+// crawlLevel fetches the pages of one level and returns the new pages found
+// on them
+func (c *crawler) crawlLevel(ctx context.Context, level []string) []string {
+	var next []string
+	var nextMu sync.Mutex
+	var wg sync.WaitGroup
+	pages := make(chan string)
+
+	for i := 0; i < c.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pages {
+				found := c.crawlPage(ctx, page)
+				nextMu.Lock()
+				next = append(next, found...)
+				nextMu.Unlock()
+			}
+		}()
+	}
+	for _, page := range level {
+		if ctx.Err() != nil {
+			break
+		}
+		if isCrawlAsset(page) || !c.reservePage() {
+			continue
+		}
+		pages <- page
+	}
+	close(pages)
+	wg.Wait()
+	return next
+}
+
+func (c *crawler) reservePage() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pages >= c.cfg.MaxPages {
+		return false
+	}
+	c.pages++
+	return true
+}
+
+// crawlPage fetches one page and returns the newly discovered URLs on it
+func (c *crawler) crawlPage(ctx context.Context, page string) []string {
+	result, err := c.fetcher.Fetch(ctx, page)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil // Interrupted, not a page error
+		}
+		c.mu.Lock()
+		c.errors++
+		c.mu.Unlock()
+		if c.cfg.Verbose {
+			c.logf("%s[CRAWL_ERROR]%s %s: %v\n", ColorRed, ColorReset, page, err)
+		}
+		return nil
+	}
+	if result.StatusCode >= 400 || !isHTMLContentType(result.ContentType) {
+		return nil
+	}
+	base, err := url.Parse(result.FinalURL)
+	if err != nil || !c.inScope(base.Hostname()) {
+		return nil // Redirected off scope
+	}
+
+	links, sitemaps := extractLinks(result.Body, base)
+	var found []string
+	for _, link := range links {
+		if u, ok := c.add(link, base); ok {
+			found = append(found, u)
+		}
+	}
+	if c.cfg.Sitemaps {
+		for _, sitemap := range sitemaps {
+			found = append(found, c.readSitemap(ctx, sitemap)...)
+		}
+	}
+	if c.cfg.Verbose {
+		c.logf("%s[CRAWL]%s %s (%d) %d new URL(s)\n", ColorBlue, ColorReset, page, result.StatusCode, len(found))
+	}
+	return found
+}
+
+// extractLinks returns the link targets of a page, resolved against its
+// <base href> if present, and any <link rel="sitemap"> targets.
+func extractLinks(body string, pageURL *url.URL) (links, sitemaps []string) {
+	base := pageURL
+	for _, tag := range crawlTagRegex.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(tag[1])
+		attrs := tagAttributes(tag[0])
+		var target string
+		switch name {
+		case "a", "link", "base":
+			target = attrs["href"]
+		case "script":
+			target = attrs["src"]
+		case "form":
+			target = attrs["action"]
+		}
+		if target == "" || isNonNavigableLink(target) {
+			continue
+		}
+		switch {
+		case name == "base":
+			if u, err := pageURL.Parse(target); err == nil {
+				base = u
+			}
+		case name == "link" && strings.Contains(strings.ToLower(attrs["rel"]), "sitemap"):
+			if u, err := base.Parse(target); err == nil {
+				sitemaps = append(sitemaps, u.String())
+			}
+		default:
+			if u, err := base.Parse(target); err == nil {
+				links = append(links, u.String())
+			}
+		}
+	}
+	return links, sitemaps
+}
+
+func tagAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range crawlAttrRegex.FindAllStringSubmatch(tag, -1) {
+		name := strings.ToLower(m[1])
+		if _, dup := attrs[name]; !dup {
+			attrs[name] = html.UnescapeString(m[2] + m[3] + m[4])
+		}
+	}
+	return attrs
+}
+
+func isNonNavigableLink(target string) bool {
+	lower := strings.ToLower(strings.TrimSpace(target))
+	for _, prefix := range []string{"#", "javascript:", "mailto:", "tel:", "data:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTMLContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func isCrawlAsset(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return crawlAssetExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// ==============================================
+// SITEMAPS
+// ==============================================
+
+// discoverSitemaps reads the sitemaps listed in robots.txt, falling back to
+// /sitemap.xml, and returns the new pages they list.
+func (c *crawler) discoverSitemaps(ctx context.Context, origin *url.URL) []string {
+	root := &url.URL{Scheme: origin.Scheme, Host: origin.Host}
+	var sitemaps []string
+	if result, err := c.fetcher.Fetch(ctx, root.JoinPath("robots.txt").String()); err == nil && result.StatusCode < 400 {
+		for _, line := range strings.Split(result.Body, "\n") {
+			key, value, found := strings.Cut(line, ":")
+			if found && strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+				sitemaps = append(sitemaps, strings.TrimSpace(value))
+			}
+		}
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = append(sitemaps, root.JoinPath("sitemap.xml").String())
+	}
+
+	var found []string
+	for _, sitemap := range sitemaps {
+		found = append(found, c.readSitemap(ctx, sitemap)...)
+	}
+	return found
+}
+
+// readSitemap parses a sitemap or sitemap index, following nested sitemaps.
+// Like pages, sitemaps are only fetched in scope and within --max-pages.
+func (c *crawler) readSitemap(ctx context.Context, sitemapURL string) []string {
+	u, err := url.Parse(strings.TrimSpace(sitemapURL))
+	if err != nil || !c.allowed(u, u.String()) {
+		return nil
+	}
+	sitemapURL = u.String()
+	c.mu.Lock()
+	if c.sitemaps[sitemapURL] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.sitemaps[sitemapURL] = true
+	c.mu.Unlock()
+	if !c.reservePage() {
+		return nil
+	}
+
+	result, err := c.fetcher.Fetch(ctx, sitemapURL)
+	if err != nil || result.StatusCode >= 400 {
+		return nil
+	}
+	isIndex := strings.Contains(result.Body, "<sitemapindex")
+	var found []string
+	for _, m := range sitemapLocRegex.FindAllStringSubmatch(result.Body, -1) {
+		loc := html.UnescapeString(m[1])
+		if isIndex {
+			found = append(found, c.readSitemap(ctx, loc)...)
+		} else if u, ok := c.add(loc, nil); ok {
+			found = append(found, u)
+		}
+	}
+	if c.cfg.Verbose {
+		c.logf("%s[SITEMAP]%s %s: %d new URL(s)\n", ColorCyan, ColorReset, sitemapURL, len(found))
+	}
+	return found
+}
+
+// logf writes crawl progress to stderr so stdout stays a clean URL list
+func (c *crawler) logf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
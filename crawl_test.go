@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// crawlSite serves a small site and records the paths fetched from it. Seeds
+// use the localhost name, so links to 127.0.0.1 are off the seed host.
+type crawlSite struct {
+	*httptest.Server
+	origin string // http://localhost:port
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+func newCrawlSite(t *testing.T) *crawlSite {
+	site := &crawlSite{}
+	pages := map[string]string{
+		"/":                    `<a href="/a">a</a> <a href="/b#top">b</a> <script src="/app.js"></script> <a href="/private/x">x</a> <a href="OFFSITE/other">o</a>`,
+		"/a":                   `<a href="/a/deep">deep</a>`,
+		"/a/deep":              `<a href="/a/deeper">deeper</a>`,
+		"/robots.txt":          "User-agent: *\nSitemap: ORIGIN/sitemap.xml\nSitemap: ORIGIN/private/sitemap.xml\nSitemap: OFFSITE/sitemap.xml\n",
+		"/sitemap.xml":         `<urlset><url><loc>ORIGIN/from-sitemap</loc></url></urlset>`,
+		"/private/sitemap.xml": `<urlset><url><loc>ORIGIN/private-sitemap</loc></url></urlset>`,
+	}
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site.mu.Lock()
+		site.fetched = append(site.fetched, r.Host[:strings.Index(r.Host, ":")]+r.URL.Path)
+		site.mu.Unlock()
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		page = strings.ReplaceAll(page, "ORIGIN", site.origin)
+		fmt.Fprint(w, strings.ReplaceAll(page, "OFFSITE", site.URL))
+	}))
+	t.Cleanup(site.Close)
+	site.origin = strings.Replace(site.URL, "127.0.0.1", "localhost", 1)
+	return site
+}
+
+// crawl runs a crawl from the site root and returns the reported paths and
+// the fetched host+paths, both sorted
+func (site *crawlSite) crawl(t *testing.T, cc crawlConfig) (reported, fetched []string) {
+	t.Helper()
+	fetcher, err := scanner.NewFetcher(scanner.FetchConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fetcher.Close()
+	site.mu.Lock()
+	site.fetched = nil
+	site.mu.Unlock()
+
+	cc.Seeds = []string{site.origin + "/"}
+	if cc.Concurrency == 0 {
+		cc.Concurrency = 2
+	}
+	if cc.MaxPages == 0 {
+		cc.MaxPages = 100
+	}
+	newCrawler(cc, fetcher, func(u string) {
+		reported = append(reported, strings.TrimPrefix(u, site.origin))
+	}).run(context.Background())
+
+	sort.Strings(reported)
+	site.mu.Lock()
+	fetched = append(fetched, site.fetched...)
+	site.mu.Unlock()
+	sort.Strings(fetched)
+	return reported, fetched
+}
+
+func TestCrawlDepth(t *testing.T) {
+	site := newCrawlSite(t)
+	for depth, want := range map[int]struct{ reported, fetched string }{
+		0: {"/", ""},
+		1: {"/ /a /app.js /b /private/x", "localhost/"},
+		2: {"/ /a /a/deep /app.js /b /private/x", "localhost/ localhost/a localhost/app.js localhost/b localhost/private/x"},
+	} {
+		reported, fetched := site.crawl(t, crawlConfig{Depth: depth})
+		if strings.Join(reported, " ") != want.reported {
+			t.Errorf("depth %d: reported %v, want %s", depth, reported, want.reported)
+		}
+		if strings.Join(fetched, " ") != want.fetched {
+			t.Errorf("depth %d: fetched %v, want %s", depth, fetched, want.fetched)
+		}
+	}
+}
+
+func TestCrawlSitemapScope(t *testing.T) {
+	site := newCrawlSite(t)
+	exclude, err := scanner.ParseScope(strings.NewReader("/private\n"), "exclude.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported, fetched := site.crawl(t, crawlConfig{Depth: 0, Sitemaps: true, Exclude: exclude})
+	if strings.Join(reported, " ") != "/ /from-sitemap" {
+		t.Errorf("reported %v, want / /from-sitemap", reported)
+	}
+	if strings.Join(fetched, " ") != "localhost/robots.txt localhost/sitemap.xml" {
+		t.Errorf("fetched %v: sitemaps off the seed host or excluded were fetched", fetched)
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	site := newCrawlSite(t)
+	_, fetched := site.crawl(t, crawlConfig{Depth: 3, MaxPages: 3, Sitemaps: true})
+	// robots.txt is not a page; the two sitemaps leave room for the seed only
+	if strings.Join(fetched, " ") != "localhost/ localhost/private/sitemap.xml localhost/robots.txt localhost/sitemap.xml" {
+		t.Errorf("fetched %v with --max-pages 3", fetched)
+	}
+}
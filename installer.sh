@@ -154,7 +154,7 @@ build_app() {
     # Construir la aplicación Go
     # Usar CGO_ENABLED=0 para un binario estático si no hay dependencias C
     # GOOS y GOARCH se pueden establecer aquí para cross-compilation si es necesario
-    if CGO_ENABLED=0 go build -ldflags="-s -w" -o "${APP_NAME}" main.go; then #
+    if CGO_ENABLED=0 go build -ldflags="-s -w" -o "${APP_NAME}" .; then #
         success "${APP_NAME} binary built successfully."
     else
         die "Failed to build ${APP_NAME}."
@@ -1,17 +1,21 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"regexp"
+	"os/signal"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/output"
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
 )
 
 // ==============================================
@@ -49,50 +53,63 @@ const BANNER = `
 // CONFIGURATION STRUCTURE
 // ==============================================
 type Config struct {
-	PatternsFile     string
-	UrlsFile         string
-	OutputFile       string
-	Threads          int
-	Verbose          bool
-	ShowBanner       bool
-	LogFile          string // For detailed, one-line-per-match-detail logs
-	FoundUrlsLogFile string // For clean list of unique matched URLs
-}
-
-// ==============================================
-// PATTERN INFO STRUCTURE
-// ==============================================
-type PatternInfo struct {
-	RegexStr   string
-	Compiled   *regexp.Regexp
-	SourceFile string
-}
-
-// Structure to hold detailed match results for logging
-type MatchDetail struct {
-	Pattern     PatternInfo
-	Occurrences []string
+	PatternsFile       string
+	UrlsFile           string
+	OutputFile         string
+	Threads            int
+	Verbose            bool
+	ShowBanner         bool
+	LogFile            string        // For detailed, one-line-per-match-detail logs
+	FoundUrlsLogFile   string        // For clean list of unique matched URLs
+	JSONLFile          string        // For machine-readable JSON Lines, one object per match detail
+	SARIFFile          string        // SARIF 2.1.0 log for code-scanning dashboards
+	HTMLFile           string        // Self-contained HTML report
+	CSVFile            string        // One CSV row per match detail, for spreadsheets
+	MarkdownFile       string        // Findings grouped by host and category, for bug reports
+	Outputs            []output.Spec // All sinks: the file flags above, then --output
+	ResumeFile         string        // State file for resumable scans
+	CheckpointInterval time.Duration
+	MinSeverity        scanner.Severity
+	MinScore           int                  // --min-confidence: drop occurrences scoring lower
+	Decode             scanner.DecodeConfig // --decode/--decode-depth: also match decoded views of each URL
+	Dedupe             scanner.DedupeConfig // --dedupe-*: skip duplicate input URLs and list each matched URL once
+	Scope              *scanner.ScopeList   // --scope: skip input URLs it does not contain, nil if unset
+	Exclude            *scanner.ScopeList   // --exclude: skip input URLs it contains, nil if unset
+	Categories         []string
+	Tags               []string
+	Fetch              bool // GET each URL and scan response headers and body
+	FetchConfig        scanner.FetchConfig
+	Policy             *failPolicy // --fail-on/--fail-on-count, nil if unset
+	ProfileFile        string      // --profile-rules: per-rule cost and hit report
 }
 
 // ==============================================
-// SCANNER STRUCTURE
+// RUNNER STRUCTURE
 // ==============================================
-type Scanner struct {
-	Config        Config
-	Patterns      []PatternInfo
-	Stats         ScanStats
-	mu            sync.Mutex
-	logFileMutex  sync.Mutex
-	foundFile     *os.File
-	logDetailFile *os.File // This will now be the structured, one-line-per-match log
+// The Runner drives a scanner.Scanner and writes its results to the output
+// sinks (stdout included).
+type Runner struct {
+	Config      Config
+	Rules       *scanner.RuleSet
+	Stats       ScanStats
+	scanner     *scanner.Scanner
+	checkpoint  *checkpointer       // nil without --resume
+	dedupe      *scanner.URLDeduper // nil with --dedupe-mode none
+	outputMutex sync.Mutex          // Guards the outputs below, written from onResult
+	outputs     []runnerOutput
+	files       map[string]*os.File // Output files by path, synced for checkpoints
+	profileFile *os.File            // --profile-rules, nil if unset
 }
 
 type ScanStats struct {
-	URLsProcessed int
-	URLsMatched   int
-	PatternsCount int
-	StartTime     time.Time
-	EndTime       time.Time
+	scanner.Stats
+	PatternsCount  int
+	Resumed        int  // URLs processed by earlier runs (--resume), included in the totals
+	PolicyFindings int  // Findings matching --fail-on
+	Errors         int  // Input, output and checkpoint errors
+	Interrupted    bool // Stopped by SIGINT/SIGTERM before the end of the input
+	StartTime      time.Time
+	EndTime        time.Time
 }
 
 // ==============================================
@@ -109,12 +126,91 @@ const (
 	ColorBold   = "\033[1m"
 )
 
+// ==============================================
+// EXIT CODES
+// ==============================================
+// With --fail-on or --fail-on-count, exitFindings means the policy failed
+// and findings below it exit with exitOK.
+const (
+	exitOK           = 0 // No findings
+	exitFindings     = 1
+	exitConfigError  = 2   // Bad flags, patterns, input or output paths (as the flag package uses)
+	exitRuntimeError = 3   // Reading input or writing results failed mid-scan
+	exitInterrupted  = 130 // 128 + SIGINT, as shells report a Ctrl-C
+)
+
 // ==============================================
 // MAIN FUNCTION
 // ==============================================
 func main() {
-	config := parseFlags()
+	if len(os.Args) > 1 && os.Args[1] == "crawl" {
+		os.Exit(runCrawl(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "patterns" {
+		os.Exit(runPatterns(os.Args[2:]))
+	}
+
+	os.Exit(runScan(parseFlags()))
+}
+
+// runScan scans the URLs from -l or stdin and returns the exit code. An
+// interrupt stops the scan early; the outputs are still flushed and closed.
+func runScan(config Config) int {
+	printBanner(config)
+
+	runner, err := newRunner(config)
+	if err != nil {
+		fmt.Printf("%s[ERROR]%s %v\n", ColorRed, ColorReset, err) // To stdout
+		return exitConfigError
+	}
+	defer runner.Close()
+
+	var input io.Reader
+	if config.UrlsFile != "" {
+		file, err := os.Open(config.UrlsFile)
+		if err != nil {
+			runner.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Cannot open URLs file: %v\n", ColorRed, ColorReset, err), true)
+			return exitConfigError
+		}
+		defer file.Close()
+		input = file
+		if config.Verbose {
+			runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Reading URLs from: %s\n", ColorCyan, ColorReset, config.UrlsFile), true)
+		}
+	} else {
+		input = os.Stdin
+		if config.Verbose {
+			runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Reading URLs from stdin (pipe mode)\n", ColorCyan, ColorReset), true)
+		}
+	}
 
+	ctx, stop := interruptContext()
+	defer stop()
+	return runner.resumeAndRun(ctx, input)
+}
+
+// resumeAndRun skips the input processed by the run being resumed, if any,
+// then scans the rest and returns the exit code
+func (s *Runner) resumeAndRun(ctx context.Context, input io.Reader) int {
+	if checkpoint := s.checkpoint; checkpoint != nil {
+		if checkpoint.base.Completed {
+			fmt.Printf("%s[INFO]%s '%s' records a completed scan of %d URLs. Delete it to scan again.\n",
+				ColorGreen, ColorReset, s.Config.ResumeFile, checkpoint.base.Processed)
+			return s.exitCode()
+		}
+		if err := checkpoint.skipInput(input, s.admitFunc()); err != nil {
+			s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Resuming '%s': %v\n", ColorRed, ColorReset, s.Config.ResumeFile, err), true)
+			return exitConfigError
+		}
+		if checkpoint.resuming() {
+			s.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Resuming from '%s': skipping %d URLs already processed (%d bytes)\n",
+				ColorCyan, ColorReset, s.Config.ResumeFile, checkpoint.base.Processed, checkpoint.base.Offset), true)
+		}
+	}
+	return s.run(ctx, input)
+}
+
+func printBanner(config Config) {
 	// Initial banner and startup messages to stdout
 	if config.ShowBanner {
 		fmt.Println(BANNER)
@@ -123,94 +219,243 @@ func main() {
 		fmt.Printf("%s📅 Build: %s | 🐹 Go: %s | 💻 OS: %s%s\n\n",
 			ColorBlue, BUILD_DATE, runtime.Version(), runtime.GOOS, ColorReset)
 	}
+}
 
-	scanner := &Scanner{
+// newRunner opens the output files, loads the patterns and builds the
+// scanner (and its HTTP client in fetch mode). Call Close when done.
+func newRunner(config Config) (*Runner, error) {
+	runner := &Runner{
 		Config: config,
 		Stats: ScanStats{
 			StartTime: time.Now(),
 		},
 	}
-	defer scanner.CloseFiles()
 
-	if err := scanner.setupOutputFiles(); err != nil {
-		fmt.Printf("%s[ERROR]%s Setting up output files: %v\n", ColorRed, ColorReset, err) // To stdout
-		os.Exit(1)
+	// Pattern loading messages will use logGeneralMessage (which might write to logDetailFile or stdout)
+	if err := runner.loadPatterns(); err != nil {
+		return nil, fmt.Errorf("failed to load patterns: %w", err)
 	}
 
-	// Pattern loading messages will use logGeneralMessage (which might write to logDetailFile or stdout)
-	if err := scanner.loadPatterns(); err != nil {
-		scanner.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Failed to load patterns: %v\n", ColorRed, ColorReset, err), true)
-		os.Exit(1)
+	if config.ResumeFile != "" {
+		input := config.UrlsFile
+		if input == "" {
+			input = stdinInput
+		}
+		dedupe := ""
+		if config.Dedupe.Mode != scanner.DedupeNone {
+			dedupe = string(config.Dedupe.Mode)
+		}
+		scope := ""
+		if config.Scope != nil {
+			scope = "scope " + config.Scope.Hash()
+		}
+		if config.Exclude != nil {
+			scope += " exclude " + config.Exclude.Hash()
+		}
+		checkpoint, err := loadCheckpoint(config.ResumeFile, config.CheckpointInterval, checkpointState{
+			Input:        input,
+			PatternsHash: runner.Rules.Hash(),
+			FailOn:       config.Policy.key(),
+			MinScore:     config.MinScore,
+			Decode:       config.Decode.String(),
+			Dedupe:       dedupe,
+			Scope:        scope,
+		})
+		if err != nil {
+			return nil, err
+		}
+		runner.checkpoint = checkpoint
+		runner.Stats.Stats = checkpoint.base.Stats
+		runner.Stats.PolicyFindings = checkpoint.base.PolicyHits
+		runner.Stats.Resumed = int(checkpoint.base.Processed)
+	}
+
+	if err := runner.setupOutputs(); err != nil {
+		return nil, fmt.Errorf("setting up outputs: %w", err)
+	}
+	if config.ProfileFile != "" {
+		var err error
+		if runner.profileFile, err = os.Create(config.ProfileFile); err != nil {
+			runner.CloseFiles()
+			return nil, fmt.Errorf("--profile-rules: %w", err)
+		}
 	}
 
 	if config.Verbose {
-		scanner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Loaded %d patterns. Initial source: %s\n",
-			ColorCyan, ColorReset, scanner.Stats.PatternsCount, config.PatternsFile), true)
+		runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Loaded %d patterns. Initial source: %s\n",
+			ColorCyan, ColorReset, runner.Stats.PatternsCount, config.PatternsFile), true)
 	}
 
-	var input io.Reader
-	if config.UrlsFile != "" {
-		file, err := os.Open(config.UrlsFile)
-		if err != nil {
-			scanner.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Cannot open URLs file: %v\n", ColorRed, ColorReset, err), true)
-			os.Exit(1)
-		}
-		defer file.Close()
-		input = file
-		if config.Verbose {
-			scanner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Reading URLs from: %s\n", ColorCyan, ColorReset, config.UrlsFile), true)
-		}
-	} else {
-		input = os.Stdin
-		if config.Verbose {
-			scanner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Reading URLs from stdin (pipe mode)\n", ColorCyan, ColorReset), true)
+	var err error
+	if runner.dedupe, err = scanner.NewURLDeduper(config.Dedupe); err != nil {
+		runner.CloseFiles()
+		return nil, fmt.Errorf("--dedupe-store: %w", err)
+	}
+	runner.scanner, err = scanner.New(scanner.Options{
+		Rules:        runner.Rules,
+		Workers:      config.Threads,
+		Fetch:        config.Fetch,
+		FetchConfig:  config.FetchConfig,
+		MinScore:     config.MinScore,
+		Decode:       config.Decode,
+		Scope:        config.Scope,
+		Exclude:      config.Exclude,
+		Dedupe:       runner.dedupe,
+		ProfileRules: config.ProfileFile != "",
+		OnResult:     runner.onResult,
+	})
+	if err != nil {
+		runner.CloseFiles()
+		runner.closeDedupe()
+		return nil, err
+	}
+	if config.Fetch && config.Verbose {
+		runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Fetch mode: scanning response headers and bodies (timeout %s, max body %d bytes)\n",
+			ColorCyan, ColorReset, config.FetchConfig.Timeout, config.FetchConfig.MaxBodySize), true)
+	}
+	for _, list := range []*scanner.ScopeList{config.Scope, config.Exclude} {
+		if list != nil && config.Verbose {
+			kind := "Scope"
+			if list == config.Exclude {
+				kind = "Exclude"
+			}
+			runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s %s: %d entries from '%s'\n",
+				ColorCyan, ColorReset, kind, list.Len(), list.Name), true)
 		}
 	}
+	if decode := config.Decode.String(); decode != "" && config.Verbose {
+		runner.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Decoding: also matching %s views of each URL\n",
+			ColorCyan, ColorReset, decode), true)
+	}
+	return runner, nil
+}
 
-	scanner.scan(input)
+// run scans every URL from input, prints the final summary and returns the
+// exit code
+func (s *Runner) run(ctx context.Context, input io.Reader) int {
+	s.scan(ctx, input)
+	s.closeOutputs()
+	s.writeRuleProfile()
 	// showFinalStats will now only print to stdout if banner/verbose, not to logDetailFile
-	scanner.showFinalStats()
+	s.showFinalStats()
 
 	// Final messages about where files were saved (to stdout)
-	if scanner.Config.FoundUrlsLogFile != "" {
-		fmt.Printf("%s[INFO]%s Matched URLs saved to: %s\n", ColorGreen, ColorReset, scanner.Config.FoundUrlsLogFile)
+	s.showSavedOutputs()
+	if s.Stats.Interrupted {
+		fmt.Fprintf(os.Stderr, "%s[INTERRUPTED]%s Partial results: %d URLs processed, %d matched, %d findings (%s)\n",
+			ColorYellow, ColorReset, s.Stats.URLsProcessed, s.Stats.URLsMatched, s.Stats.Findings, s.severitySummary())
+	}
+	if policy := s.Config.Policy; policy != nil && !s.Stats.Interrupted {
+		if s.policyFailed() {
+			fmt.Fprintf(os.Stderr, "%s[POLICY]%s Failed: %d findings match %s\n",
+				ColorRed, ColorReset, s.Stats.PolicyFindings, policy)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s[POLICY]%s Passed: %d findings match %s\n",
+				ColorGreen, ColorReset, s.Stats.PolicyFindings, policy)
+		}
+	}
+	return s.exitCode()
+}
+
+// exitCode maps the outcome of the scan to one of the exit codes above. An
+// interrupted scan is incomplete, so its findings decide nothing.
+func (s *Runner) exitCode() int {
+	switch {
+	case s.Stats.Interrupted:
+		return exitInterrupted
+	case s.Stats.Errors > 0:
+		return exitRuntimeError
+	case s.policyFailed():
+		return exitFindings
+	}
+	return exitOK
+}
+
+// policyFailed reports whether the findings fail the run: any finding at all
+// without a policy, else enough findings matching --fail-on
+func (s *Runner) policyFailed() bool {
+	if s.Config.Policy == nil {
+		return s.Stats.Findings > 0
 	}
-	if scanner.Config.LogFile != "" {
-		fmt.Printf("%s[INFO]%s Detailed match log saved to: %s\n", ColorGreen, ColorReset, scanner.Config.LogFile)
+	return s.Stats.PolicyFindings >= s.Config.Policy.minCount
+}
+
+// interruptContext is cancelled by the first SIGINT or SIGTERM. The signal
+// handler is then removed, so a second Ctrl-C kills the process at once.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			fmt.Fprintf(os.Stderr, "\n%s[INTERRUPTED]%s Finishing in-flight URLs and flushing outputs (Ctrl-C again to abort)\n", ColorYellow, ColorReset)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
 	}
 }
 
 // ==============================================
 // HELPER FUNCTIONS
 // ==============================================
-func (s *Scanner) setupOutputFiles() error {
-	if s.Config.FoundUrlsLogFile != "" {
-		file, err := os.Create(s.Config.FoundUrlsLogFile)
-		if err != nil {
-			return fmt.Errorf("creating found URLs file '%s': %w", s.Config.FoundUrlsLogFile, err)
+// saveCheckpoint syncs the output files and records them in the --resume
+// state. Call with outputMutex held.
+func (s *Runner) saveCheckpoint(completed bool) {
+	files := s.files
+	for _, file := range files {
+		if err := file.Sync(); err != nil {
+			s.Stats.Errors++
+			s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Syncing '%s': %v\n", ColorRed, ColorReset, file.Name(), err), true)
+			return
 		}
-		s.foundFile = file
 	}
+	if err := s.checkpoint.save(files, s.Stats.Stats, s.Stats.PolicyFindings, completed); err != nil {
+		s.Stats.Errors++
+		s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Saving resume state '%s': %v\n", ColorRed, ColorReset, s.Config.ResumeFile, err), true)
+	}
+}
 
-	if s.Config.LogFile != "" {
-		file, err := os.Create(s.Config.LogFile)
-		if err != nil {
-			if s.foundFile != nil {
-				s.foundFile.Close() // Clean up if partially successful
-			}
-			return fmt.Errorf("creating detailed match log file '%s': %w", s.Config.LogFile, err)
-		}
-		s.logDetailFile = file // This is for the structured one-line-per-match log
+// Close releases the output files, the HTTP client and the dedupe store
+func (s *Runner) Close() {
+	s.CloseFiles()
+	s.scanner.Close()
+	s.closeDedupe()
+}
+
+// admitFunc returns the scanner's Admit when input URLs may be skipped
+// (--scope, --exclude, --dedupe-mode), nil otherwise
+func (s *Runner) admitFunc() func(string) bool {
+	if s.Config.Scope == nil && s.Config.Exclude == nil && s.dedupe == nil {
+		return nil
+	}
+	return s.scanner.Admit
+}
+
+// closeDedupe releases the dedupe store. A store error means duplicates
+// may have been scanned again, which is reported but changes no result.
+func (s *Runner) closeDedupe() {
+	if s.dedupe == nil {
+		return
+	}
+	if err := s.dedupe.Close(); err != nil {
+		s.logGeneralMessage(fmt.Sprintf("%s[WARN]%s Dedupe store: %v\n", ColorYellow, ColorReset, err), true)
 	}
-	return nil
 }
 
-func (s *Scanner) CloseFiles() {
-	if s.foundFile != nil {
-		s.foundFile.Close()
+// CloseFiles closes the output files without finishing their sinks, for
+// runs that end before the scan starts. Closing twice is harmless.
+func (s *Runner) CloseFiles() {
+	for _, file := range s.files {
+		file.Close()
 	}
-	if s.logDetailFile != nil {
-		s.logDetailFile.Close()
+	if s.profileFile != nil {
+		s.profileFile.Close()
 	}
 }
 
@@ -218,7 +463,7 @@ func (s *Scanner) CloseFiles() {
 // It writes to s.logDetailFile IF it's meant to be a general log (not the case anymore)
 // OR to stdout under certain conditions.
 // For v2.5.7, s.logDetailFile is a structured match log, so general messages primarily go to stdout.
-func (s *Scanner) logGeneralMessage(message string, forceToStdout bool) {
+func (s *Runner) logGeneralMessage(message string, forceToStdout bool) {
 	// In this version, s.logDetailFile is for specific match structures.
 	// General messages (errors, verbose progress, pattern loading) should primarily go to stdout.
 	// However, if a user *only* specifies --log-file and expects everything there, this might need adjustment.
@@ -252,6 +497,7 @@ func parseFlags() Config {
 		fmt.Printf("%s💡 Test Examples (logs matched pattern & occurrences to --log-file):%s\n", ColorYellow, ColorReset)
 		fmt.Println("  echo \"https://example.com/api/v1/users\" | codehunter -r api_endpoints.txt --found-urls hits.txt --log-file detailed_matches.log")
 		fmt.Println("  echo \"http://test.com/api/key1?api_key=ABC&api_key=DEF\" | codehunter -r secrets.txt -v --log-file detailed_matches_verbose.log")
+		fmt.Println("  cat urls.txt | codehunter -r secrets.txt -b=false --jsonl matches.jsonl && jq -r .url matches.jsonl")
 		fmt.Println()
 
 		fmt.Printf("%s💡 Full Workflow Example with Katana:%s\n", ColorYellow, ColorReset)
@@ -265,230 +511,313 @@ func parseFlags() Config {
 		fmt.Printf("%s📋 Available Patterns (default location: patterns/ or /usr/share/codehunter/patterns/):%s\n", ColorYellow, ColorReset)
 		fmt.Println("  • secrets.txt      - API keys, tokens, credentials")
 		fmt.Println("  • api_endpoints.txt - REST APIs, GraphQL, endpoints")
+		fmt.Println("  • high_confidence.toml - Vendor token formats with severity/confidence metadata")
 		// ... (other patterns)
 		fmt.Println()
 		fmt.Printf("%s🏴‍☠️ Happy Bug Hunting! 🏴‍☠️%s\n", ColorBold, ColorReset)
 	}
 
-	flag.StringVar(&config.PatternsFile, "r", "", "Patterns file(s), comma-separated (required)")
 	flag.StringVar(&config.UrlsFile, "l", "", "URLs file (optional, uses stdin if not provided)")
-	flag.StringVar(&config.OutputFile, "o", "", "Output file for matched URLs (legacy, use --found-urls for clarity)")
-	flag.IntVar(&config.Threads, "t", 10, "Number of threads")
-	flag.BoolVar(&config.Verbose, "v", false, "Verbose output (logs progress to stdout)")
-	flag.BoolVar(&config.ShowBanner, "b", true, "Show banner (default: true, set to false with -b=false)")
-	flag.StringVar(&config.LogFile, "log-file", "", "File to write detailed one-line-per-match log (URL, Pattern, Occurrences)")
-	flag.StringVar(&config.FoundUrlsLogFile, "found-urls", "", "File to write clean list of unique matched URLs (optional)")
+	flag.StringVar(&config.ResumeFile, "resume", "", "State file to checkpoint progress to, and to resume from if it exists")
+	flag.DurationVar(&config.CheckpointInterval, "checkpoint-interval", 10*time.Second, "How often to save the --resume state")
+	finalize := registerScanFlags(flag.CommandLine, &config)
 
 	flag.Parse()
 
+	if err := finalize(); err != nil {
+		fmt.Printf("%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		os.Exit(exitConfigError)
+	}
 	if config.PatternsFile == "" {
 		fmt.Printf("%s[ERROR]%s Patterns file is required! Use -r <patterns_file>%s\n", ColorRed, ColorReset, ColorReset)
 		fmt.Println()
 		flag.Usage()
-		os.Exit(1)
-	}
-	if config.OutputFile != "" && config.FoundUrlsLogFile == "" {
-		config.FoundUrlsLogFile = config.OutputFile
+		os.Exit(exitConfigError)
 	}
 	return config
 }
 
-// ==============================================
-// PATTERN LOADING
-// ==============================================
-func (s *Scanner) loadPatterns() error {
-	patternFileSources := strings.Split(s.Config.PatternsFile, ",")
-	var loadedPatterns []PatternInfo
-	var filesSuccessfullyProcessed int
-	var patternLoadingLog strings.Builder
-
-	for _, sourceName := range patternFileSources {
-		sourceName = strings.TrimSpace(sourceName)
-		if sourceName == "" {
-			continue
-		}
-		possiblePaths := []string{sourceName, filepath.Join("patterns", sourceName), filepath.Join("/usr/share/codehunter/patterns", sourceName)}
-		var file *os.File
+// registerScanFlags adds the pattern, output and fetch flags shared by the
+// scanner and the crawl subcommand. The returned function validates them
+// and must be called after parsing.
+func registerScanFlags(fs *flag.FlagSet, config *Config) func() error {
+	fs.StringVar(&config.PatternsFile, "r", "", "Patterns file(s) or installed packs (name[@version]), comma-separated (required)")
+	fs.StringVar(&config.OutputFile, "o", "", "Output file for matched URLs (legacy, use --found-urls for clarity)")
+	fs.IntVar(&config.Threads, "t", 10, "Number of threads")
+	fs.BoolVar(&config.Verbose, "v", false, "Verbose output (logs progress to stdout)")
+	fs.BoolVar(&config.ShowBanner, "b", true, "Show banner (default: true, set to false with -b=false)")
+	fs.StringVar(&config.LogFile, "log-file", "", "File to write detailed one-line-per-match log (URL, Pattern, Occurrences)")
+	fs.StringVar(&config.FoundUrlsLogFile, "found-urls", "", "File to write clean list of unique matched URLs (optional)")
+	fs.BoolVar(&config.Fetch, "fetch", false, "Fetch each URL and also scan response headers and body")
+	fs.DurationVar(&config.FetchConfig.Timeout, "timeout", 10*time.Second, "HTTP timeout per request (--fetch)")
+	fs.Var((*headerFlags)(&config.FetchConfig.Headers), "H", "Extra request header 'Name: value', repeatable (--fetch)")
+	fs.StringVar(&config.FetchConfig.Proxy, "proxy", "", "HTTP/SOCKS5 proxy URL, e.g. http://127.0.0.1:8080 (--fetch)")
+	fs.Int64Var(&config.FetchConfig.MaxBodySize, "max-body", scanner.DefaultMaxBodySize, "Maximum response body bytes to scan (--fetch)")
+	fs.BoolVar(&config.FetchConfig.Insecure, "insecure", false, "Skip TLS certificate verification (--fetch)")
+	fs.StringVar(&config.FetchConfig.TLSMinVersion, "tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3 (--fetch)")
+	fs.Float64Var(&config.FetchConfig.RateLimit, "rate-limit", 0, "Maximum requests per second across all threads, 0 = unlimited (--fetch)")
+	fs.StringVar(&config.FetchConfig.UserAgent, "user-agent", "CodeHunter/"+VERSION, "User-Agent header (--fetch)")
+	fs.StringVar(&config.JSONLFile, "jsonl", "", "File to write one JSON object per match detail (JSON Lines, for jq/ELK pipelines)")
+	fs.StringVar(&config.SARIFFile, "sarif", "", "File to write a SARIF 2.1.0 report to (for code-scanning dashboards)")
+	fs.StringVar(&config.HTMLFile, "html", "", "File to write a self-contained HTML report to")
+	fs.StringVar(&config.CSVFile, "csv", "", "File to write one CSV row per match detail (for spreadsheets)")
+	fs.StringVar(&config.MarkdownFile, "markdown", "", "File to write a Markdown report grouped by host and category")
+	var extraOutputs outputFlags
+	fs.Var(&extraOutputs, "output", "Extra output as type[,min-severity=S][,min-confidence=N][,category=C][,tag=T]:target, repeatable (types: "+outputTypeNames()+")")
+	minSeverity := fs.String("min-severity", "info", "Only load rules with at least this severity (info, low, medium, high, critical)")
+	minConfidence := fs.String("min-confidence", "0", "Only report occurrences with at least this confidence score: 0-100, or low, medium, high")
+	dedupeMode := fs.String("dedupe-mode", "none", "Skip input URLs already seen and list matched URLs once, comparing them: none, exact, normalized or pattern")
+	dedupeStore := fs.String("dedupe-store", "memory", "Where --dedupe-mode and --found-urls remember URLs: memory (exact), bloom (bounded memory, rare misses) or disk")
+	fs.Float64Var(&config.Dedupe.FPRate, "dedupe-fp-rate", scanner.DefaultFPRate, "False-positive rate of --dedupe-store bloom: the share of new URLs taken for duplicates")
+	fs.StringVar(&config.Dedupe.Dir, "dedupe-dir", "", "Directory for the --dedupe-store disk file (default: the system temp directory)")
+	scope := fs.String("scope", "", "File of in-scope hosts, wildcards, CIDRs, path prefixes or re: regexps (or a HackerOne scope CSV); other input URLs are skipped")
+	exclude := fs.String("exclude", "", "File of out-of-scope entries, in the --scope format; matching input URLs are skipped")
+	decoders := fs.String("decode", "", "Also match decoded views of each URL: percent, plus, html, base64, comma-separated, or all")
+	fs.IntVar(&config.Decode.Depth, "decode-depth", scanner.DefaultDecodeDepth, "Layers of nested decoding for --decode")
+	categories := fs.String("category", "", "Only load rules from these categories, comma-separated (e.g. secrets,cloud)")
+	tags := fs.String("tags", "", "Only load rules carrying any of these tags, comma-separated")
+	var failOn failOnFlags
+	fs.Var(&failOn, "fail-on", "Exit 1 only for findings matching all of these comma-separated conditions (e.g. severity>=high,category=secrets), repeatable to match any of them")
+	failOnCount := fs.Int("fail-on-count", 0, "Exit 1 only if at least N findings match --fail-on (default 1)")
+	fs.StringVar(&config.ProfileFile, "profile-rules", "", "File to write per-rule evaluations, hits and regex time to, ranked by cost and noise (.json for JSON)")
+
+	return func() error {
 		var err error
-		var usedPath string
-		opened := false
-		for _, path := range possiblePaths {
-			file, err = os.Open(path)
-			if err == nil {
-				usedPath = path
-				opened = true
-				break
-			}
+		if config.MinSeverity, err = scanner.ParseSeverity(*minSeverity); err != nil {
+			return fmt.Errorf("--min-severity: %w", err)
+		}
+		if config.MinScore, err = scanner.ParseScore(*minConfidence); err != nil {
+			return fmt.Errorf("--min-confidence: %w", err)
+		}
+		if config.Dedupe.Mode, err = scanner.ParseDedupeMode(*dedupeMode); err != nil {
+			return fmt.Errorf("--dedupe-mode: %w", err)
 		}
-		if !opened {
-			msg := fmt.Sprintf("%s[WARN]%s Cannot open pattern file '%s'. Skipping.\n", ColorYellow, ColorReset, sourceName)
-			patternLoadingLog.WriteString(msg) // Collect messages
-			continue
-		}
-		baseSourceName := filepath.Base(usedPath)
-		if s.Config.Verbose {
-			msg := fmt.Sprintf("%s[INFO]%s Loading patterns from: %s\n", ColorCyan, ColorReset, usedPath)
-			patternLoadingLog.WriteString(msg)
-		}
-		fileScanner := bufio.NewScanner(file)
-		lineNum := 0
-		patternsInThisFile := 0
-		for fileScanner.Scan() {
-			lineNum++
-			line := strings.TrimSpace(fileScanner.Text())
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
+		if config.Dedupe.Store, err = scanner.ParseDedupeStore(*dedupeStore); err != nil {
+			return fmt.Errorf("--dedupe-store: %w", err)
+		}
+		if config.Dedupe.FPRate <= 0 || config.Dedupe.FPRate >= 1 {
+			return fmt.Errorf("--dedupe-fp-rate must be between 0 and 1, e.g. 0.001")
+		}
+		if *scope != "" {
+			if config.Scope, err = scanner.LoadScope(*scope); err != nil {
+				return fmt.Errorf("--scope: %w", err)
 			}
-			compiledPattern, errRegex := regexp.Compile(line)
-			if errRegex != nil {
-				msg := fmt.Sprintf("%s[WARN]%s Invalid regex (line %d) in '%s': '%s' (%v). Skipping.\n", ColorYellow, ColorReset, lineNum, usedPath, line, errRegex)
-				patternLoadingLog.WriteString(msg)
-				continue
+		}
+		if *exclude != "" {
+			if config.Exclude, err = scanner.LoadScope(*exclude); err != nil {
+				return fmt.Errorf("--exclude: %w", err)
 			}
-			loadedPatterns = append(loadedPatterns, PatternInfo{RegexStr: line, Compiled: compiledPattern, SourceFile: baseSourceName})
-			patternsInThisFile++
 		}
-		if errScan := fileScanner.Err(); errScan != nil {
-			msg := fmt.Sprintf("%s[WARN]%s Error reading '%s': %v.\n", ColorYellow, ColorReset, usedPath, errScan)
-			patternLoadingLog.WriteString(msg)
+		if config.Decode.Decoders, err = scanner.ParseDecoders(*decoders); err != nil {
+			return fmt.Errorf("--decode: %w", err)
 		}
-		file.Close()
-		if patternsInThisFile > 0 {
-			filesSuccessfullyProcessed++
+		if config.Decode.Depth < 1 {
+			return fmt.Errorf("--decode-depth must be at least 1")
+		}
+		config.Categories = scanner.SplitList(*categories)
+		config.Tags = scanner.SplitList(*tags)
+		if config.Policy, err = parseFailPolicy(failOn, *failOnCount); err != nil {
+			return err
+		}
+		if config.OutputFile != "" && config.FoundUrlsLogFile == "" {
+			config.FoundUrlsLogFile = config.OutputFile
 		}
+		config.Outputs = config.outputSpecs(extraOutputs)
+		return nil
 	}
+}
 
-	s.logGeneralMessage(patternLoadingLog.String(), true) // Log collected messages
+// headerFlags collects repeatable -H "Name: value" flags
+type headerFlags []string
 
-	s.Patterns = loadedPatterns
-	s.Stats.PatternsCount = len(loadedPatterns)
-	if len(s.Patterns) == 0 {
-		return fmt.Errorf("no valid patterns loaded from any specified sources ('%s')", s.Config.PatternsFile)
-	}
-	if filesSuccessfullyProcessed < len(patternFileSources) && len(patternFileSources) > 1 {
-		s.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Processed patterns from %d of %d sources.\n", ColorCyan, ColorReset, filesSuccessfullyProcessed, len(patternFileSources)), true)
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(value string) error {
+	if name, _, found := strings.Cut(value, ":"); !found || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header '%s' must be in 'Name: value' form", value)
 	}
+	*h = append(*h, value)
 	return nil
 }
 
-
 // ==============================================
-// MAIN SCANNING LOGIC
+// PATTERN LOADING
 // ==============================================
-func (s *Scanner) scan(input io.Reader) {
-	urlChan := make(chan string, s.Config.Threads*2)
-	uniqueMatchedURLChan := make(chan string, s.Config.Threads*2) // For --found-urls output
-
-	var readerWg, writerWg, workerWg sync.WaitGroup
-
-	if s.foundFile != nil {
-		writerWg.Add(1)
-		go func() {
-			defer writerWg.Done()
-			// Use a map to write unique URLs to the foundFile
-			writtenURLs := make(map[string]bool)
-			for resultURL := range uniqueMatchedURLChan {
-				if !writtenURLs[resultURL] {
-					fmt.Fprintln(s.foundFile, resultURL)
-					writtenURLs[resultURL] = true
-				}
-			}
-		}()
+// patternSearchDirs are tried, in order, for pattern files not found as given
+var patternSearchDirs = []string{"patterns", "/usr/share/codehunter/patterns"}
+
+// patternPacks is the store -r resolves pack references in, nil if there is
+// no pack directory
+func patternPacks() *scanner.PackStore {
+	dir := scanner.DefaultPackDir()
+	if dir == "" {
+		return nil
 	}
+	return &scanner.PackStore{Dir: dir}
+}
 
-	for i := 0; i < s.Config.Threads; i++ {
-		workerWg.Add(1)
-		go func(workerID int) {
-			defer workerWg.Done()
-			for url := range urlChan {
-				s.processURL(url, uniqueMatchedURLChan, workerID)
-			}
-		}(i)
-	}
+func (s *Runner) loadPatterns() error {
+	patternFileSources := strings.Split(s.Config.PatternsFile, ",")
+	var patternLoadingLog strings.Builder
 
-	readerWg.Add(1)
-	go func() {
-		defer readerWg.Done()
-		defer close(urlChan)
-		scanner := bufio.NewScanner(input)
-		for scanner.Scan() {
-			url := strings.TrimSpace(scanner.Text())
-			if url != "" && !strings.HasPrefix(url, "#") {
-				urlChan <- url
+	loaded, err := scanner.LoadRules(patternFileSources, scanner.LoadOptions{
+		SearchDirs: patternSearchDirs,
+		Packs:      patternPacks(),
+		OnFile: func(path string) {
+			if s.Config.Verbose {
+				patternLoadingLog.WriteString(fmt.Sprintf("%s[INFO]%s Loading patterns from: %s\n", ColorCyan, ColorReset, path))
 			}
-		}
-		if err := scanner.Err(); err != nil {
-			s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Error reading URLs: %v\n", ColorRed, ColorReset, err), true)
-		}
-	}()
+		},
+		OnWarning: func(msg string) {
+			patternLoadingLog.WriteString(fmt.Sprintf("%s[WARN]%s %s\n", ColorYellow, ColorReset, msg))
+		},
+	})
+	s.logGeneralMessage(patternLoadingLog.String(), true) // Log collected messages
+	if err != nil {
+		return err
+	}
 
-	readerWg.Wait()
-	workerWg.Wait()
-	close(uniqueMatchedURLChan)
+	s.Rules = loaded.Filter(scanner.Filter{
+		MinSeverity: s.Config.MinSeverity,
+		Categories:  s.Config.Categories,
+		Tags:        s.Config.Tags,
+	})
+	s.Stats.PatternsCount = s.Rules.Len()
+	if s.Rules.Len() == 0 {
+		return fmt.Errorf("all %d loaded patterns were excluded by --min-severity/--category/--tags", loaded.Len())
+	}
+	if s.Config.Verbose && s.Rules.Len() < loaded.Len() {
+		s.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Filters kept %d of %d patterns.\n", ColorCyan, ColorReset, s.Rules.Len(), loaded.Len()), false)
+	}
+	sources := make(map[string]bool)
+	for _, rule := range loaded.Rules {
+		sources[rule.SourceFile] = true
+	}
+	if len(sources) < len(patternFileSources) && len(patternFileSources) > 1 {
+		s.logGeneralMessage(fmt.Sprintf("%s[INFO]%s Processed patterns from %d of %d sources.\n", ColorCyan, ColorReset, len(sources), len(patternFileSources)), true)
+	}
+	return nil
+}
 
-	if s.foundFile != nil {
-		writerWg.Wait()
+// ==============================================
+// MAIN SCANNING LOGIC
+// ==============================================
+func (s *Runner) scan(ctx context.Context, input io.Reader) {
+	// Results, matches included, are written by onResult; the match channel
+	// only needs draining. After an interrupt every URL already scanned is
+	// still delivered.
+	for range s.scanner.Scan(ctx, input) {
+	}
+	err := s.scanner.Err()
+	if s.admitFunc() != nil {
+		// Including the part of the input a --resume skipped
+		stats := s.scanner.Stats()
+		s.Stats.OutOfScope, s.Stats.Excluded, s.Stats.DuplicatesSkipped = stats.OutOfScope, stats.Excluded, stats.DuplicatesSkipped
+		s.closeDedupe()
+	}
+	if errors.Is(err, context.Canceled) {
+		s.Stats.Interrupted = true
+	} else if err != nil {
+		s.Stats.Errors++
+		s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Error reading URLs: %v\n", ColorRed, ColorReset, err), true)
+	}
+	if s.checkpoint != nil {
+		s.outputMutex.Lock()
+		s.saveCheckpoint(err == nil)
+		s.outputMutex.Unlock()
 	}
 	s.Stats.EndTime = time.Now()
 }
 
 // ==============================================
-// URL PROCESSING (Writes one line per pattern match detail to logDetailFile)
+// URL RESULTS (progress, fetch logs, --found-urls)
 // ==============================================
-func (s *Scanner) processURL(url string, uniqueMatchedURLChan chan<- string, workerID int) {
-	s.mu.Lock()
-	s.Stats.URLsProcessed++
-	currentProcessed := s.Stats.URLsProcessed
-	s.mu.Unlock()
-
-	if s.Config.Verbose && currentProcessed%100 == 0 {
+// onResult runs on the scanner's worker goroutines, once per URL
+func (s *Runner) onResult(result scanner.Result) {
+	processed := int64(s.Stats.Resumed) + result.Seq + 1
+	if s.Config.Verbose && processed%100 == 0 {
 		s.logGeneralMessage(fmt.Sprintf("%s[PROGRESS]%s Processed %d URLs (Worker %d)\n",
-			ColorBlue, ColorReset, currentProcessed, workerID), false)
+			ColorBlue, ColorReset, processed, result.WorkerID), false)
 	}
 
-	var overallMatchForURL bool = false
-
-	for _, pInfo := range s.Patterns { // Test ALL patterns against the URL
-		foundOccurrences := pInfo.Compiled.FindAllString(url, -1)
-
-		if len(foundOccurrences) > 0 {
-			if !overallMatchForURL { // If this is the first pattern to match this URL
-				overallMatchForURL = true
-				s.mu.Lock()
-				s.Stats.URLsMatched++ // Increment unique matched URL count
-				s.mu.Unlock()
+	if result.FetchErr != nil {
+		s.logGeneralMessage(fmt.Sprintf("%s[FETCH_ERROR]%s %s: %v\n", ColorYellow, ColorReset, result.URL, result.FetchErr), false)
+	} else if result.Fetch != nil && s.Config.Verbose {
+		note := ""
+		if result.Fetch.Truncated {
+			note = ", truncated"
+		} else if result.Fetch.Skipped {
+			note = ", binary body skipped"
+		}
+		s.logGeneralMessage(fmt.Sprintf("%s[FETCH]%s %s -> %d (%d bytes%s)\n",
+			ColorBlue, ColorReset, result.URL, result.Fetch.StatusCode, len(result.Fetch.Body), note), false)
+	}
 
-				// Send to --found-urls file (only the URL, once per URL)
-				if s.foundFile != nil {
-					uniqueMatchedURLChan <- url
-				} else if !s.Config.Verbose { // If no --found-urls AND not verbose, print unique matched URL to stdout
-					fmt.Println(url)
-				}
-			}
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+	if s.checkpoint == nil {
+		s.writeResult(result)
+		return
+	}
+	// With --resume, results are written in input order so the outputs
+	// always cover a prefix of the input that the state file can point to
+	s.checkpoint.commit(result, s.writeResult)
+	if s.checkpoint.due() {
+		s.saveCheckpoint(false)
+	}
+}
 
-			// Log this specific pattern match detail to the --log-file
-			if s.logDetailFile != nil {
-				occurrencesString := strings.Join(foundOccurrences, " - ")
-				logLine := fmt.Sprintf("%s MATCHED_PATTERN: %s (From: %s) FOUND [%d time(s)]:- %s\n",
-					url, pInfo.RegexStr, pInfo.SourceFile, len(foundOccurrences), occurrencesString)
+// writeResult counts a URL and writes its matches. Call with outputMutex held.
+func (s *Runner) writeResult(result scanner.Result) {
+	s.Stats.Add(result)
+	if len(result.Matches) == 0 {
+		return
+	}
+	for _, match := range result.Matches {
+		if s.Config.Policy != nil && s.Config.Policy.matches(match.Rule) {
+			s.Stats.PolicyFindings++
+		}
+		s.writeMatch(match)
+	}
+}
 
-				s.logFileMutex.Lock()
-				fmt.Fprint(s.logDetailFile, logLine)
-				s.logFileMutex.Unlock()
-			}
+// ==============================================
+// MATCH OUTPUT (every sink, plus the verbose console line)
+// ==============================================
+func (s *Runner) writeMatch(match scanner.Match) {
+	for _, out := range s.outputs {
+		if err := out.sink.Write(match); err != nil {
+			s.Stats.Errors++
+			s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Writing %s output '%s': %v\n",
+				ColorRed, ColorReset, out.spec.Type, out.spec.Target, err), true)
+		}
+	}
 
-			if s.Config.Verbose { // Verbose output for each pattern hit
-				s.logGeneralMessage(fmt.Sprintf("%s[MATCH_DETAIL]%s %s (Pattern: %s, Occurrences: %d)\n",
-					ColorGreen, ColorReset, url, pInfo.RegexStr, len(foundOccurrences)), false)
+	if s.Config.Verbose { // Verbose output for each pattern hit
+		rule := match.Rule
+		s.logGeneralMessage(fmt.Sprintf("%s[MATCH_DETAIL]%s %s (Rule: %s, Severity: %s, Score: %d, Occurrences: %d)\n",
+			ColorGreen, ColorReset, match.URL, rule.DisplayName(), rule.Severity, match.Score(), len(match.Occurrences)), false)
+		for _, occ := range match.Occurrences {
+			if occ.JWT != nil {
+				s.logGeneralMessage(fmt.Sprintf("%s[JWT]%s %s\n", ColorYellow, ColorReset, occ.JWT), false)
 			}
 		}
 	}
 }
 
+// severitySummary renders FindingsBySeverity as "C:0 H:2 M:1 L:0 I:5"
+func (s *Runner) severitySummary() string {
+	parts := make([]string, 0, len(s.Stats.FindingsBySeverity))
+	for sev := scanner.SeverityCritical; sev >= scanner.SeverityInfo; sev-- {
+		parts = append(parts, fmt.Sprintf("%s:%d", strings.ToUpper(sev.String()[:1]), s.Stats.FindingsBySeverity[sev]))
+	}
+	return strings.Join(parts, " ")
+}
+
 // ==============================================
 // STATISTICS DISPLAY (Now only prints to STDOUT if banner/verbose)
 // ==============================================
-func (s *Scanner) showFinalStats() {
+func (s *Runner) showFinalStats() {
 	// Only proceed to build and print stats if banner or verbose mode is on
 	if !s.Config.ShowBanner && !s.Config.Verbose {
 		return
@@ -498,7 +827,7 @@ func (s *Scanner) showFinalStats() {
 	duration := s.Stats.EndTime.Sub(s.Stats.StartTime)
 	speed := 0.0
 	if duration.Seconds() > 0 {
-		speed = float64(s.Stats.URLsProcessed) / duration.Seconds()
+		speed = float64(s.Stats.URLsProcessed-s.Stats.Resumed) / duration.Seconds()
 	}
 
 	// ... (rest of the statsBuilder formatting as in v2.5.5) ...
@@ -511,6 +840,20 @@ func (s *Scanner) showFinalStats() {
 		ColorPurple, ColorReset, ColorGreen, s.Stats.URLsMatched, ColorReset, ColorPurple, ColorReset))
 	statsBuilder.WriteString(fmt.Sprintf("%s║%s  🔍 Patterns Used:  %s%-10d%s                     %s║%s\n",
 		ColorPurple, ColorReset, ColorYellow, s.Stats.PatternsCount, ColorReset, ColorPurple, ColorReset))
+	statsBuilder.WriteString(fmt.Sprintf("%s║%s  🔥 By Severity:    %s%-25s%s %s║%s\n",
+		ColorPurple, ColorReset, ColorRed, s.severitySummary(), ColorReset, ColorPurple, ColorReset))
+	if s.Config.Fetch {
+		statsBuilder.WriteString(fmt.Sprintf("%s║%s  🌐 Fetched:        %s%-10d%s errors: %-10d %s║%s\n",
+			ColorPurple, ColorReset, ColorCyan, s.Stats.URLsFetched, ColorReset, s.Stats.FetchErrors, ColorPurple, ColorReset))
+	}
+	if s.Config.Scope != nil || s.Config.Exclude != nil {
+		statsBuilder.WriteString(fmt.Sprintf("%s║%s  🚧 Out of Scope:   %s%-10d%s excluded: %-9d %s║%s\n",
+			ColorPurple, ColorReset, ColorCyan, s.Stats.OutOfScope, ColorReset, s.Stats.Excluded, ColorPurple, ColorReset))
+	}
+	if s.dedupe != nil {
+		statsBuilder.WriteString(fmt.Sprintf("%s║%s  ♻️ Duplicates:     %s%-10d%s skipped (%-10s) %s║%s\n",
+			ColorPurple, ColorReset, ColorCyan, s.Stats.DuplicatesSkipped, ColorReset, s.Config.Dedupe.Mode, ColorPurple, ColorReset))
+	}
 	statsBuilder.WriteString(fmt.Sprintf("%s║%s  ⏱️ Duration:       %s%-10s%s                     %s║%s\n",
 		ColorPurple, ColorReset, ColorBlue, duration.Truncate(time.Millisecond).String(), ColorReset, ColorPurple, ColorReset))
 	statsBuilder.WriteString(fmt.Sprintf("%s║%s  🚀 Speed:          %s%-10.1f URLs/sec%s           %s║%s\n",
@@ -518,7 +861,10 @@ func (s *Scanner) showFinalStats() {
 	statsBuilder.WriteString(fmt.Sprintf("%s╠══════════════════════════════════════════════════════════╣%s\n", ColorPurple, ColorReset))
 
 	var matchStatus, matchColor string
-	if s.Stats.URLsMatched == 0 {
+	if s.Stats.Interrupted {
+		matchStatus = "Interrupted (partial)"
+		matchColor = ColorYellow
+	} else if s.Stats.URLsMatched == 0 {
 		matchStatus = "No matches found"
 		matchColor = ColorYellow
 	} else if s.Stats.URLsMatched < 10 && s.Stats.URLsMatched > 0 {
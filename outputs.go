@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Acorzo1983/Codehunter/pkg/output"
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// OUTPUT SINKS
+// ==============================================
+// Every output is an output.Sink. The file flags (--found-urls, --log-file,
+// --jsonl, ...) are shorthands for sink specs, --output adds any registered
+// sink with its own filter, and the list of matched URLs on stdout is a
+// "urls:-" sink added when nothing else collects the URLs.
+
+type runnerOutput struct {
+	spec   output.Spec
+	sink   output.Sink
+	failed bool // Close failed, so the output is not reported as saved
+}
+
+// outputFlags collects repeatable --output specs
+type outputFlags []output.Spec
+
+func (o *outputFlags) String() string {
+	var specs []string
+	for _, spec := range *o {
+		specs = append(specs, spec.Type+":"+spec.Target)
+	}
+	return strings.Join(specs, ", ")
+}
+
+func (o *outputFlags) Set(value string) error {
+	spec, err := output.ParseSpec(value)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, spec)
+	return nil
+}
+
+func outputTypeNames() string {
+	var names []string
+	for _, t := range output.Types() {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// outputSpecs turns the file flags into sink specs, followed by extra
+func (c Config) outputSpecs(extra []output.Spec) []output.Spec {
+	var specs []output.Spec
+	for _, file := range []struct{ sinkType, path string }{
+		{"urls", c.FoundUrlsLogFile},
+		{"text", c.LogFile},
+		{"jsonl", c.JSONLFile},
+		{"sarif", c.SARIFFile},
+		{"html", c.HTMLFile},
+		{"csv", c.CSVFile},
+		{"markdown", c.MarkdownFile},
+	} {
+		if file.path != "" {
+			specs = append(specs, output.Spec{Type: file.sinkType, Target: file.path})
+		}
+	}
+	specs = append(specs, extra...)
+
+	// Without --found-urls (or another URL list) matched URLs are printed,
+	// unless verbose mode or another output is already using stdout
+	listed := false
+	for _, spec := range specs {
+		listed = listed || spec.Type == "urls" || spec.Target == "-"
+	}
+	if !listed && !c.Verbose {
+		specs = append(specs, output.Spec{Type: "urls", Target: "-"})
+	}
+	return specs
+}
+
+// setupOutputs opens every sink. Output files go through openOutputFile, so
+// they are reopened for appending when a --resume state is continued.
+func (s *Runner) setupOutputs() error {
+	s.files = make(map[string]*os.File)
+	env := output.Env{
+		Rules:       s.Rules,
+		ToolVersion: VERSION,
+		Resumable:   s.checkpoint != nil,
+		OpenFile:    s.openOutputFile,
+	}
+	env.UniqueURLs = s.Config.Dedupe.Mode != scanner.DedupeNone
+	env.NewKeySet = func() (scanner.KeySet, error) { return scanner.NewKeySet(s.Config.Dedupe) }
+	for _, spec := range s.Config.Outputs {
+		sink, err := output.OpenSpec(spec, env)
+		if err != nil {
+			s.CloseFiles() // Clean up if partially successful
+			return err
+		}
+		s.outputs = append(s.outputs, runnerOutput{spec: spec, sink: sink})
+	}
+	return nil
+}
+
+func (s *Runner) openOutputFile(path string) (*os.File, bool, error) {
+	if s.files[path] != nil {
+		return nil, false, fmt.Errorf("'%s' is already used by another output", path)
+	}
+	var file *os.File
+	var err error
+	appending := false
+	if s.checkpoint != nil {
+		file, err = s.checkpoint.prepareOutput(path)
+		appending = s.checkpoint.resuming()
+	} else {
+		file, err = os.Create(path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	s.files[path] = file
+	return file, appending, nil
+}
+
+// closeOutputs finishes every sink, which writes the reports that are only
+// complete once the scan is
+func (s *Runner) closeOutputs() {
+	summary := output.Summary{
+		Stats:       s.Stats.Stats,
+		Started:     s.Stats.StartTime,
+		Finished:    s.Stats.EndTime,
+		Interrupted: s.Stats.Interrupted,
+		Settings:    s.reportSettings(),
+	}
+	for i, out := range s.outputs {
+		if err := out.sink.Close(summary); err != nil {
+			s.outputs[i].failed = true
+			s.Stats.Errors++
+			s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Closing %s output '%s': %v\n",
+				ColorRed, ColorReset, out.spec.Type, out.spec.Target, err), true)
+		}
+	}
+}
+
+// outputLabels names the built-in outputs in the final messages
+var outputLabels = map[string]string{
+	"urls":     "Matched URLs saved to",
+	"text":     "Detailed match log saved to",
+	"jsonl":    "JSON Lines match log saved to",
+	"sarif":    "SARIF report saved to",
+	"html":     "HTML report saved to",
+	"csv":      "CSV match log saved to",
+	"markdown": "Markdown report saved to",
+	"webhook":  "Matches sent to",
+}
+
+// showSavedOutputs names the outputs written, on stderr when an output
+// uses stdout so the messages do not end up in its data
+func (s *Runner) showSavedOutputs() {
+	messages := io.Writer(os.Stdout)
+	for _, out := range s.outputs {
+		if out.spec.Target == "-" && out.spec.Type != "urls" {
+			messages = os.Stderr
+		}
+	}
+	for _, out := range s.outputs {
+		if out.failed || out.spec.Target == "-" {
+			continue
+		}
+		label, ok := outputLabels[out.spec.Type]
+		if !ok {
+			label = out.spec.Type + " output written to"
+		}
+		fmt.Fprintf(messages, "%s[INFO]%s %s: %s\n", ColorGreen, ColorReset, label, out.spec.Target)
+	}
+	if s.profileFile != nil {
+		fmt.Fprintf(messages, "%s[INFO]%s Rule profile saved to: %s\n", ColorGreen, ColorReset, s.Config.ProfileFile)
+	}
+}
+
+// reportSettings lists the configuration shown in reports. Header values
+// are left out: they often carry credentials.
+func (s *Runner) reportSettings() []output.Setting {
+	c := s.Config
+	input := c.UrlsFile
+	if input == "" {
+		input = "stdin"
+	}
+	settings := []output.Setting{
+		{Name: "Patterns", Value: c.PatternsFile},
+		{Name: "Patterns loaded", Value: fmt.Sprint(s.Stats.PatternsCount)},
+		{Name: "Input", Value: input},
+		{Name: "Threads", Value: fmt.Sprint(c.Threads)},
+		{Name: "Minimum severity", Value: c.MinSeverity.String()},
+	}
+	if c.MinScore > 0 {
+		settings = append(settings, output.Setting{Name: "Minimum confidence score", Value: fmt.Sprint(c.MinScore)})
+	}
+	if d := c.Dedupe; d.Mode != scanner.DedupeNone {
+		store := string(d.Store)
+		if d.Store == scanner.StoreBloom {
+			store += fmt.Sprintf(", false-positive rate %g", d.FPRate)
+		}
+		settings = append(settings, output.Setting{Name: "Dedupe mode", Value: fmt.Sprintf("%s (%s)", d.Mode, store)})
+	}
+	if c.Scope != nil {
+		settings = append(settings, output.Setting{Name: "Scope", Value: fmt.Sprintf("%s (%d entries)", c.Scope.Name, c.Scope.Len())})
+	}
+	if c.Exclude != nil {
+		settings = append(settings, output.Setting{Name: "Exclude", Value: fmt.Sprintf("%s (%d entries)", c.Exclude.Name, c.Exclude.Len())})
+	}
+	if decode := c.Decode.String(); decode != "" {
+		settings = append(settings, output.Setting{Name: "Decoding", Value: decode})
+	}
+	if len(c.Categories) > 0 {
+		settings = append(settings, output.Setting{Name: "Categories", Value: strings.Join(c.Categories, ", ")})
+	}
+	if len(c.Tags) > 0 {
+		settings = append(settings, output.Setting{Name: "Tags", Value: strings.Join(c.Tags, ", ")})
+	}
+	if c.Policy != nil {
+		settings = append(settings, output.Setting{Name: "Fail policy", Value: c.Policy.String()})
+	}
+	if c.Fetch {
+		fc := c.FetchConfig
+		settings = append(settings,
+			output.Setting{Name: "Fetch", Value: fmt.Sprintf("timeout %s, max body %d bytes", fc.Timeout, fc.MaxBodySize)},
+			output.Setting{Name: "User-Agent", Value: fc.UserAgent})
+		if fc.Proxy != "" {
+			proxy := fc.Proxy
+			if u, err := url.Parse(proxy); err == nil && u.User != nil {
+				u.User = nil // Reports are shared; proxy credentials are not
+				proxy = u.String()
+			}
+			settings = append(settings, output.Setting{Name: "Proxy", Value: proxy})
+		}
+		if fc.RateLimit > 0 {
+			settings = append(settings, output.Setting{Name: "Rate limit", Value: fmt.Sprintf("%g requests/sec", fc.RateLimit)})
+		}
+		if len(fc.Headers) > 0 {
+			names := make([]string, len(fc.Headers))
+			for i, header := range fc.Headers {
+				names[i], _, _ = strings.Cut(header, ":")
+			}
+			settings = append(settings, output.Setting{Name: "Extra headers", Value: strings.Join(names, ", ")})
+		}
+		if fc.Insecure {
+			settings = append(settings, output.Setting{Name: "TLS verification", Value: "disabled"})
+		}
+	}
+	var outputs []string
+	for _, out := range s.outputs {
+		if out.spec.Type == "webhook" {
+			outputs = append(outputs, "webhook") // The URL may embed a token
+			continue
+		}
+		outputs = append(outputs, out.spec.Type+":"+out.spec.Target)
+	}
+	settings = append(settings, output.Setting{Name: "Outputs", Value: strings.Join(outputs, ", ")})
+	return settings
+}
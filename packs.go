@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// PATTERN PACKS (codehunter patterns install/update/list)
+// ==============================================
+// Packs are installed into scanner.DefaultPackDir(), where -r finds them by
+// name, name@version or name@version/file.
+
+// packDownloadTimeout bounds each request of an install or update
+const packDownloadTimeout = 2 * time.Minute
+
+// packStoreFlag registers --dir, the pack directory of these subcommands
+func packStoreFlag(fs *flag.FlagSet) func() (*scanner.PackStore, error) {
+	dir := fs.String("dir", scanner.DefaultPackDir(), "Pack directory (default from $CODEHUNTER_HOME or $XDG_DATA_HOME)")
+	return func() (*scanner.PackStore, error) {
+		if *dir == "" {
+			return nil, fmt.Errorf("no pack directory: set $CODEHUNTER_HOME or use --dir")
+		}
+		return &scanner.PackStore{Dir: *dir, Client: &http.Client{Timeout: packDownloadTimeout}}, nil
+	}
+}
+
+func runPatternsInstall(args []string) int {
+	var sum string
+	var force bool
+
+	fs := flag.NewFlagSet("patterns install", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns install%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns install ./my-pack                        (directory with a pack.toml)")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns install --sha256 <sum> https://example.com/cloud-keys-1.2.0.tar.gz")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns install https://example.com/packs/cloud-keys/pack.toml")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns install https://github.com/user/packs.git#v1.2.0")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	store := packStoreFlag(fs)
+	fs.StringVar(&sum, "sha256", "", "Expected sha256 checksum of the archive or pack.toml (one source only)")
+	fs.BoolVar(&force, "force", false, "Reinstall a version that is already installed")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitConfigError
+	}
+	if sum != "" && fs.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s --sha256 applies to a single source\n", ColorRed, ColorReset)
+		return exitConfigError
+	}
+	packs, err := store()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	status := exitOK
+	for _, source := range fs.Args() {
+		pack, err := packs.Install(ctx, source, scanner.InstallOptions{SHA256: sum, Force: force})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Install failed: %v\n", ColorRed, ColorReset, err)
+			status = exitRuntimeError
+			continue
+		}
+		fmt.Printf("%s[INFO]%s Installed %s%s%s (%d files) into %s\n", ColorCyan, ColorReset, ColorBold, pack.Ref(), ColorReset, len(pack.Files), pack.Dir)
+	}
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return status
+}
+
+func runPatternsUpdate(args []string) int {
+	fs := flag.NewFlagSet("patterns update", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns update%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns update                    (every installed pack)")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns update cloud-keys")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	store := packStoreFlag(fs)
+	fs.Parse(args)
+
+	packs, err := store()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		installed, err := packs.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+			return exitRuntimeError
+		}
+		seen := make(map[string]bool)
+		for _, pack := range installed {
+			if !seen[pack.Name] {
+				seen[pack.Name] = true
+				names = append(names, pack.Name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Printf("%s[INFO]%s No pattern packs installed in %s\n", ColorCyan, ColorReset, packs.Dir)
+			return exitOK
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	status := exitOK
+	for _, name := range names {
+		pack, updated, err := packs.Update(ctx, name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Updating %s: %v\n", ColorRed, ColorReset, name, err)
+			status = exitRuntimeError
+		case updated:
+			fmt.Printf("%s[INFO]%s Updated %s to %s%s%s\n", ColorCyan, ColorReset, name, ColorBold, pack.Version, ColorReset)
+		default:
+			fmt.Printf("%s[INFO]%s %s is up to date\n", ColorCyan, ColorReset, pack.Ref())
+		}
+	}
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return status
+}
+
+func runPatternsList(args []string) int {
+	var format string
+
+	fs := flag.NewFlagSet("patterns list", flag.ExitOnError)
+	store := packStoreFlag(fs)
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s Unknown format '%s' (use text or json)\n", ColorRed, ColorReset, format)
+		return exitConfigError
+	}
+	packs, err := store()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	installed, err := packs.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitRuntimeError
+	}
+
+	type listedPack struct {
+		*scanner.Pack
+		Verified bool `json:"verified"` // Every file matches its checksum
+	}
+	listed := []listedPack{}
+	for _, pack := range installed {
+		listed = append(listed, listedPack{pack, pack.Verify() == nil})
+	}
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(listed); err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+			return exitRuntimeError
+		}
+		return exitOK
+	}
+
+	if len(listed) == 0 {
+		fmt.Printf("%s[INFO]%s No pattern packs installed in %s\n", ColorCyan, ColorReset, packs.Dir)
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tFILES\tSTATUS\tINSTALLED\tSOURCE")
+	for _, p := range listed {
+		status := "ok"
+		if !p.Verified {
+			status = "MODIFIED"
+		}
+		installedAt := "-"
+		if !p.Installed.IsZero() {
+			installedAt = p.Installed.Local().Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", p.Name, p.Version, len(p.Files), status, installedAt, p.Source)
+	}
+	w.Flush()
+	fmt.Printf("%d packs in %s; use them with -r name, name@version or name@version/file\n", len(listed), packs.Dir)
+	return exitOK
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// PATTERN TOOLS (codehunter patterns)
+// ==============================================
+
+func runPatterns(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns lint [-r files] [--corpus urls.txt] [--format text|json] [files...]")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns test [-r files] [--corpus examples/urls.txt] [--format text|json] [files...]")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns export [-r files] [--format gitleaks] [-o file] [files...]")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns install [--sha256 sum] [--force] <dir|archive|url|git-repo>...")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns update [pack...]")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns list [--format text|json]")
+	}
+	if len(args) == 0 {
+		usage()
+		return exitConfigError
+	}
+	switch args[0] {
+	case "lint":
+		return runPatternsLint(args[1:])
+	case "test":
+		return runPatternsTest(args[1:])
+	case "export":
+		return runPatternsExport(args[1:])
+	case "install":
+		return runPatternsInstall(args[1:])
+	case "update":
+		return runPatternsUpdate(args[1:])
+	case "list":
+		return runPatternsList(args[1:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "%s[ERROR]%s Unknown patterns command '%s'\n", ColorRed, ColorReset, args[0])
+	usage()
+	return exitConfigError
+}
+
+// runPatternsLint checks pattern files and returns exitFindings if any
+// error (or, with --strict, any issue) was found
+func runPatternsLint(args []string) int {
+	var files, corpus, format string
+	var maxRate float64
+	var strict bool
+
+	fs := flag.NewFlagSet("patterns lint", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns lint%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns lint                      (every file of the default pattern directory)")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns lint -r secrets.txt,custom.toml --corpus urls.txt")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns lint --format json my_patterns/*.toml")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&files, "r", "", "Pattern file(s) to lint, comma-separated (files may also follow the flags)")
+	fs.StringVar(&corpus, "corpus", "", "File of sample URLs, one per line, to find rules matching almost everything")
+	fs.Float64Var(&maxRate, "max-match-rate", scanner.DefaultMaxMatchRate, "Share of the corpus above which a rule is reported as broad")
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.BoolVar(&strict, "strict", false, "Exit with 1 on warnings too")
+	fs.Parse(args)
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s Unknown format '%s' (use text or json)\n", ColorRed, ColorReset, format)
+		return exitConfigError
+	}
+	if maxRate <= 0 || maxRate > 1 {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s --max-match-rate must be above 0 and at most 1\n", ColorRed, ColorReset)
+		return exitConfigError
+	}
+
+	paths, err := patternFileArgs(files, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	opts := scanner.LintOptions{SearchDirs: patternSearchDirs, Packs: patternPacks(), MaxMatchRate: maxRate}
+	if corpus != "" {
+		urls, err := readSeedsFile(corpus)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Cannot read corpus: %v\n", ColorRed, ColorReset, err)
+			return exitConfigError
+		}
+		opts.Corpus = urls
+	}
+
+	report, err := scanner.LintFiles(paths, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	errors, warnings := report.Count(scanner.LintError), report.Count(scanner.LintWarning)
+	if format == "json" {
+		out := struct {
+			*scanner.LintReport
+			Errors   int `json:"errors"`
+			Warnings int `json:"warnings"`
+		}{report, errors, warnings}
+		if out.Issues == nil {
+			out.Issues = []scanner.LintIssue{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+			return exitRuntimeError
+		}
+	} else {
+		printLintReport(report, errors, warnings)
+	}
+
+	if errors > 0 || (strict && warnings > 0) {
+		return exitFindings
+	}
+	return exitOK
+}
+
+func printLintReport(report *scanner.LintReport, errors, warnings int) {
+	for _, issue := range report.Issues {
+		label := fmt.Sprintf("%s[WARN]%s ", ColorYellow, ColorReset)
+		if issue.Level == scanner.LintError {
+			label = fmt.Sprintf("%s[ERROR]%s", ColorRed, ColorReset)
+		}
+		location := issue.File
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d", issue.File, issue.Line)
+		}
+		fmt.Printf("%s %s %s%s:%s %s\n", label, location, ColorBold, issue.Check, ColorReset, issue.Message)
+	}
+	color := ColorGreen
+	switch {
+	case errors > 0:
+		color = ColorRed
+	case warnings > 0:
+		color = ColorYellow
+	}
+	fmt.Printf("%s%d errors, %d warnings%s in %d files (%d rules)\n", color, errors, warnings, ColorReset, len(report.Files), report.Rules)
+}
+
+// defaultTestCorpus is read by patterns test unless --corpus names another
+const defaultTestCorpus = "examples/urls.txt"
+
+// runPatternsTest checks every rule against its examples and the corpus
+// expectations, and returns exitFindings if any check failed
+func runPatternsTest(args []string) int {
+	var files, corpus, format, minConfidence string
+	var verbose bool
+
+	fs := flag.NewFlagSet("patterns test", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns test%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns test                      (every file of the default pattern directory)")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns test -r custom.txt --corpus my_urls.txt")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns test --format json high_confidence.toml")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&files, "r", "", "Pattern file(s) to test, comma-separated (files may also follow the flags)")
+	fs.StringVar(&corpus, "corpus", defaultTestCorpus, "URL file with #+/#- expectations (\"none\" to skip)")
+	fs.StringVar(&minConfidence, "min-confidence", "0", "Only count occurrences with at least this confidence score, as in scans")
+	fs.StringVar(&format, "format", "text", "Output format: text or json")
+	fs.BoolVar(&verbose, "v", false, "Also list the rules without examples")
+	fs.Parse(args)
+
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s Unknown format '%s' (use text or json)\n", ColorRed, ColorReset, format)
+		return exitConfigError
+	}
+	opts := scanner.TestOptions{}
+	var err error
+	if opts.MinScore, err = scanner.ParseScore(minConfidence); err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s --min-confidence: %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	paths, err := patternFileArgs(files, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	rules, err := scanner.LoadRules(paths, scanner.LoadOptions{
+		SearchDirs: patternSearchDirs,
+		Packs:      patternPacks(),
+		OnWarning: func(msg string) {
+			fmt.Fprintf(os.Stderr, "%s[WARN]%s %s\n", ColorYellow, ColorReset, msg)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	// The default corpus is optional, one named with --corpus is not
+	corpusSet := false
+	fs.Visit(func(f *flag.Flag) { corpusSet = corpusSet || f.Name == "corpus" })
+	if corpus != "none" && corpus != "" {
+		opts.Corpus, err = scanner.LoadTestCorpus(corpus)
+		switch {
+		case err == nil:
+			opts.CorpusName = corpus
+		case os.IsNotExist(err) && !corpusSet:
+		default:
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Cannot read corpus: %v\n", ColorRed, ColorReset, err)
+			return exitConfigError
+		}
+	}
+
+	report := scanner.RunRuleTests(rules, opts)
+	if format == "json" {
+		if report.Failures == nil {
+			report.Failures = []scanner.TestFailure{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+			return exitRuntimeError
+		}
+	} else {
+		printTestReport(report, rules, opts.CorpusName, verbose)
+	}
+
+	if len(report.Failures) > 0 {
+		return exitFindings
+	}
+	return exitOK
+}
+
+func printTestReport(report *scanner.TestReport, rules *scanner.RuleSet, corpusName string, verbose bool) {
+	for _, failure := range report.Failures {
+		location := failure.File
+		if failure.Line > 0 {
+			location = fmt.Sprintf("%s:%d", failure.File, failure.Line)
+		}
+		subject := failure.RuleID
+		switch failure.Kind {
+		case scanner.TestMissedURL:
+			subject = "#+ " + failure.Selector
+		case scanner.TestHitURL:
+			subject = "#- " + failure.Selector
+		}
+		fmt.Printf("%s[FAIL]%s %s %s%s:%s %s\n", ColorRed, ColorReset, location, ColorBold, subject, ColorReset, failure.Message)
+	}
+	if verbose {
+		for _, rule := range rules.Rules {
+			if len(rule.Examples) == 0 && len(rule.Negatives) == 0 {
+				fmt.Printf("%s[INFO]%s %s:%d %s has no examples\n", ColorCyan, ColorReset, rule.SourceFile, rule.Line, rule.ID)
+			}
+		}
+	}
+
+	color := ColorGreen
+	if len(report.Failures) > 0 {
+		color = ColorRed
+	}
+	fmt.Printf("%s%d failed, %d passed%s: %d of %d rules with examples (%d checked)",
+		color, len(report.Failures), report.Checks-len(report.Failures), ColorReset, report.Tested, report.Rules, report.Examples)
+	if corpusName != "" {
+		fmt.Printf(", %d URLs in %s", report.CorpusURLs, corpusName)
+		if report.Skipped > 0 {
+			fmt.Printf(" (%d expectations for rules not loaded skipped)", report.Skipped)
+		}
+	}
+	fmt.Println()
+}
+
+// runPatternsExport writes rules in another tool's format. Only gitleaks
+// is supported; the importers read the other formats directly.
+func runPatternsExport(args []string) int {
+	var files, format, outFile, title string
+
+	fs := flag.NewFlagSet("patterns export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\n%s🎯 CodeHunter v%s - patterns export%s\n\n", ColorBold, VERSION, ColorReset)
+		fmt.Fprintf(os.Stderr, "%s📋 Usage:%s\n", ColorYellow, ColorReset)
+		fmt.Fprintln(os.Stderr, "  codehunter patterns export -o gitleaks.toml   (every file of the default pattern directory)")
+		fmt.Fprintln(os.Stderr, "  codehunter patterns export -r secrets.txt,high_confidence.toml -o gitleaks.toml")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%s🔧 Flags:%s\n", ColorYellow, ColorReset)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&files, "r", "", "Pattern file(s) to export, comma-separated (files may also follow the flags)")
+	fs.StringVar(&format, "format", "gitleaks", "Output format: gitleaks")
+	fs.StringVar(&outFile, "o", "", "Output file (stdout if not set)")
+	fs.StringVar(&title, "title", "CodeHunter rules", "Title of the gitleaks configuration")
+	fs.Parse(args)
+
+	if format != "gitleaks" {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s Unknown format '%s' (use gitleaks)\n", ColorRed, ColorReset, format)
+		return exitConfigError
+	}
+	paths, err := patternFileArgs(files, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+	rules, err := scanner.LoadRules(paths, scanner.LoadOptions{
+		SearchDirs: patternSearchDirs,
+		Packs:      patternPacks(),
+		OnWarning: func(msg string) {
+			fmt.Fprintf(os.Stderr, "%s[WARN]%s %s\n", ColorYellow, ColorReset, msg)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitConfigError
+	}
+
+	out := os.Stdout
+	if outFile != "" {
+		if out, err = os.Create(outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s[ERROR]%s Cannot create '%s': %v\n", ColorRed, ColorReset, outFile, err)
+			return exitConfigError
+		}
+	}
+	dropped, err := scanner.ExportGitleaks(out, rules.Rules, title)
+	if outFile != "" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[ERROR]%s %v\n", ColorRed, ColorReset, err)
+		return exitRuntimeError
+	}
+	// The same loss usually hits many rules: report each kind once
+	var reasons []string
+	ruleIDs := make(map[string][]string)
+	for _, msg := range dropped {
+		id, reason, _ := strings.Cut(msg, ": ")
+		if ruleIDs[reason] == nil {
+			reasons = append(reasons, reason)
+		}
+		ruleIDs[reason] = append(ruleIDs[reason], id)
+	}
+	for _, reason := range reasons {
+		ids := ruleIDs[reason]
+		listed := strings.Join(ids, ", ")
+		if len(ids) > 3 {
+			listed = fmt.Sprintf("%s and %d more", strings.Join(ids[:3], ", "), len(ids)-3)
+		}
+		fmt.Fprintf(os.Stderr, "%s[WARN]%s Not exported: %s (%s)\n", ColorYellow, ColorReset, reason, listed)
+	}
+	if outFile != "" {
+		fmt.Fprintf(os.Stderr, "%s[INFO]%s Exported %d rules to %s\n", ColorCyan, ColorReset, rules.Len(), outFile)
+	}
+	return exitOK
+}
+
+// patternFileArgs returns the files of -r and the positional arguments,
+// or the default pattern files when both are empty
+func patternFileArgs(flagValue string, args []string) ([]string, error) {
+	var paths []string
+	if flagValue != "" {
+		paths = append(paths, strings.Split(flagValue, ",")...)
+	}
+	paths = append(paths, args...)
+	if len(paths) == 0 {
+		return defaultPatternFiles()
+	}
+	return paths, nil
+}
+
+// defaultPatternFiles lists the rule files (.txt, .toml, .yaml, .yml) of
+// the first pattern directory that exists
+func defaultPatternFiles() ([]string, error) {
+	for _, dir := range patternSearchDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		var paths []string
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".txt" || ext == ".toml" || ext == ".yaml" || ext == ".yml") {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+		sort.Strings(paths)
+		if len(paths) > 0 {
+			return paths, nil
+		}
+	}
+	return nil, fmt.Errorf("no pattern files found in %s; name them with -r", strings.Join(patternSearchDirs, " or "))
+}
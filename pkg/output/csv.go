@@ -0,0 +1,96 @@
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// CSV
+// ==============================================
+// One row per match, like the --log-file lines, flushed as it is written so
+// the file can be checkpointed and appended to. Occurrences share one cell,
+// one per line. Cells that a spreadsheet would run as a formula get a
+// leading quote: URLs come from targets and must not execute when opened.
+
+var CSVHeader = []string{
+	"url", "rule_id", "name", "severity", "confidence", "score", "category", "tags",
+	"source_file", "line", "pattern", "count", "occurrences", "timestamp",
+}
+
+// CSVWriter is the "csv" sink
+type CSVWriter struct {
+	target fileTarget
+	w      *csv.Writer
+}
+
+// NewCSVWriter returns a sink writing CSV rows to w
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{target: fileTarget{w: w}}
+}
+
+// Open writes CSVHeader, unless the file is being appended to
+func (cw *CSVWriter) Open(env Env) error {
+	if err := cw.target.open(env); err != nil {
+		return err
+	}
+	cw.w = csv.NewWriter(cw.target.w)
+	if cw.target.appending {
+		return nil
+	}
+	return cw.writeRow(append([]string(nil), CSVHeader...))
+}
+
+func (cw *CSVWriter) Write(m scanner.Match) error {
+	rule := m.Rule
+	return cw.writeRow([]string{
+		m.URL,
+		rule.ID,
+		rule.Name,
+		rule.Severity.String(),
+		rule.Confidence.String(),
+		strconv.Itoa(m.Score()),
+		rule.Category,
+		strings.Join(rule.Tags, ";"),
+		rule.SourceFile,
+		strconv.Itoa(rule.Line),
+		rule.Regex,
+		strconv.Itoa(len(m.Occurrences)),
+		strings.Join(describedValues(m), "\n"),
+		m.Timestamp.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (cw *CSVWriter) writeRow(row []string) error {
+	for i, cell := range row {
+		row[i] = neutralizeFormula(cell)
+	}
+	if err := cw.w.Write(row); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// neutralizeFormula prefixes cells starting with a formula trigger with a
+// quote, which spreadsheets show as text
+func neutralizeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (cw *CSVWriter) Close(Summary) error {
+	cw.w.Flush()
+	err := cw.w.Error()
+	if closeErr := cw.target.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
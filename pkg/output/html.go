@@ -0,0 +1,414 @@
+package output
+
+import (
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// HTML REPORT
+// ==============================================
+// A single offline HTML file for people who do not read JSON: summary
+// cards, breakdowns per pattern file and per host, and a findings table
+// that can be sorted and filtered in the browser. Styles and script are
+// inline, so the file can be mailed or attached as is.
+
+// HTMLWriter is the "html" sink. It collects matches and renders the
+// report on Close.
+type HTMLWriter struct {
+	target   fileTarget
+	rules    *scanner.RuleSet
+	version  string
+	findings []htmlFinding
+}
+
+// NewHTMLWriter returns a sink writing the report to w
+func NewHTMLWriter(w io.Writer) *HTMLWriter {
+	return &HTMLWriter{target: fileTarget{w: w}}
+}
+
+func (hw *HTMLWriter) Open(env Env) error {
+	if env.Resumable {
+		return errNotResumable
+	}
+	hw.rules = env.Rules
+	if hw.rules == nil {
+		hw.rules = scanner.NewRuleSet(nil)
+	}
+	hw.version = env.ToolVersion
+	return hw.target.open(env)
+}
+
+type htmlFinding struct {
+	Rule     *scanner.Rule
+	URL      string
+	Host     string
+	URLParts []textPart // URL with the whole-URL occurrences marked
+	Values   []string   // Rendered occurrences
+	JWTs     []string   // Analysis of the JWTs among them
+	Score    int
+}
+
+// textPart is a run of text, highlighted or not
+type textPart struct {
+	Text string
+	Mark bool
+}
+
+func (hw *HTMLWriter) Write(m scanner.Match) error {
+	hw.findings = append(hw.findings, htmlFinding{
+		Rule:     m.Rule,
+		URL:      m.URL,
+		Host:     hostOf(m.URL),
+		URLParts: markURL(m.URL, m.Occurrences),
+		Values:   m.Values(),
+		JWTs:     jwtSummaries(m),
+		Score:    m.Score(),
+	})
+	return nil
+}
+
+func jwtSummaries(m scanner.Match) []string {
+	var summaries []string
+	for _, occ := range m.Occurrences {
+		if occ.JWT != nil {
+			summaries = append(summaries, occ.JWT.String())
+		}
+	}
+	return summaries
+}
+
+func hostOf(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return "(no host)"
+}
+
+// markURL splits url into parts, marking the spans matched on the whole URL
+// (occurrences in other components or decoded views carry offsets into
+// that text, so they are only highlighted in the occurrence list)
+func markURL(url string, occurrences []scanner.Occurrence) []textPart {
+	marked := make([]bool, len(url))
+	for _, occ := range occurrences {
+		if occ.Component != scanner.ComponentURL && occ.Component != "" || len(occ.Decoding) > 0 {
+			continue
+		}
+		for i := occ.Start; i < occ.End && i < len(url); i++ {
+			marked[i] = true
+		}
+	}
+	var parts []textPart
+	start := 0
+	for i := 1; i <= len(url); i++ {
+		if i == len(url) || marked[i] != marked[start] {
+			parts = append(parts, textPart{Text: url[start:i], Mark: marked[start]})
+			start = i
+		}
+	}
+	return parts
+}
+
+type htmlFileRow struct {
+	File         string
+	Rules        int
+	RulesMatched int
+	Findings     int
+	URLs         int
+}
+
+type htmlHostRow struct {
+	Host        string
+	URLs        int
+	Findings    int
+	MaxSeverity scanner.Severity
+}
+
+type htmlSeverityCount struct {
+	Severity scanner.Severity
+	Count    int
+}
+
+type htmlData struct {
+	Version     string
+	Generated   time.Time
+	Summary     Summary
+	Duration    time.Duration
+	Severities  []htmlSeverityCount
+	Categories  []string
+	Hosts       []htmlHostRow
+	Files       []htmlFileRow
+	Findings    []htmlFinding
+	RulesLoaded int
+}
+
+// Close renders the report
+func (hw *HTMLWriter) Close(summary Summary) error {
+	data := htmlData{
+		Version:     hw.version,
+		Generated:   time.Now(),
+		Summary:     summary,
+		Findings:    hw.findings,
+		RulesLoaded: hw.rules.Len(),
+	}
+	if !summary.Started.IsZero() && !summary.Finished.IsZero() {
+		data.Duration = summary.Finished.Sub(summary.Started).Truncate(time.Millisecond)
+	}
+	// Counted from the findings rather than the scan totals, which include
+	// matches a filtered sink never saw
+	var bySeverity [scanner.SeverityCritical + 1]int
+	for _, f := range hw.findings {
+		bySeverity[f.Rule.Severity]++
+	}
+	for sev := scanner.SeverityCritical; sev >= scanner.SeverityInfo; sev-- {
+		data.Severities = append(data.Severities, htmlSeverityCount{sev, bySeverity[sev]})
+	}
+
+	files := make(map[string]*htmlFileRow)
+	var fileOrder []string
+	for _, r := range hw.rules.Rules {
+		row, ok := files[r.SourceFile]
+		if !ok {
+			row = &htmlFileRow{File: r.SourceFile}
+			files[r.SourceFile] = row
+			fileOrder = append(fileOrder, r.SourceFile)
+		}
+		row.Rules++
+	}
+	hosts := make(map[string]*htmlHostRow)
+	categories := make(map[string]bool)
+	matchedRules := make(map[*scanner.Rule]bool)
+	fileURLs := make(map[string]bool) // "file\x00url"
+	hostURLs := make(map[string]bool)
+	for _, f := range hw.findings {
+		file := files[f.Rule.SourceFile]
+		if file == nil { // Rule from outside the rule set
+			file = &htmlFileRow{File: f.Rule.SourceFile}
+			files[f.Rule.SourceFile] = file
+			fileOrder = append(fileOrder, f.Rule.SourceFile)
+		}
+		file.Findings++
+		if !matchedRules[f.Rule] {
+			matchedRules[f.Rule] = true
+			file.RulesMatched++
+		}
+		if key := f.Rule.SourceFile + "\x00" + f.URL; !fileURLs[key] {
+			fileURLs[key] = true
+			file.URLs++
+		}
+
+		host := hosts[f.Host]
+		if host == nil {
+			host = &htmlHostRow{Host: f.Host}
+			hosts[f.Host] = host
+		}
+		host.Findings++
+		if f.Rule.Severity > host.MaxSeverity {
+			host.MaxSeverity = f.Rule.Severity
+		}
+		if !hostURLs[f.URL] {
+			hostURLs[f.URL] = true
+			host.URLs++
+		}
+		categories[f.Rule.Category] = true
+	}
+
+	for _, name := range fileOrder {
+		data.Files = append(data.Files, *files[name])
+	}
+	for _, host := range hosts {
+		data.Hosts = append(data.Hosts, *host)
+	}
+	sort.Slice(data.Hosts, func(i, j int) bool {
+		a, b := data.Hosts[i], data.Hosts[j]
+		if a.MaxSeverity != b.MaxSeverity {
+			return a.MaxSeverity > b.MaxSeverity
+		}
+		if a.Findings != b.Findings {
+			return a.Findings > b.Findings
+		}
+		return a.Host < b.Host
+	})
+	for category := range categories {
+		data.Categories = append(data.Categories, category)
+	}
+	sort.Strings(data.Categories)
+
+	err := htmlTemplate.Execute(hw.target.w, data)
+	if closeErr := hw.target.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ts": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+	"join": strings.Join,
+}).Parse(htmlReportTemplate))
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>CodeHunter Report</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 0; color: #1d2330; background: #f4f5f8; }
+header { background: #1d2330; color: #fff; padding: 18px 32px; }
+header h1 { margin: 0; font-size: 22px; }
+header p { margin: 4px 0 0; color: #b8bfcc; }
+main { padding: 24px 32px; }
+section { background: #fff; border: 1px solid #dde1e8; border-radius: 6px; padding: 16px 20px; margin-bottom: 20px; }
+h2 { font-size: 17px; margin: 0 0 12px; }
+.cards { display: flex; flex-wrap: wrap; gap: 12px; }
+.card { border: 1px solid #dde1e8; border-radius: 6px; padding: 10px 16px; min-width: 120px; }
+.card b { display: block; font-size: 22px; }
+.card span { color: #5b6475; font-size: 12px; text-transform: uppercase; }
+.warn { background: #fff4d6; border: 1px solid #f0c960; border-radius: 6px; padding: 8px 12px; margin-bottom: 12px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 6px 8px; border-bottom: 1px solid #e8ebf0; vertical-align: top; }
+th { background: #f0f2f6; font-weight: 600; white-space: nowrap; }
+th.sortable { cursor: pointer; user-select: none; }
+th.sortable:after { content: " \2195"; color: #9aa3b2; }
+td.num, th.num { text-align: right; }
+.url { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 12px; word-break: break-all; }
+.values { margin: 0; padding-left: 16px; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 12px; word-break: break-all; }
+mark { background: #ffe08a; padding: 0 1px; }
+.sev { display: inline-block; border-radius: 3px; padding: 1px 6px; font-size: 12px; font-weight: 600; color: #fff; }
+.sev-critical { background: #8e1b1b; } .sev-high { background: #d9480f; } .sev-medium { background: #e0a100; }
+.sev-low { background: #3b7dd8; } .sev-info { background: #7a8394; }
+.muted { color: #5b6475; }
+.filters { display: flex; flex-wrap: wrap; gap: 10px; margin-bottom: 12px; }
+.filters input, .filters select { font: inherit; padding: 4px 8px; border: 1px solid #c5cbd6; border-radius: 4px; }
+.filters input { min-width: 280px; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 4px 16px; margin: 0; }
+dt { color: #5b6475; } dd { margin: 0; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 12px; word-break: break-all; }
+</style>
+</head>
+<body>
+<header>
+<h1>🏴‍☠️ CodeHunter Report</h1>
+<p>CodeHunter {{.Version}} · generated {{ts .Generated}}</p>
+</header>
+<main>
+
+<section>
+<h2>Summary</h2>
+{{if .Summary.Interrupted}}<div class="warn">The scan was interrupted: these are partial results.</div>{{end}}
+<div class="cards">
+<div class="card"><b>{{.Summary.Stats.URLsProcessed}}</b><span>URLs processed</span></div>
+<div class="card"><b>{{.Summary.Stats.URLsMatched}}</b><span>URLs matched</span></div>
+<div class="card"><b>{{len .Findings}}</b><span>Findings</span></div>
+{{range .Severities}}<div class="card"><b>{{.Count}}</b><span class="sev sev-{{.Severity}}">{{.Severity}}</span></div>
+{{end}}<div class="card"><b>{{len .Hosts}}</b><span>Hosts</span></div>
+<div class="card"><b>{{.RulesLoaded}}</b><span>Patterns loaded</span></div>
+{{if .Summary.Stats.URLsFetched}}<div class="card"><b>{{.Summary.Stats.URLsFetched}}</b><span>Fetched ({{.Summary.Stats.FetchErrors}} errors)</span></div>
+{{end}}</div>
+<p class="muted">Started {{ts .Summary.Started}} · finished {{ts .Summary.Finished}}{{if .Duration}} · {{.Duration}}{{end}}</p>
+</section>
+
+<section>
+<h2>By Pattern File</h2>
+<table>
+<thead><tr><th>Pattern file</th><th class="num">Patterns</th><th class="num">Patterns matched</th><th class="num">URLs</th><th class="num">Findings</th></tr></thead>
+<tbody>
+{{range .Files}}<tr><td>{{.File}}</td><td class="num">{{.Rules}}</td><td class="num">{{.RulesMatched}}</td><td class="num">{{.URLs}}</td><td class="num">{{.Findings}}</td></tr>
+{{end}}</tbody>
+</table>
+</section>
+
+<section>
+<h2>By Host</h2>
+{{if .Hosts}}<table>
+<thead><tr><th>Host</th><th>Highest severity</th><th class="num">URLs</th><th class="num">Findings</th></tr></thead>
+<tbody>
+{{range .Hosts}}<tr><td class="url">{{.Host}}</td><td><span class="sev sev-{{.MaxSeverity}}">{{.MaxSeverity}}</span></td><td class="num">{{.URLs}}</td><td class="num">{{.Findings}}</td></tr>
+{{end}}</tbody>
+</table>{{else}}<p class="muted">No findings.</p>{{end}}
+</section>
+
+<section>
+<h2>Findings</h2>
+{{if .Findings}}<div class="filters">
+<input id="q" type="search" placeholder="Filter by URL, pattern, value...">
+<select id="sev"><option value="0">Any severity</option><option value="1">Low and above</option><option value="2">Medium and above</option><option value="3">High and above</option><option value="4">Critical only</option></select>
+<select id="cat"><option value="">Any category</option>{{range .Categories}}<option>{{.}}</option>{{end}}</select>
+<span id="count" class="muted"></span>
+</div>
+<table id="findings">
+<thead><tr><th class="sortable" data-type="num">Severity</th><th class="sortable" data-type="num">Confidence</th><th class="sortable">Pattern</th><th class="sortable">Category</th><th class="sortable">URL</th><th>Occurrences</th><th class="sortable">Source</th></tr></thead>
+<tbody>
+{{range .Findings}}<tr data-sev="{{printf "%d" .Rule.Severity}}" data-cat="{{.Rule.Category}}">
+<td data-sort="{{printf "%d" .Rule.Severity}}"><span class="sev sev-{{.Rule.Severity}}">{{.Rule.Severity}}</span></td>
+<td data-sort="{{printf "%d" .Score}}">{{.Score}}<br><span class="muted">{{.Rule.Confidence}}</span></td>
+<td><b>{{.Rule.ID}}</b>{{if .Rule.Name}}<br>{{.Rule.Name}}{{end}}<br><span class="url muted">{{.Rule.Regex}}</span></td>
+<td>{{.Rule.Category}}{{if .Rule.Tags}}<br><span class="muted">{{join .Rule.Tags ", "}}</span>{{end}}</td>
+<td class="url">{{range .URLParts}}{{if .Mark}}<mark>{{.Text}}</mark>{{else}}{{.Text}}{{end}}{{end}}</td>
+<td><ul class="values">{{range .Values}}<li><mark>{{.}}</mark></li>{{end}}</ul>{{range .JWTs}}<div class="muted">{{.}}</div>{{end}}</td>
+<td class="muted">{{.Rule.SourceFile}}:{{.Rule.Line}}</td>
+</tr>
+{{end}}</tbody>
+</table>{{else}}<p class="muted">No findings.</p>{{end}}
+</section>
+
+<section>
+<h2>Scan Configuration</h2>
+<dl>
+{{range .Summary.Settings}}<dt>{{.Name}}</dt><dd>{{.Value}}</dd>
+{{end}}</dl>
+</section>
+
+</main>
+<script>
+(function () {
+  var table = document.getElementById("findings");
+  if (!table) return;
+  var body = table.tBodies[0];
+  var rows = Array.prototype.slice.call(body.rows);
+  var q = document.getElementById("q"), sev = document.getElementById("sev"), cat = document.getElementById("cat");
+  var count = document.getElementById("count");
+
+  function filter() {
+    var text = q.value.toLowerCase(), minSev = +sev.value, category = cat.value, shown = 0;
+    rows.forEach(function (row) {
+      var ok = +row.dataset.sev >= minSev && (!category || row.dataset.cat === category) &&
+        (!text || row.textContent.toLowerCase().indexOf(text) >= 0);
+      row.style.display = ok ? "" : "none";
+      if (ok) shown++;
+    });
+    count.textContent = shown + " of " + rows.length + " findings";
+  }
+  [q, sev, cat].forEach(function (el) { el.addEventListener("input", filter); });
+
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, col) {
+    if (!th.classList.contains("sortable")) return;
+    var ascending = false;
+    th.addEventListener("click", function () {
+      ascending = !ascending;
+      var numeric = th.dataset.type === "num";
+      rows.sort(function (a, b) {
+        var x = a.cells[col].dataset.sort || a.cells[col].textContent;
+        var y = b.cells[col].dataset.sort || b.cells[col].textContent;
+        var order = numeric ? x - y : x.localeCompare(y);
+        return ascending ? order : -order;
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+  filter();
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// JSON LINES
+// ==============================================
+
+// Record is the stable, documented shape of one JSON Lines match, also sent
+// by the webhook sink
+type Record struct {
+	URL         string               `json:"url"`
+	RuleID      string               `json:"rule_id"`
+	Name        string               `json:"name,omitempty"`
+	Pattern     string               `json:"pattern"`
+	SourceFile  string               `json:"source_file"`
+	Line        int                  `json:"line"`
+	Severity    scanner.Severity     `json:"severity"`
+	Confidence  scanner.Confidence   `json:"confidence"`
+	Score       int                  `json:"score"` // Highest occurrence score
+	Category    string               `json:"category"`
+	Tags        []string             `json:"tags,omitempty"`
+	References  []string             `json:"references,omitempty"`
+	Count       int                  `json:"count"`
+	Occurrences []scanner.Occurrence `json:"occurrences"`
+	WorkerID    int                  `json:"worker_id"`
+	Timestamp   time.Time            `json:"timestamp"`
+}
+
+func NewRecord(m scanner.Match) Record {
+	return Record{
+		URL:         m.URL,
+		RuleID:      m.Rule.ID,
+		Name:        m.Rule.Name,
+		Pattern:     m.Rule.Regex,
+		SourceFile:  m.Rule.SourceFile,
+		Line:        m.Rule.Line,
+		Severity:    m.Rule.Severity,
+		Confidence:  m.Rule.Confidence,
+		Score:       m.Score(),
+		Category:    m.Rule.Category,
+		Tags:        m.Rule.Tags,
+		References:  m.Rule.References,
+		Count:       len(m.Occurrences),
+		Occurrences: m.Occurrences,
+		WorkerID:    m.WorkerID,
+		Timestamp:   m.Timestamp,
+	}
+}
+
+// JSONLWriter is the "jsonl" sink
+type JSONLWriter struct {
+	target  fileTarget
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter returns a sink writing one Record per line to w
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{target: fileTarget{w: w}}
+}
+
+func (jw *JSONLWriter) Open(env Env) error {
+	if err := jw.target.open(env); err != nil {
+		return err
+	}
+	jw.encoder = json.NewEncoder(jw.target.w)
+	jw.encoder.SetEscapeHTML(false) // Keep URLs readable (no \u0026 for '&')
+	return nil
+}
+
+func (jw *JSONLWriter) Write(m scanner.Match) error { return jw.encoder.Encode(NewRecord(m)) }
+
+func (jw *JSONLWriter) Close(Summary) error { return jw.target.close() }
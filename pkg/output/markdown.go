@@ -0,0 +1,205 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// MARKDOWN REPORT
+// ==============================================
+// Findings grouped by host, then by rule category, most severe first, as
+// nested lists that paste cleanly into a HackerOne or Bugcrowd submission.
+// Values are written as code spans so URLs and secrets are shown verbatim.
+
+// MarkdownWriter is the "markdown" sink. It collects matches and writes
+// the report on Close.
+type MarkdownWriter struct {
+	target   fileTarget
+	version  string
+	findings []mdFinding
+}
+
+type mdFinding struct {
+	host  string
+	match scanner.Match
+}
+
+// NewMarkdownWriter returns a sink writing the report to w
+func NewMarkdownWriter(w io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{target: fileTarget{w: w}}
+}
+
+func (mw *MarkdownWriter) Open(env Env) error {
+	if env.Resumable {
+		return errNotResumable
+	}
+	mw.version = env.ToolVersion
+	return mw.target.open(env)
+}
+
+func (mw *MarkdownWriter) Write(m scanner.Match) error {
+	mw.findings = append(mw.findings, mdFinding{host: hostOf(m.URL), match: m})
+	return nil
+}
+
+type mdGroup struct {
+	name        string
+	maxSeverity scanner.Severity
+	findings    []mdFinding
+}
+
+// groupFindings groups findings by key, most severe group first, keeping
+// the findings of each group in the order given
+func groupFindings(findings []mdFinding, key func(mdFinding) string) []*mdGroup {
+	index := make(map[string]*mdGroup)
+	var groups []*mdGroup
+	for _, f := range findings {
+		name := key(f)
+		group := index[name]
+		if group == nil {
+			group = &mdGroup{name: name}
+			index[name] = group
+			groups = append(groups, group)
+		}
+		group.findings = append(group.findings, f)
+		if f.match.Rule.Severity > group.maxSeverity {
+			group.maxSeverity = f.match.Rule.Severity
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].maxSeverity != groups[j].maxSeverity {
+			return groups[i].maxSeverity > groups[j].maxSeverity
+		}
+		if len(groups[i].findings) != len(groups[j].findings) {
+			return len(groups[i].findings) > len(groups[j].findings)
+		}
+		return groups[i].name < groups[j].name
+	})
+	return groups
+}
+
+// Close writes the report
+func (mw *MarkdownWriter) Close(summary Summary) error {
+	out := bufio.NewWriter(mw.target.w)
+	findings := append([]mdFinding(nil), mw.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].match.Rule.Severity > findings[j].match.Rule.Severity
+	})
+	hosts := groupFindings(findings, func(f mdFinding) string { return f.host })
+
+	fmt.Fprintf(out, "# CodeHunter Findings\n\n")
+	fmt.Fprintf(out, "Generated %s by CodeHunter %s.\n\n", time.Now().Format("2006-01-02 15:04 MST"), mw.version)
+	if summary.Interrupted {
+		fmt.Fprintf(out, "> **Note:** the scan was interrupted; these are partial results.\n\n")
+	}
+
+	var bySeverity [scanner.SeverityCritical + 1]int
+	urls := make(map[string]bool)
+	for _, f := range findings {
+		bySeverity[f.match.Rule.Severity]++
+		urls[f.match.URL] = true
+	}
+	fmt.Fprintf(out, "## Summary\n\n")
+	if summary.Stats.URLsProcessed > 0 {
+		fmt.Fprintf(out, "- URLs scanned: %d\n", summary.Stats.URLsProcessed)
+	}
+	fmt.Fprintf(out, "- URLs with findings: %d\n", len(urls))
+	fmt.Fprintf(out, "- Hosts: %d\n", len(hosts))
+	fmt.Fprintf(out, "- Findings: %d\n\n", len(findings))
+	if len(findings) > 0 {
+		fmt.Fprintf(out, "| Severity | Findings |\n|---|---:|\n")
+		for sev := scanner.SeverityCritical; sev >= scanner.SeverityInfo; sev-- {
+			if bySeverity[sev] > 0 {
+				fmt.Fprintf(out, "| %s | %d |\n", severityTitle(sev), bySeverity[sev])
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	for _, host := range hosts {
+		fmt.Fprintf(out, "## %s\n\n", escapeMarkdown(host.name))
+		for _, category := range groupFindings(host.findings, func(f mdFinding) string { return f.match.Rule.Category }) {
+			name := category.name
+			if name == "" {
+				name = "uncategorized"
+			}
+			fmt.Fprintf(out, "### %s (%d)\n\n", escapeMarkdown(name), len(category.findings))
+			for _, f := range category.findings {
+				writeMarkdownFinding(out, f.match)
+			}
+		}
+	}
+	err := out.Flush()
+	if closeErr := mw.target.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeMarkdownFinding(out io.Writer, m scanner.Match) {
+	rule := m.Rule
+	title := codeSpan(rule.ID)
+	if rule.Name != "" {
+		title += " " + escapeMarkdown(rule.Name)
+	}
+	fmt.Fprintf(out, "- **%s** %s (confidence: %s, score %d)\n", severityTitle(rule.Severity), title, rule.Confidence, m.Score())
+	fmt.Fprintf(out, "  - URL: %s\n", codeSpan(m.URL))
+	values := describedValues(m)
+	if len(values) == 1 {
+		fmt.Fprintf(out, "  - Match: %s\n", codeSpan(values[0]))
+	} else {
+		fmt.Fprintf(out, "  - Matches:\n")
+		for _, value := range values {
+			fmt.Fprintf(out, "    - %s\n", codeSpan(value))
+		}
+	}
+	fmt.Fprintf(out, "  - Pattern: %s (%s:%d)\n", codeSpan(rule.Regex), escapeMarkdown(rule.SourceFile), rule.Line)
+	for _, ref := range rule.References {
+		fmt.Fprintf(out, "  - Reference: %s\n", ref)
+	}
+	fmt.Fprintln(out)
+}
+
+func severityTitle(s scanner.Severity) string {
+	name := s.String()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// codeSpan wraps s in enough backticks that backticks inside it survive,
+// and flattens newlines, which would end the list item
+func codeSpan(s string) string {
+	s = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "\n", " ",
+)
+
+// escapeMarkdown makes s literal text in a heading or list item
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,323 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// SARIF 2.1.0
+// ==============================================
+// The whole SARIF log is one JSON document, so results are collected in
+// memory and written by Close. Each loaded rule becomes a reportingDescriptor
+// and each match a result whose locations are its occurrences, all pointing
+// at the scanned URL.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json"
+	toolURI      = "https://github.com/Acorzo1983/Codehunter"
+)
+
+// SARIFWriter is the "sarif" sink. It collects matches and writes them as
+// a SARIF log on Close.
+type SARIFWriter struct {
+	target    fileTarget
+	version   string
+	rules     *scanner.RuleSet
+	ruleIndex map[*scanner.Rule]int
+	results   []sarifResult
+}
+
+// NewSARIFWriter returns a sink writing the SARIF log to w
+func NewSARIFWriter(w io.Writer) *SARIFWriter {
+	return &SARIFWriter{target: fileTarget{w: w}}
+}
+
+func (sw *SARIFWriter) Open(env Env) error {
+	if env.Rules == nil {
+		return errors.New("sarif: no rule set")
+	}
+	if env.Resumable {
+		return errNotResumable
+	}
+	sw.version = env.ToolVersion
+	sw.rules = env.Rules
+	sw.ruleIndex = make(map[*scanner.Rule]int, env.Rules.Len())
+	for i := range env.Rules.Rules {
+		sw.ruleIndex[&env.Rules.Rules[i]] = i
+	}
+	sw.results = []sarifResult{} // "results": [] rather than null when nothing matched
+	return sw.target.open(env)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	ColumnKind  string            `json:"columnKind"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc,omitempty"`
+	EndTimeUTC          string `json:"endTimeUtc,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name,omitempty"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	HelpURI              string             `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifRuleProps     `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProps struct {
+	Regex      string              `json:"regex"`
+	SourceFile string              `json:"sourceFile"`
+	Line       int                 `json:"line"`
+	Severity   scanner.Severity    `json:"severity"`
+	Confidence scanner.Confidence  `json:"confidence"`
+	Category   string              `json:"category,omitempty"`
+	Tags       []string            `json:"tags,omitempty"`
+	Scope      []scanner.Component `json:"scope,omitempty"`
+	// GitHub code scanning ranks security alerts by this 0.0-10.0 score
+	SecuritySeverity string `json:"security-severity"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Rank                float64           `json:"rank"` // The confidence score, 0-100
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+// sarifLevel maps rule severity to the three SARIF result levels
+func sarifLevel(s scanner.Severity) string {
+	switch {
+	case s >= scanner.SeverityHigh:
+		return "error"
+	case s == scanner.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+var securitySeverity = map[scanner.Severity]string{
+	scanner.SeverityInfo:     "0.0",
+	scanner.SeverityLow:      "3.0",
+	scanner.SeverityMedium:   "5.0",
+	scanner.SeverityHigh:     "7.5",
+	scanner.SeverityCritical: "9.5",
+}
+
+func (sw *SARIFWriter) Write(m scanner.Match) error {
+	index, ok := sw.ruleIndex[m.Rule]
+	if !ok {
+		return fmt.Errorf("sarif: rule '%s' is not in the rule set", m.Rule.ID)
+	}
+	result := sarifResult{
+		RuleID:    m.Rule.ID,
+		RuleIndex: index,
+		Level:     sarifLevel(m.Rule.Severity),
+		Rank:      float64(m.Score()),
+		Message: sarifMessage{Text: fmt.Sprintf("%s matched %s: %s",
+			m.Rule.DisplayName(), m.URL, strings.Join(describedValues(m), ", "))},
+		PartialFingerprints: map[string]string{"codehunterFinding/v1": fingerprint(m)},
+	}
+	for _, occ := range m.Occurrences {
+		result.Locations = append(result.Locations, sarifOccurrence(m.URL, occ))
+	}
+	sw.results = append(sw.results, result)
+	return nil
+}
+
+// sarifOccurrence locates one occurrence. URLs are single-line artifacts, so
+// URL matches become columns on line 1 and body matches keep their line and
+// column. Columns count code points (the run's columnKind). Values from decoded URL components or headers that cannot be
+// found verbatim in the URL get no region, only a message naming them.
+func sarifOccurrence(uri string, occ scanner.Occurrence) sarifLocation {
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: artifactURI(uri)},
+	}}
+	snippet := &sarifMessage{Text: occ.Value}
+	start := -1
+	switch {
+	case occ.Line > 0:
+		region := &sarifRegion{StartLine: occ.Line, StartColumn: occ.Column, Snippet: snippet}
+		if !strings.Contains(occ.Value, "\n") {
+			region.EndColumn = occ.Column + utf8.RuneCountInString(occ.Value)
+		}
+		loc.PhysicalLocation.Region = region
+		return loc
+	case (occ.Component == scanner.ComponentURL || occ.Component == "") && len(occ.Decoding) == 0:
+		start = occ.Start
+	case occ.Component != scanner.ComponentHeader && occ.Value != "":
+		start = strings.Index(uri, occ.Value)
+	}
+	if start >= 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   1,
+			StartColumn: utf8.RuneCountInString(uri[:start]) + 1,
+			EndColumn:   utf8.RuneCountInString(uri[:start+len(occ.Value)]) + 1,
+			Snippet:     snippet,
+		}
+		return loc
+	}
+	text := fmt.Sprintf("%s in %s", occ.Value, occ.Component)
+	if occ.Key != "" {
+		text = fmt.Sprintf("%s in %s '%s'", occ.Value, occ.Component, occ.Key)
+	}
+	if len(occ.Decoding) > 0 {
+		text += fmt.Sprintf(", decoded by %s", scanner.DecodingChain(occ.Decoding))
+	}
+	loc.Message = &sarifMessage{Text: text}
+	return loc
+}
+
+// artifactURI makes sure the location is a valid URI reference; scanned
+// lines are not always well-formed URLs
+func artifactURI(raw string) string {
+	if _, err := url.Parse(raw); err == nil {
+		return raw
+	}
+	return url.PathEscape(raw)
+}
+
+// fingerprint identifies a finding across scans so dashboards can track it
+func fingerprint(m scanner.Match) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", m.Rule.ID, m.URL, strings.Join(m.Values(), "\x00"))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// Close writes the SARIF log. An interrupted scan is reported as an
+// unsuccessful invocation.
+func (sw *SARIFWriter) Close(summary Summary) error {
+	driver := sarifDriver{
+		Name:           "CodeHunter",
+		Version:        sw.version,
+		InformationURI: toolURI,
+		Rules:          make([]sarifRule, len(sw.rules.Rules)),
+	}
+	for i, r := range sw.rules.Rules {
+		rule := sarifRule{
+			ID:                   r.ID,
+			Name:                 r.Name,
+			ShortDescription:     sarifMessage{Text: r.DisplayName()},
+			FullDescription:      sarifMessage{Text: fmt.Sprintf("Pattern %s from %s:%d", r.Regex, r.SourceFile, r.Line)},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(r.Severity)},
+			Properties: sarifRuleProps{
+				Regex:            r.Regex,
+				SourceFile:       r.SourceFile,
+				Line:             r.Line,
+				Severity:         r.Severity,
+				Confidence:       r.Confidence,
+				Category:         r.Category,
+				Tags:             r.Tags,
+				Scope:            r.Scope,
+				SecuritySeverity: securitySeverity[r.Severity],
+			},
+		}
+		for _, ref := range r.References {
+			if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+				rule.HelpURI = ref
+				break
+			}
+		}
+		driver.Rules[i] = rule
+	}
+
+	invocation := sarifInvocation{
+		ExecutionSuccessful: !summary.Interrupted,
+		StartTimeUTC:        sarifTime(summary.Started),
+		EndTimeUTC:          sarifTime(summary.Finished),
+	}
+
+	encoder := json.NewEncoder(sw.target.w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:        sarifTool{Driver: driver},
+			ColumnKind:  "unicodeCodePoints",
+			Invocations: []sarifInvocation{invocation},
+			Results:     sw.results,
+		}},
+	})
+	if closeErr := sw.target.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func sarifTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
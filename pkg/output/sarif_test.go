@@ -0,0 +1,173 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// testRules builds a rule set from ID/regex pairs
+func testRules(pairs ...string) *scanner.RuleSet {
+	var rules []scanner.Rule
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rules = append(rules, scanner.Rule{
+			ID:         pairs[i],
+			Regex:      pairs[i+1],
+			Compiled:   regexp.MustCompile(pairs[i+1]),
+			SourceFile: "test.txt",
+			Line:       i/2 + 1,
+			Severity:   scanner.SeverityHigh,
+			Confidence: scanner.ConfidenceHigh,
+		})
+	}
+	return scanner.NewRuleSet(rules)
+}
+
+// scanMatches scans one URL the way a scan would
+func scanMatches(t *testing.T, rules *scanner.RuleSet, url string) []scanner.Match {
+	t.Helper()
+	s, err := scanner.New(scanner.Options{Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	return s.ScanURL(context.Background(), url).Matches
+}
+
+// sarifObject returns obj[key] as an object, failing if it is missing
+func sarifObject(t *testing.T, obj map[string]any, path, key string) map[string]any {
+	t.Helper()
+	value, ok := obj[key].(map[string]any)
+	if !ok {
+		t.Fatalf("%s: required object '%s' missing in %v", path, key, obj)
+	}
+	return value
+}
+
+func sarifArray(t *testing.T, obj map[string]any, path, key string) []any {
+	t.Helper()
+	value, ok := obj[key].([]any)
+	if !ok {
+		t.Fatalf("%s: required array '%s' missing in %v", path, key, obj)
+	}
+	return value
+}
+
+func TestSARIFLog(t *testing.T) {
+	rules := testRules("aws-key", `AKIA[0-9A-Z]{16}`, "password", `pässwörd=\w+`)
+	url := "https://exämple.com/?k=AKIA0123456789ABCDEF"
+	matches := scanMatches(t, rules, url)
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	matches = append(matches, scanner.Match{
+		URL:  "https://example.com/app.js",
+		Rule: &rules.Rules[1],
+		Occurrences: []scanner.Occurrence{{
+			Value: "pässwörd=hunter2", Component: scanner.ComponentBody, Line: 3, Column: 5, Score: 80,
+		}},
+	})
+
+	var buf bytes.Buffer
+	sw := NewSARIFWriter(&buf)
+	if err := sw.Open(Env{Rules: rules, ToolVersion: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range matches {
+		if err := sw.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	if err := sw.Close(Summary{Started: now, Finished: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The properties and constraints the SARIF 2.1.0 schema puts on what
+	// the writer emits
+	var log map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if log["version"] != "2.1.0" || log["$schema"] != sarifSchema {
+		t.Errorf("version %v, $schema %v", log["version"], log["$schema"])
+	}
+	runs := sarifArray(t, log, "log", "runs")
+	if len(runs) != 1 {
+		t.Fatalf("%d runs, want 1", len(runs))
+	}
+	run := runs[0].(map[string]any)
+	if kind := run["columnKind"]; kind != "unicodeCodePoints" && kind != "utf16CodeUnits" {
+		t.Errorf("columnKind %v", kind)
+	}
+	driver := sarifObject(t, sarifObject(t, run, "run", "tool"), "tool", "driver")
+	if name, _ := driver["name"].(string); name == "" {
+		t.Error("tool.driver.name is required")
+	}
+	descriptors := sarifArray(t, driver, "driver", "rules")
+	if len(descriptors) != rules.Len() {
+		t.Fatalf("%d rule descriptors, want %d", len(descriptors), rules.Len())
+	}
+	for _, inv := range sarifArray(t, run, "run", "invocations") {
+		if _, ok := inv.(map[string]any)["executionSuccessful"].(bool); !ok {
+			t.Error("invocation.executionSuccessful is required")
+		}
+	}
+
+	results := sarifArray(t, run, "run", "results")
+	if len(results) != len(matches) {
+		t.Fatalf("%d results, want %d", len(results), len(matches))
+	}
+	var regions []map[string]any
+	for i, r := range results {
+		result := r.(map[string]any)
+		if text, _ := sarifObject(t, result, "result", "message")["text"].(string); text == "" {
+			t.Errorf("result %d: message.text is required", i)
+		}
+		switch result["level"] {
+		case "none", "note", "warning", "error":
+		default:
+			t.Errorf("result %d: level %v", i, result["level"])
+		}
+		if rank, _ := result["rank"].(float64); rank < -1 || rank > 100 {
+			t.Errorf("result %d: rank %v outside -1..100", i, rank)
+		}
+		index := int(result["ruleIndex"].(float64))
+		if index < 0 || index >= len(descriptors) || descriptors[index].(map[string]any)["id"] != result["ruleId"] {
+			t.Errorf("result %d: ruleIndex %d does not point at rule '%v'", i, index, result["ruleId"])
+		}
+		for _, l := range sarifArray(t, result, "result", "locations") {
+			physical := sarifObject(t, l.(map[string]any), "location", "physicalLocation")
+			if uri, _ := sarifObject(t, physical, "physicalLocation", "artifactLocation")["uri"].(string); uri == "" {
+				t.Errorf("result %d: artifactLocation.uri missing", i)
+			}
+			region := sarifObject(t, physical, "physicalLocation", "region")
+			start, end := region["startColumn"].(float64), region["endColumn"].(float64)
+			if region["startLine"].(float64) < 1 || start < 1 || end < start {
+				t.Errorf("result %d: invalid region %v", i, region)
+			}
+			regions = append(regions, region)
+		}
+	}
+
+	// "exämple" is one column shorter than it is long in bytes
+	for i, want := range [][3]float64{{1, 24, 44}, {3, 5, 21}} {
+		got := [3]float64{regions[i]["startLine"].(float64), regions[i]["startColumn"].(float64), regions[i]["endColumn"].(float64)}
+		if got != want {
+			t.Errorf("region %d: line, start and end column %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSARIFNotResumable(t *testing.T) {
+	sw := NewSARIFWriter(&bytes.Buffer{})
+	if err := sw.Open(Env{Rules: testRules("r", "x"), Resumable: true}); !errors.Is(err, errNotResumable) {
+		t.Errorf("Open with Resumable: %v, want errNotResumable", err)
+	}
+}
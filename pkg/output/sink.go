@@ -0,0 +1,305 @@
+// Package output delivers scanner matches to sinks: match logs and reports
+// in several formats, stdout and webhooks. Sinks are looked up by type name
+// in a registry, which other packages can extend:
+//
+//	output.Register(output.SinkType{
+//		Name:        "syslog",
+//		Description: "Matches sent to syslog",
+//		New:         func(target string) (output.Sink, error) { return newSyslogSink(target) },
+//	})
+//
+//	sink, err := output.Open("jsonl,min-severity=high:hits.jsonl", output.Env{Rules: rules})
+//	...
+//	for match := range s.Scan(ctx, urls) {
+//		sink.Write(match)
+//	}
+//	sink.Close(output.Summary{Stats: s.Stats()})
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// SINKS
+// ==============================================
+
+// Sink receives the matches of one scan. Open is called once before the
+// first Write and Close once after the last, with the totals of the scan.
+// Calls are never concurrent.
+type Sink interface {
+	Open(env Env) error
+	Write(m scanner.Match) error
+	Close(summary Summary) error
+}
+
+// Env is what a sink gets to know about the scan when it is opened
+type Env struct {
+	Rules       *scanner.RuleSet
+	ToolVersion string
+
+	// Resumable is set when the scan checkpoints its outputs (--resume).
+	// Sinks that cannot append to what an earlier run wrote fail Open.
+	Resumable bool
+
+	// OpenFile opens a sink's output file; nil means os.Create. appending
+	// reports that the file holds earlier output that must be kept. Files
+	// opened this way may be synced and measured between Writes, so sinks
+	// must not buffer across Writes.
+	OpenFile func(path string) (file *os.File, appending bool, err error)
+
+	// UniqueURLs is set when the scanner skips duplicate input URLs
+	// (scanner.Options.Dedupe), so sinks need not remember URLs to list
+	// each once
+	UniqueURLs bool
+
+	// NewKeySet opens the set a sink remembers URLs in (--dedupe-store), so
+	// that memory stays bounded on large inputs. nil means an in-memory set.
+	NewKeySet func() (scanner.KeySet, error)
+}
+
+// Summary describes the scan as a whole, for reports that show more than
+// the matches. Settings lists the configuration in display order.
+type Summary struct {
+	Stats       scanner.Stats
+	Started     time.Time
+	Finished    time.Time
+	Interrupted bool
+	Settings    []Setting
+}
+
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// errNotResumable is returned by report sinks opened with Env.Resumable
+var errNotResumable = errors.New("written once when the scan ends, so it cannot be combined with --resume")
+
+// ==============================================
+// REGISTRY
+// ==============================================
+
+// SinkType is a registered kind of sink. New gets the target from the sink
+// spec: a file path, a URL or "-" for stdout, depending on the type.
+type SinkType struct {
+	Name        string
+	Description string
+	New         func(target string) (Sink, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]SinkType)
+)
+
+// Register makes a sink type available to Open. It panics if the name is
+// empty or already taken.
+func Register(t SinkType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if t.Name == "" || t.New == nil {
+		panic("output: Register needs a name and a constructor")
+	}
+	if _, dup := registry[t.Name]; dup {
+		panic("output: sink type " + t.Name + " registered twice")
+	}
+	registry[t.Name] = t
+}
+
+// Types returns the registered sink types sorted by name
+func Types() []SinkType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]SinkType, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+func lookup(name string) (SinkType, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// ==============================================
+// SINK SPECS & FILTERS
+// ==============================================
+
+// Spec selects a sink and the matches it receives. Its text form is
+//
+//	type[,min-severity=S][,min-confidence=N][,category=C]...[,tag=T]...:target
+//
+// e.g. "jsonl:all.jsonl" or "sarif,min-severity=high,category=secrets:ci.sarif".
+// Repeated category and tag options are alternatives.
+type Spec struct {
+	Type     string
+	Target   string
+	Filter   scanner.Filter
+	MinScore int // Matches whose best occurrence scores lower are dropped
+}
+
+func ParseSpec(text string) (Spec, error) {
+	var spec Spec
+	head, target, found := strings.Cut(text, ":")
+	if !found || target == "" {
+		return spec, fmt.Errorf("output '%s': expected type:target, e.g. jsonl:matches.jsonl", text)
+	}
+	spec.Target = target
+	options := strings.Split(head, ",")
+	spec.Type = strings.ToLower(strings.TrimSpace(options[0]))
+	if _, ok := lookup(spec.Type); !ok {
+		return spec, fmt.Errorf("output '%s': unknown type '%s' (use %s)", text, spec.Type, typeNames())
+	}
+	for _, option := range options[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if value == "" {
+			return spec, fmt.Errorf("output '%s': option '%s' needs a value", text, option)
+		}
+		switch key {
+		case "min-severity":
+			severity, err := scanner.ParseSeverity(value)
+			if err != nil {
+				return spec, fmt.Errorf("output '%s': %w", text, err)
+			}
+			spec.Filter.MinSeverity = severity
+		case "min-confidence":
+			score, err := scanner.ParseScore(value)
+			if err != nil {
+				return spec, fmt.Errorf("output '%s': %w", text, err)
+			}
+			spec.MinScore = score
+		case "category":
+			spec.Filter.Categories = append(spec.Filter.Categories, value)
+		case "tag":
+			spec.Filter.Tags = append(spec.Filter.Tags, value)
+		default:
+			return spec, fmt.Errorf("output '%s': unknown option '%s' (use min-severity, min-confidence, category or tag)", text, key)
+		}
+	}
+	return spec, nil
+}
+
+func typeNames() string {
+	var names []string
+	for _, t := range Types() {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Open parses a spec, creates its sink and opens it. The sink only receives
+// matches that pass the spec's filter.
+func Open(spec string, env Env) (Sink, error) {
+	parsed, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return OpenSpec(parsed, env)
+}
+
+func OpenSpec(spec Spec, env Env) (Sink, error) {
+	t, ok := lookup(spec.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown output type '%s'", spec.Type)
+	}
+	sink, err := t.New(spec.Target)
+	if err != nil {
+		return nil, fmt.Errorf("%s output '%s': %w", spec.Type, spec.Target, err)
+	}
+	if err := sink.Open(env); err != nil {
+		return nil, fmt.Errorf("%s output '%s': %w", spec.Type, spec.Target, err)
+	}
+	if isZeroFilter(spec.Filter) && spec.MinScore == 0 {
+		return sink, nil
+	}
+	return &filteredSink{Sink: sink, filter: spec.Filter, minScore: spec.MinScore}, nil
+}
+
+func isZeroFilter(f scanner.Filter) bool {
+	return f.MinSeverity == scanner.SeverityInfo && len(f.Categories) == 0 && len(f.Tags) == 0
+}
+
+// filteredSink drops the matches its filter does not select
+type filteredSink struct {
+	Sink
+	filter   scanner.Filter
+	minScore int
+}
+
+func (fs *filteredSink) Write(m scanner.Match) error {
+	if !fs.filter.Match(m.Rule) || m.Score() < fs.minScore {
+		return nil
+	}
+	return fs.Sink.Write(m)
+}
+
+// ==============================================
+// FILE TARGETS
+// ==============================================
+
+// fileTarget is where a file-based sink writes: a path opened through the
+// Env, or a writer handed to the sink's constructor
+type fileTarget struct {
+	path      string
+	w         io.Writer
+	file      *os.File // Opened by open, closed by close
+	appending bool
+}
+
+func (t *fileTarget) open(env Env) error {
+	if t.w != nil {
+		return nil
+	}
+	if t.path == "-" {
+		t.w = os.Stdout
+		return nil
+	}
+	var err error
+	if env.OpenFile != nil {
+		t.file, t.appending, err = env.OpenFile(t.path)
+	} else {
+		t.file, err = os.Create(t.path)
+	}
+	if err != nil {
+		return err
+	}
+	t.w = t.file
+	return nil
+}
+
+func (t *fileTarget) close() error {
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+func init() {
+	for _, t := range []SinkType{
+		{Name: "urls", Description: "Unique matched URLs, one per line (\"-\" for stdout)", New: func(target string) (Sink, error) { return &URLListWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "text", Description: "One line per match, as --log-file", New: func(target string) (Sink, error) { return &TextWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "jsonl", Description: "One JSON object per match (JSON Lines)", New: func(target string) (Sink, error) { return &JSONLWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "csv", Description: "One CSV row per match", New: func(target string) (Sink, error) { return &CSVWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "sarif", Description: "SARIF 2.1.0 report", New: func(target string) (Sink, error) { return &SARIFWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "html", Description: "Self-contained HTML report", New: func(target string) (Sink, error) { return &HTMLWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "markdown", Description: "Markdown report grouped by host and category", New: func(target string) (Sink, error) { return &MarkdownWriter{target: fileTarget{path: target}}, nil }},
+		{Name: "webhook", Description: "Matches POSTed as JSON to an http(s) URL, in batches", New: newWebhookSink},
+	} {
+		Register(t)
+	}
+}
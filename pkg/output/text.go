@@ -0,0 +1,131 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// TEXT LOG & URL LIST
+// ==============================================
+
+// TextWriter is the "text" sink: the one-line-per-match log of --log-file
+type TextWriter struct {
+	target fileTarget
+}
+
+// NewTextWriter returns a sink writing log lines to w
+func NewTextWriter(w io.Writer) *TextWriter {
+	return &TextWriter{target: fileTarget{w: w}}
+}
+
+func (tw *TextWriter) Open(env Env) error { return tw.target.open(env) }
+
+func (tw *TextWriter) Write(m scanner.Match) error {
+	rule := m.Rule
+	_, err := fmt.Fprintf(tw.target.w, "%s MATCHED_PATTERN: %s (From: %s, ID: %s, Severity: %s, Score: %d) FOUND [%d time(s)]:- %s\n",
+		m.URL, rule.Regex, rule.SourceFile, rule.ID, rule.Severity, m.Score(), len(m.Occurrences), strings.Join(describedValues(m), " - "))
+	return err
+}
+
+// describedValues renders the occurrences for people, with the analysis of
+// any JWT after its value
+func describedValues(m scanner.Match) []string {
+	values := m.Values()
+	for i, occ := range m.Occurrences {
+		if occ.JWT != nil {
+			values[i] += " (" + occ.JWT.String() + ")"
+		}
+	}
+	return values
+}
+
+func (tw *TextWriter) Close(Summary) error { return tw.target.close() }
+
+// URLListWriter is the "urls" sink: each matched URL once, as --found-urls.
+// A file is kept free of duplicates, including lines written by an earlier
+// run it is appended to. On stdout, or when the scanner already skips
+// duplicates (Env.UniqueURLs), a URL is printed once per time it was
+// scanned, so nothing has to be remembered. Otherwise URLs are remembered
+// in Env.NewKeySet.
+type URLListWriter struct {
+	target  fileTarget
+	seen    scanner.KeySet // nil on stdout or with Env.UniqueURLs
+	lastURL string
+	lastSeq int64
+}
+
+// NewURLListWriter returns a sink writing matched URLs to w
+func NewURLListWriter(w io.Writer) *URLListWriter {
+	return &URLListWriter{target: fileTarget{w: w}}
+}
+
+func (uw *URLListWriter) Open(env Env) error {
+	if err := uw.target.open(env); err != nil {
+		return err
+	}
+	if uw.target.w == os.Stdout || env.UniqueURLs {
+		return nil
+	}
+	var err error
+	if env.NewKeySet != nil {
+		uw.seen, err = env.NewKeySet()
+	} else {
+		uw.seen, err = scanner.NewKeySet(scanner.DedupeConfig{})
+	}
+	if err != nil {
+		uw.target.close()
+		return err
+	}
+	if uw.target.appending {
+		if err := readLines(uw.target.path, uw.seen); err != nil {
+			uw.Close(Summary{})
+			return err
+		}
+	}
+	return nil
+}
+
+func (uw *URLListWriter) Write(m scanner.Match) error {
+	if uw.seen == nil {
+		if m.URL == uw.lastURL && m.Seq == uw.lastSeq {
+			return nil // Another match of the URL just printed
+		}
+		uw.lastURL, uw.lastSeq = m.URL, m.Seq
+	} else if !uw.seen.Add(m.URL) {
+		return nil
+	}
+	_, err := fmt.Fprintln(uw.target.w, m.URL)
+	return err
+}
+
+func (uw *URLListWriter) Close(Summary) error {
+	err := uw.target.close()
+	if uw.seen != nil {
+		if closeErr := uw.seen.Close(); err == nil {
+			err = closeErr
+		}
+		uw.seen = nil
+	}
+	return err
+}
+
+// readLines adds the lines of an existing file to lines
+func readLines(path string, lines scanner.KeySet) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewScanner(file)
+	reader.Buffer(make([]byte, 64*1024), 1024*1024)
+	for reader.Scan() {
+		lines.Add(reader.Text())
+	}
+	return reader.Err()
+}
@@ -0,0 +1,35 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+func TestURLListUsesKeySet(t *testing.T) {
+	rules := testRules("r", "x")
+	for _, store := range []scanner.DedupeStore{scanner.StoreMemory, scanner.StoreBloom, scanner.StoreDisk} {
+		opened := 0
+		env := Env{Rules: rules, NewKeySet: func() (scanner.KeySet, error) {
+			opened++
+			return scanner.NewKeySet(scanner.DedupeConfig{Store: store, Dir: t.TempDir()})
+		}}
+		var buf bytes.Buffer
+		uw := NewURLListWriter(&buf)
+		if err := uw.Open(env); err != nil {
+			t.Fatal(err)
+		}
+		for i, url := range []string{"https://a.com/x", "https://b.com/x", "https://a.com/x", "https://b.com/x"} {
+			if err := uw.Write(scanner.Match{URL: url, Seq: int64(i), Rule: &rules.Rules[0]}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := uw.Close(Summary{}); err != nil {
+			t.Fatal(err)
+		}
+		if opened != 1 || buf.String() != "https://a.com/x\nhttps://b.com/x\n" {
+			t.Errorf("%s: %d sets opened, wrote %q", store, opened, buf.String())
+		}
+	}
+}
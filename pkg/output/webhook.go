@@ -0,0 +1,188 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// WEBHOOK
+// ==============================================
+// Matches are POSTed as JSON in batches, from a goroutine so a slow
+// endpoint only holds up the scan once several batches are queued. The
+// payload has a "text" line for chat webhooks (Slack, Mattermost) and the
+// matches as JSON Lines records for everything else:
+//
+//	{"text": "CodeHunter: 2 matches ...", "matches": [{...}, {...}]}
+//
+// A resumed scan would post again what was posted after its last
+// checkpoint, so the sink refuses --resume.
+
+const (
+	webhookBatchSize = 50
+	webhookMaxDelay  = 5 * time.Second // Flush a partial batch once its oldest match is this old
+	webhookTimeout   = 15 * time.Second
+)
+
+var errWebhookNotResumable = errors.New("matches posted after the last checkpoint would be posted again, so it cannot be combined with --resume")
+
+type webhookPayload struct {
+	Text    string   `json:"text"`
+	Matches []Record `json:"matches"`
+}
+
+// WebhookSink is the "webhook" sink
+type WebhookSink struct {
+	url       string
+	client    *http.Client
+	userAgent string
+	maxDelay  time.Duration
+
+	batchMu sync.Mutex // Write and the batch timer both flush
+	batch   []Record
+	timer   *time.Timer // Flushes the batch once its oldest match is maxDelay old
+	closed  bool
+	queue   chan []Record
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error // First delivery error not yet returned
+}
+
+func newWebhookSink(target string) (Sink, error) { return NewWebhookSink(target) }
+
+// NewWebhookSink returns a sink posting matches to an http(s) URL
+func NewWebhookSink(target string) (*WebhookSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook target must be an http(s) URL")
+	}
+	return &WebhookSink{url: target, client: &http.Client{Timeout: webhookTimeout}, maxDelay: webhookMaxDelay}, nil
+}
+
+func (ws *WebhookSink) Open(env Env) error {
+	if env.Resumable {
+		return errWebhookNotResumable
+	}
+	ws.userAgent = "CodeHunter/" + env.ToolVersion
+	ws.queue = make(chan []Record, 4)
+	ws.done = make(chan struct{})
+	go ws.send()
+	return nil
+}
+
+func (ws *WebhookSink) send() {
+	defer close(ws.done)
+	for batch := range ws.queue {
+		if err := ws.post(batch); err != nil {
+			ws.mu.Lock()
+			if ws.err == nil {
+				ws.err = err
+			}
+			ws.mu.Unlock()
+		}
+	}
+}
+
+func (ws *WebhookSink) post(batch []Record) error {
+	payload := webhookPayload{Text: webhookText(batch), Matches: batch}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ws.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", ws.userAgent)
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s returned %s for %d matches", ws.url, resp.Status, len(batch))
+	}
+	return nil
+}
+
+func webhookText(batch []Record) string {
+	worst := batch[0]
+	for _, r := range batch[1:] {
+		if r.Severity > worst.Severity {
+			worst = r
+		}
+	}
+	name := worst.Name
+	if name == "" {
+		name = worst.RuleID
+	}
+	return fmt.Sprintf("CodeHunter: %d matches, most severe: %s (%s) on %s", len(batch), name, worst.Severity, worst.URL)
+}
+
+// Write queues the match. It returns the first delivery error since the
+// previous Write, if any.
+func (ws *WebhookSink) Write(m scanner.Match) error {
+	ws.batchMu.Lock()
+	ws.batch = append(ws.batch, NewRecord(m))
+	if len(ws.batch) >= webhookBatchSize {
+		ws.flush()
+	} else if ws.timer == nil {
+		ws.timer = time.AfterFunc(ws.maxDelay, ws.flushLate)
+	}
+	ws.batchMu.Unlock()
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	err := ws.err
+	ws.err = nil
+	return err
+}
+
+// flush queues the batch; batchMu must be held
+func (ws *WebhookSink) flush() {
+	if ws.timer != nil {
+		ws.timer.Stop()
+		ws.timer = nil
+	}
+	if len(ws.batch) > 0 {
+		ws.queue <- ws.batch
+		ws.batch = nil
+	}
+}
+
+// flushLate sends a partial batch that no further match has filled
+func (ws *WebhookSink) flushLate() {
+	ws.batchMu.Lock()
+	defer ws.batchMu.Unlock()
+	if !ws.closed {
+		ws.flush()
+	}
+}
+
+// Close sends the last batch and waits for every delivery to finish
+func (ws *WebhookSink) Close(Summary) error {
+	ws.batchMu.Lock()
+	ws.flush()
+	ws.closed = true
+	ws.batchMu.Unlock()
+	close(ws.queue)
+	<-ws.done
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.err
+}
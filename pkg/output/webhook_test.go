@@ -0,0 +1,84 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// webhookServer records the size of each batch posted to it
+func webhookServer(t *testing.T) (*httptest.Server, chan int) {
+	batches := make(chan int, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text    string            `json:"text"`
+			Matches []json.RawMessage `json:"matches"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batches <- len(payload.Matches)
+	}))
+	t.Cleanup(server.Close)
+	return server, batches
+}
+
+func openWebhook(t *testing.T, target string, rules *scanner.RuleSet) *WebhookSink {
+	t.Helper()
+	ws, err := NewWebhookSink(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.maxDelay = 50 * time.Millisecond
+	if err := ws.Open(Env{Rules: rules, ToolVersion: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestWebhookBatches(t *testing.T) {
+	server, batches := webhookServer(t)
+	rules := testRules("r", "x")
+	ws := openWebhook(t, server.URL, rules)
+	for i := 0; i < webhookBatchSize+1; i++ {
+		if err := ws.Write(scanner.Match{URL: fmt.Sprintf("https://a.com/%d", i), Seq: int64(i), Rule: &rules.Rules[0]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := <-batches; got != webhookBatchSize {
+		t.Errorf("first batch of %d matches, want %d", got, webhookBatchSize)
+	}
+
+	// The last match is posted once it is maxDelay old, with no further writes
+	select {
+	case got := <-batches:
+		if got != 1 {
+			t.Errorf("partial batch of %d matches, want 1", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("partial batch not posted after the delay")
+	}
+	if err := ws.Close(Summary{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 0 {
+		t.Errorf("%d more batches posted on Close", len(batches))
+	}
+}
+
+func TestWebhookNotResumable(t *testing.T) {
+	ws, err := NewWebhookSink("https://hooks.example.com/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Open(Env{Rules: testRules("r", "x"), Resumable: true}); !errors.Is(err, errWebhookNotResumable) {
+		t.Errorf("Open with Resumable: %v, want errWebhookNotResumable", err)
+	}
+}
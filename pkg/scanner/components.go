@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ==============================================
+// URL COMPONENTS (rule scopes)
+// ==============================================
+// A rule with no scope matches the raw URL string, as it always has. A rule
+// scoped to one or more components is only applied to those parts of the
+// parsed URL, so "/admin/.*" scoped to "path" no longer fires on
+// "?redirect=/admin/".
+
+type Component string
+
+const (
+	ComponentURL        Component = "url"
+	ComponentScheme     Component = "scheme"
+	ComponentHost       Component = "host"
+	ComponentPort       Component = "port"
+	ComponentPath       Component = "path"
+	ComponentSegment    Component = "segment"
+	ComponentQueryKey   Component = "query_key"
+	ComponentQueryValue Component = "query_value"
+	ComponentFragment   Component = "fragment"
+
+	// Response components, only present in fetch mode
+	ComponentHeader Component = "header"
+	ComponentBody   Component = "body"
+)
+
+var knownComponents = []Component{
+	ComponentURL, ComponentScheme, ComponentHost, ComponentPort, ComponentPath,
+	ComponentSegment, ComponentQueryKey, ComponentQueryValue, ComponentFragment,
+	ComponentHeader, ComponentBody,
+}
+
+// unscopedComponents are matched by rules that declare no scope: the raw URL
+// and, when fetching, the response headers and body.
+var unscopedComponents = []Component{ComponentURL, ComponentHeader, ComponentBody}
+
+func ParseComponent(name string) (Component, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, c := range knownComponents {
+		if name == string(c) {
+			return c, nil
+		}
+	}
+	names := make([]string, len(knownComponents))
+	for i, c := range knownComponents {
+		names[i] = string(c)
+	}
+	return "", fmt.Errorf("unknown scope '%s' (use %s)", name, strings.Join(names, ", "))
+}
+
+func parseScope(names []string) ([]Component, error) {
+	var scope []Component
+	for _, name := range names {
+		c, err := ParseComponent(name)
+		if err != nil {
+			return nil, err
+		}
+		if c == ComponentURL && len(names) == 1 {
+			return nil, nil // Explicit "url" is the same as no scope
+		}
+		scope = append(scope, c)
+	}
+	return scope, nil
+}
+
+// ComponentView is one matchable piece of a URL or response. Key names the
+// query parameter for query keys/values, the 1-based index for path segments
+// and the header name for headers. Decoded views carry the decoders that
+// produced Value.
+type ComponentView struct {
+	Component Component
+	Key       string
+	Value     string
+	Decoding  []Decoder
+}
+
+// urlComponents splits a URL into its matchable components. Query
+// parameters keep their original order and duplicates. Empty components are
+// omitted. If the URL cannot be parsed only the raw URL view is returned.
+func urlComponents(rawURL string) []ComponentView {
+	views := []ComponentView{{Component: ComponentURL, Value: rawURL}}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return views
+	}
+
+	add := func(c Component, key, value string) {
+		if value != "" {
+			views = append(views, ComponentView{Component: c, Key: key, Value: value})
+		}
+	}
+	add(ComponentScheme, "", u.Scheme)
+	add(ComponentHost, "", u.Hostname())
+	add(ComponentPort, "", u.Port())
+	add(ComponentPath, "", u.Path)
+	segmentIndex := 0
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			segmentIndex++
+			add(ComponentSegment, fmt.Sprint(segmentIndex), segment)
+		}
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key := queryUnescapeLenient(rawKey)
+		add(ComponentQueryKey, key, key)
+		add(ComponentQueryValue, key, queryUnescapeLenient(rawValue))
+	}
+	add(ComponentFragment, "", u.Fragment)
+	return views
+}
+
+// queryUnescapeLenient decodes a query key or value, keeping the raw text
+// when it contains invalid escapes (common in crawled URLs).
+func queryUnescapeLenient(s string) string {
+	if decoded, err := url.QueryUnescape(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+// scopeAppliesTo reports whether a rule with this scope should be matched
+// against the given component.
+func scopeAppliesTo(scope []Component, c Component) bool {
+	if len(scope) == 0 {
+		return c == ComponentURL || c == ComponentHeader || c == ComponentBody
+	}
+	for _, sc := range scope {
+		if sc == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package scanner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==============================================
+// DECODING STAGE
+// ==============================================
+// Rules match the URL as written, so "api%5Fkey%3DABC..." or a base64
+// "state=" parameter holding credentials slips through. With decoders
+// enabled every URL view also gets decoded views: each layer applies every
+// decoder to the views of the previous layer, up to the configured depth.
+// Occurrences found in a decoded view record the chain that produced it.
+
+// Decoder names one decoding step
+type Decoder string
+
+const (
+	DecodePercent Decoder = "percent" // %XX escapes
+	DecodePlus    Decoder = "plus"    // '+' as space, as in form-encoded queries
+	DecodeHTML    Decoder = "html"    // HTML entities: &amp; &#61; &#x3D; ...
+	DecodeBase64  Decoder = "base64"  // Standard and URL-safe base64 runs, padded or not
+)
+
+var knownDecoders = []Decoder{DecodePercent, DecodePlus, DecodeHTML, DecodeBase64}
+
+const (
+	DefaultDecodeDepth = 2
+	maxDecodedViews    = 32 // Per source view, against runaway nesting
+	minBase64Run       = 16 // Shorter runs are mostly words and path segments
+)
+
+// DecodeConfig enables the decoding stage. It is off without Decoders.
+type DecodeConfig struct {
+	Decoders []Decoder // Applied in this order in every layer
+	Depth    int       // Layers of decoding, DefaultDecodeDepth if 0
+}
+
+// ParseDecoders reads a comma-separated decoder list. "all" enables every
+// decoder and "none" (or an empty list) none.
+func ParseDecoders(list string) ([]Decoder, error) {
+	var decoders []Decoder
+	for _, name := range SplitList(strings.ToLower(list)) {
+		switch name {
+		case "none":
+			continue
+		case "all":
+			return knownDecoders, nil
+		}
+		d, ok := findDecoder(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown decoder '%s' (use percent, plus, html, base64 or all)", name)
+		}
+		if !containsDecoder(decoders, d) {
+			decoders = append(decoders, d)
+		}
+	}
+	return decoders, nil
+}
+
+func findDecoder(name string) (Decoder, bool) {
+	for _, d := range knownDecoders {
+		if name == string(d) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+func containsDecoder(decoders []Decoder, d Decoder) bool {
+	for _, existing := range decoders {
+		if existing == d {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the configuration, e.g. "percent, base64 (depth 2)",
+// or "" when decoding is off
+func (c DecodeConfig) String() string {
+	if len(c.Decoders) == 0 {
+		return ""
+	}
+	depth := c.Depth
+	if depth <= 0 {
+		depth = DefaultDecodeDepth
+	}
+	names := make([]string, len(c.Decoders))
+	for i, d := range c.Decoders {
+		names[i] = string(d)
+	}
+	return fmt.Sprintf("%s (depth %d)", strings.Join(names, ", "), depth)
+}
+
+// DecodingChain renders a chain of decoders, outermost first: "percent > base64"
+func DecodingChain(chain []Decoder) string {
+	names := make([]string, len(chain))
+	for i, d := range chain {
+		names[i] = string(d)
+	}
+	return strings.Join(names, " > ")
+}
+
+// decodeViews returns the decoded views of views. Texts that decode to
+// something already seen for the same source view are skipped.
+func decodeViews(views []ComponentView, config DecodeConfig) []ComponentView {
+	depth := config.Depth
+	if depth <= 0 {
+		depth = DefaultDecodeDepth
+	}
+	var decoded []ComponentView
+	for _, view := range views {
+		seen := map[string]bool{view.Value: true}
+		count := 0
+		layer := []ComponentView{view}
+		for level := 0; level < depth && len(layer) > 0; level++ {
+			var next []ComponentView
+			for _, v := range layer {
+				for _, d := range config.Decoders {
+					for _, text := range decode(d, v.Value) {
+						if seen[text] || count >= maxDecodedViews {
+							continue
+						}
+						seen[text] = true
+						count++
+						chain := append(v.Decoding[:len(v.Decoding):len(v.Decoding)], d)
+						next = append(next, ComponentView{Component: v.Component, Key: v.Key, Value: text, Decoding: chain})
+					}
+				}
+			}
+			decoded = append(decoded, next...)
+			layer = next
+		}
+	}
+	return decoded
+}
+
+// decode applies one decoder to text, returning nothing when it does not
+// change it. Base64 returns one text per decodable run.
+func decode(d Decoder, text string) []string {
+	var decoded string
+	switch d {
+	case DecodePercent:
+		if !strings.Contains(text, "%") {
+			return nil
+		}
+		decoded = percentDecodeLenient(text)
+	case DecodePlus:
+		decoded = strings.ReplaceAll(text, "+", " ")
+	case DecodeHTML:
+		if !strings.Contains(text, "&") {
+			return nil
+		}
+		decoded = html.UnescapeString(text)
+	case DecodeBase64:
+		return base64Runs(text)
+	}
+	if decoded == text {
+		return nil
+	}
+	return []string{decoded}
+}
+
+// percentDecodeLenient decodes valid %XX escapes and keeps the rest as is
+func percentDecodeLenient(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			sb.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// base64Runs decodes every run of base64 characters in text long enough to
+// be an encoded value, keeping those that decode to printable text
+func base64Runs(text string) []string {
+	var decoded []string
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && isBase64Char(text[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minBase64Run {
+			if plain, ok := decodeBase64(text[start:i]); ok {
+				decoded = append(decoded, plain)
+			}
+		}
+		start = -1
+	}
+	return decoded
+}
+
+// isBase64Char accepts both alphabets; '=' only counts as trailing padding,
+// so "state=..." splits at the '='
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '-' || c == '_'
+}
+
+func decodeBase64(run string) (string, bool) {
+	urlSafe := strings.ContainsAny(run, "-_")
+	if urlSafe && strings.ContainsAny(run, "+/") {
+		return "", false // Mixed alphabets
+	}
+	encoding := base64.RawStdEncoding
+	if urlSafe {
+		encoding = base64.RawURLEncoding
+	}
+	data, err := encoding.DecodeString(run)
+	if err != nil || !printable(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+// printable reports whether data is UTF-8 text without control characters
+// other than tabs and newlines: random bytes almost never are
+func printable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
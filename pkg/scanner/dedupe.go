@@ -0,0 +1,225 @@
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ==============================================
+// URL NORMALIZATION & DEDUPLICATION
+// ==============================================
+// Wayback and crawler lists repeat the same endpoint with a different
+// case, port, parameter order or ID. A dedupe mode maps every URL to a key;
+// URLs whose key was already seen are skipped before scanning, so every
+// output lists each of them once. The keys are kept in a KeySet.
+
+type DedupeMode string
+
+const (
+	DedupeNone       DedupeMode = "none"       // Every input line is scanned
+	DedupeExact      DedupeMode = "exact"      // Identical lines
+	DedupeNormalized DedupeMode = "normalized" // Same URL once normalized, see NormalizeURL
+	DedupePattern    DedupeMode = "pattern"    // Same URL up to numeric and UUID values, see URLPattern
+)
+
+func ParseDedupeMode(name string) (DedupeMode, error) {
+	switch mode := DedupeMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "", DedupeNone:
+		return DedupeNone, nil
+	case DedupeExact, DedupeNormalized, DedupePattern:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown dedupe mode '%s' (use none, exact, normalized or pattern)", name)
+}
+
+// Key is the identity of rawURL under the mode: URLs with the same key are
+// duplicates
+func (m DedupeMode) Key(rawURL string) string {
+	switch m {
+	case DedupeNormalized:
+		return NormalizeURL(rawURL)
+	case DedupePattern:
+		return URLPattern(rawURL)
+	}
+	return rawURL
+}
+
+// defaultPorts are left out of normalized URLs
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+// NormalizeURL lowercases the scheme and host, drops the default port,
+// writes an empty path as "/" and sorts the query parameters by key
+// (keeping the order of repeated keys). The path, values and fragment are
+// kept as written. Unparseable URLs are returned as is.
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+	return normalizedURL(u, u.EscapedPath(), sortedQuery(u.RawQuery, nil))
+}
+
+// URLPattern normalizes rawURL and replaces numeric and UUID path segments
+// and query values with {int} and {uuid}, so "/users/42?id=7" and
+// "/users/43?id=8" share the pattern "/users/{int}?id={int}"
+func URLPattern(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = placeholder(segment)
+	}
+	return normalizedURL(u, strings.Join(segments, "/"), sortedQuery(u.RawQuery, placeholder))
+}
+
+func normalizedURL(u *url.URL, path, query string) string {
+	var sb strings.Builder
+	if u.Scheme != "" {
+		sb.WriteString(strings.ToLower(u.Scheme) + ":")
+	}
+	if u.Host != "" || u.Scheme != "" {
+		sb.WriteString("//")
+		if u.User != nil {
+			sb.WriteString(u.User.String() + "@")
+		}
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+		sb.WriteString(host)
+		if port := u.Port(); port != "" && port != defaultPorts[strings.ToLower(u.Scheme)] {
+			sb.WriteString(":" + port)
+		}
+		if path == "" {
+			path = "/"
+		}
+	}
+	sb.WriteString(path)
+	if query != "" {
+		sb.WriteString("?" + query)
+	}
+	if u.Fragment != "" {
+		sb.WriteString("#" + u.EscapedFragment())
+	}
+	return sb.String()
+}
+
+// sortedQuery sorts the parameters of a raw query by key, dropping empty
+// ones, and passes each value through replace if it is set
+func sortedQuery(rawQuery string, replace func(string) string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type param struct{ key, pair string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(pair, "=")
+		if replace != nil && hasValue {
+			pair = key + "=" + replace(value)
+		}
+		params = append(params, param{queryUnescapeLenient(key), pair})
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].key < params[j].key })
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// placeholder replaces a numeric or UUID value with {int} or {uuid}
+func placeholder(value string) string {
+	switch {
+	case value == "":
+		return value
+	case strings.Trim(value, digits) == "":
+		return "{int}"
+	case isUUID(value):
+		return "{uuid}"
+	}
+	return value
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// URLDeduper remembers the keys of the URLs it was given in a KeySet. It
+// is safe for concurrent use.
+type URLDeduper struct {
+	mode    DedupeMode
+	mu      sync.Mutex
+	seen    KeySet
+	skipped int
+}
+
+// NewURLDeduper opens a deduper with the configured store. It returns nil
+// for DedupeNone. Close it to release the store.
+func NewURLDeduper(config DedupeConfig) (*URLDeduper, error) {
+	if config.Mode == DedupeNone || config.Mode == "" {
+		return nil, nil
+	}
+	seen, err := NewKeySet(config)
+	if err != nil {
+		return nil, err
+	}
+	return &URLDeduper{mode: config.Mode, seen: seen}, nil
+}
+
+func (d *URLDeduper) Mode() DedupeMode { return d.mode }
+
+// Add records rawURL and reports whether it is new: false means a URL with
+// the same key was added before, and counts as skipped
+func (d *URLDeduper) Add(rawURL string) bool {
+	key := d.mode.Key(rawURL)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen.Add(key) {
+		return true
+	}
+	d.skipped++
+	return false
+}
+
+// Skipped is the number of duplicates Add has reported
+func (d *URLDeduper) Skipped() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.skipped
+}
+
+// Close releases the store, reporting any error it met. URLs added after
+// an error were all taken as new.
+func (d *URLDeduper) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		return nil
+	}
+	err := d.seen.Close()
+	d.seen = nil
+	return err
+}
@@ -0,0 +1,688 @@
+package scanner
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================================
+// MULTI-PATTERN MATCHING ENGINE
+// ==============================================
+// Running ~1000 regexes one after another on every URL is the main cost of
+// a scan. The engine extracts, for each rule, a set of literals of which at
+// least one must appear in any match (e.g. "/wp-admin" for "/wp-admin/.*"),
+// and feeds all literals into one Aho-Corasick automaton. Each text is
+// scanned once; only rules whose literals were seen (plus the few rules with
+// no usable literal) are evaluated with their regex. Results are identical
+// to evaluating every rule, in rule order. Rules with keywords use them as
+// their literals, so they only run on texts containing a keyword.
+
+const (
+	maxLiteralSet  = 64 // Give up on literal sets larger than this
+	minLiteralSize = 2  // Shorter literals prefilter too little to be worth it
+)
+
+type ruleMatch struct {
+	Index       int // Index into the engine's pattern slice
+	Occurrences []Occurrence
+}
+
+type componentRules struct {
+	all    []int // Rules applying to the component, ascending
+	always []int // Subset without usable literals, evaluated unconditionally
+}
+
+type matchEngine struct {
+	patterns     []Rule
+	secretGroups []int      // Index of each rule's "secret" group, -1 without one
+	keywords     [][]string // Lowercased keywords of each rule, nil without them
+	scanKeywords []bool     // Rules whose keywords the automaton cannot find, nil if none
+	automaton    *ahoCorasick
+	components   map[Component]*componentRules
+	scratchPool  sync.Pool
+}
+
+type engineScratch struct {
+	seen       []uint32 // Generation marks, one per rule
+	generation uint32
+	candidates []int
+}
+
+func newMatchEngine(patterns []Rule) *matchEngine {
+	e := &matchEngine{
+		patterns:     patterns,
+		secretGroups: make([]int, len(patterns)),
+		components:   make(map[Component]*componentRules),
+	}
+	for i, p := range patterns {
+		e.secretGroups[i] = p.Compiled.SubexpIndex("secret")
+	}
+
+	literalIDs := make(map[string]int)
+	var literals []string
+	var literalRules [][]int
+	for i, p := range patterns {
+		for _, c := range ruleComponents(p) {
+			rules := e.components[c]
+			if rules == nil {
+				rules = &componentRules{}
+				e.components[c] = rules
+			}
+			rules.all = append(rules.all, i)
+		}
+
+		required := requiredLiterals(p.Regex)
+		if keywords := keywordLiterals(p.Keywords); keywords != nil {
+			if e.keywords == nil {
+				e.keywords = make([][]string, len(patterns))
+			}
+			e.keywords[i] = keywords
+			if asciiOnly(keywords) {
+				required = keywords // A keyword must appear even where the regex needs no literal
+			} else {
+				// The automaton folds ASCII case only, so candidates checks
+				// these keywords itself
+				if e.scanKeywords == nil {
+					e.scanKeywords = make([]bool, len(patterns))
+				}
+				e.scanKeywords[i] = true
+			}
+		}
+		if required == nil {
+			for _, c := range ruleComponents(p) {
+				e.components[c].always = append(e.components[c].always, i)
+			}
+			continue
+		}
+		for _, lit := range required {
+			id, ok := literalIDs[lit]
+			if !ok {
+				id = len(literals)
+				literalIDs[lit] = id
+				literals = append(literals, lit)
+				literalRules = append(literalRules, nil)
+			}
+			literalRules[id] = append(literalRules[id], i)
+		}
+	}
+	e.automaton = newAhoCorasick(literals, literalRules)
+	e.scratchPool.New = func() any {
+		return &engineScratch{seen: make([]uint32, len(patterns))}
+	}
+	return e
+}
+
+// ruleComponents lists the components a rule is evaluated against
+func ruleComponents(p Rule) []Component {
+	if len(p.Scope) == 0 {
+		return unscopedComponents
+	}
+	return p.Scope
+}
+
+// match evaluates every applicable rule against the views and returns one
+// entry per matching rule, ordered by rule index, with occurrences in view
+// order (the same result as looping over all rules and views). counters,
+// if not nil, receive the evaluations and time of each rule.
+func (e *matchEngine) match(views []ComponentView, counters []ruleCounters) []ruleMatch {
+	scratch := e.scratchPool.Get().(*engineScratch)
+	defer e.scratchPool.Put(scratch)
+
+	var matches []ruleMatch
+	for _, view := range views {
+		rules := e.components[view.Component]
+		if rules == nil {
+			continue
+		}
+		for _, idx := range e.candidates(view.Value, view.Component, rules, scratch) {
+			rule := &e.patterns[idx]
+			group := e.secretGroups[idx]
+			var start time.Time
+			if counters != nil {
+				start = time.Now()
+			}
+			var locs [][]int
+			if group > 0 {
+				locs = rule.Compiled.FindAllStringSubmatchIndex(view.Value, -1)
+			} else {
+				locs = rule.Compiled.FindAllStringIndex(view.Value, -1)
+			}
+			if len(locs) == 0 {
+				if counters != nil {
+					counters[idx].evaluations.Add(1)
+					counters[idx].nanos.Add(int64(time.Since(start)))
+				}
+				continue
+			}
+			occurrences := make([]Occurrence, 0, len(locs))
+			for _, loc := range locs {
+				value := view.Value[loc[0]:loc[1]]
+				secret := secretPart(value)
+				if group > 0 && loc[2*group] >= 0 {
+					secret = view.Value[loc[2*group]:loc[2*group+1]]
+				}
+				if rule.Allowlisted(secret) {
+					continue
+				}
+				score, failed := rule.Score(secret)
+				occurrences = append(occurrences, Occurrence{
+					Value:     value,
+					Start:     loc[0],
+					End:       loc[1],
+					Component: view.Component,
+					Key:       view.Key,
+					Score:     score,
+					Failed:    failed,
+					Decoding:  view.Decoding,
+				})
+			}
+			if counters != nil {
+				counters[idx].evaluations.Add(1)
+				counters[idx].nanos.Add(int64(time.Since(start)))
+			}
+			if len(occurrences) > 0 {
+				matches = append(matches, ruleMatch{Index: idx, Occurrences: occurrences})
+			}
+		}
+	}
+	if len(views) == 1 {
+		return matches // Already in rule order
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Index < matches[j].Index })
+	merged := matches[:0]
+	for _, m := range matches {
+		if n := len(merged); n > 0 && merged[n-1].Index == m.Index {
+			merged[n-1].Occurrences = append(merged[n-1].Occurrences, m.Occurrences...)
+			continue
+		}
+		merged = append(merged, m)
+	}
+	for i := range merged {
+		merged[i].Occurrences = dropRedecoded(merged[i].Occurrences)
+	}
+	return merged
+}
+
+// dropRedecoded drops occurrences from decoded views that repeat a value
+// already found, e.g. a key the percent-decoded URL still contains as is
+func dropRedecoded(occurrences []Occurrence) []Occurrence {
+	decoded := false
+	for _, occ := range occurrences {
+		decoded = decoded || len(occ.Decoding) > 0
+	}
+	if !decoded {
+		return occurrences
+	}
+	type found struct {
+		component Component
+		key       string
+		value     string
+	}
+	seen := make(map[found]bool)
+	kept := occurrences[:0]
+	for _, occ := range occurrences {
+		f := found{occ.Component, occ.Key, occ.Value}
+		if len(occ.Decoding) > 0 && seen[f] {
+			continue
+		}
+		seen[f] = true
+		kept = append(kept, occ)
+	}
+	return kept
+}
+
+// candidates returns, in ascending order, the rules that may match text.
+// The returned slice is only valid until the scratch is reused.
+func (e *matchEngine) candidates(text string, c Component, rules *componentRules, scratch *engineScratch) []int {
+	// The automaton folds ASCII case only. The two non-ASCII runes that
+	// case-fold to ASCII letters (U+212A KELVIN SIGN ~ 'k', U+017F LONG S ~
+	// 's') could satisfy a (?i) literal unseen, so such texts are checked
+	// against every rule.
+	if hasUnicodeFoldHazard(text) {
+		if e.keywords == nil {
+			return rules.all
+		}
+		lower := strings.ToLower(text)
+		cands := scratch.candidates[:0]
+		for _, idx := range rules.all {
+			if e.keywords[idx] == nil || containsAny(lower, e.keywords[idx]) {
+				cands = append(cands, idx)
+			}
+		}
+		scratch.candidates = cands
+		return cands
+	}
+
+	scratch.generation++
+	if scratch.generation == 0 { // Wrapped around: reset marks
+		for i := range scratch.seen {
+			scratch.seen[i] = 0
+		}
+		scratch.generation = 1
+	}
+	gen := scratch.generation
+	cands := scratch.candidates[:0]
+	for _, idx := range rules.always {
+		scratch.seen[idx] = gen
+		cands = append(cands, idx)
+	}
+	e.automaton.scan(text, func(ruleIdxs []int) {
+		for _, idx := range ruleIdxs {
+			if scratch.seen[idx] != gen && scopeAppliesTo(e.patterns[idx].Scope, c) {
+				scratch.seen[idx] = gen
+				cands = append(cands, idx)
+			}
+		}
+	})
+	sort.Ints(cands)
+	if e.scanKeywords != nil {
+		var lower string
+		kept := cands[:0]
+		for _, idx := range cands {
+			if e.scanKeywords[idx] {
+				if lower == "" {
+					lower = strings.ToLower(text)
+				}
+				if !containsAny(lower, e.keywords[idx]) {
+					continue
+				}
+			}
+			kept = append(kept, idx)
+		}
+		cands = kept
+	}
+	scratch.candidates = cands
+	return cands
+}
+
+func hasUnicodeFoldHazard(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return strings.ContainsRune(s[i:], '\u212A') || strings.ContainsRune(s[i:], '\u017F')
+		}
+	}
+	return false
+}
+
+// ==============================================
+// LITERAL EXTRACTION
+// ==============================================
+
+// literalInfo describes what a regex node can match: either exactly one of
+// a small set of strings, or some string containing one of required.
+type literalInfo struct {
+	exact    []string
+	required []string
+}
+
+// keywordLiterals lowercases a rule's keywords, or returns nil if an empty
+// keyword lets every text through
+func keywordLiterals(keywords []string) []string {
+	var literals []string
+	for _, k := range keywords {
+		if k == "" {
+			return nil
+		}
+		literals = append(literals, strings.ToLower(k))
+	}
+	return literals
+}
+
+// asciiOnly reports whether the automaton, which folds ASCII case only, can
+// find the keywords
+func asciiOnly(keywords []string) bool {
+	for _, k := range keywords {
+		for i := 0; i < len(k); i++ {
+			if k[i] >= 0x80 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// requiredLiterals returns lowercase literals of which at least one occurs
+// in every match of the regex, or nil if no useful set exists.
+func requiredLiterals(expr string) []string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	info := analyzeLiterals(re.Simplify())
+	set := info.required
+	if info.exact != nil {
+		set = info.exact
+	}
+	if literalScore(set) < minLiteralSize {
+		return nil
+	}
+	return minimizeLiterals(set)
+}
+
+func analyzeLiterals(re *syntax.Regexp) literalInfo {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText,
+		syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return literalInfo{exact: []string{""}}
+
+	case syntax.OpLiteral:
+		lit, ok := foldLiteral(re.Rune, re.Flags&syntax.FoldCase != 0)
+		if !ok {
+			return literalInfo{}
+		}
+		return literalInfo{exact: []string{lit}}
+
+	case syntax.OpCharClass:
+		return literalInfo{exact: charClassLiterals(re.Rune)}
+
+	case syntax.OpCapture:
+		return analyzeLiterals(re.Sub[0])
+
+	case syntax.OpQuest:
+		sub := analyzeLiterals(re.Sub[0])
+		if sub.exact == nil || len(sub.exact) >= maxLiteralSet {
+			return literalInfo{}
+		}
+		return literalInfo{exact: dedupe(append([]string{""}, sub.exact...))}
+
+	case syntax.OpPlus:
+		return literalInfo{required: analyzeLiterals(re.Sub[0]).best()}
+
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return literalInfo{}
+		}
+		return literalInfo{required: analyzeLiterals(re.Sub[0]).best()}
+
+	case syntax.OpConcat:
+		return analyzeConcat(re.Sub)
+
+	case syntax.OpAlternate:
+		return analyzeAlternate(re.Sub)
+	}
+	// OpAnyChar, OpAnyCharNotNL, OpStar, OpNoMatch: nothing is required
+	return literalInfo{}
+}
+
+func analyzeConcat(subs []*syntax.Regexp) literalInfo {
+	current := []string{""}
+	allExact := true
+	var bestRequired []string
+	for _, sub := range subs {
+		info := analyzeLiterals(sub)
+		if info.exact != nil && len(current)*len(info.exact) <= maxLiteralSet {
+			current = crossProduct(current, info.exact)
+			continue
+		}
+		allExact = false
+		bestRequired = betterLiterals(bestRequired, current)
+		if info.exact != nil {
+			current = info.exact
+			continue
+		}
+		bestRequired = betterLiterals(bestRequired, info.required)
+		current = []string{""}
+	}
+	if allExact {
+		return literalInfo{exact: current}
+	}
+	return literalInfo{required: betterLiterals(bestRequired, current)}
+}
+
+func analyzeAlternate(subs []*syntax.Regexp) literalInfo {
+	var exact, required []string
+	allExact := true
+	for _, sub := range subs {
+		info := analyzeLiterals(sub)
+		if info.exact == nil {
+			allExact = false
+		}
+		set := info.best()
+		if set == nil {
+			return literalInfo{} // One branch can match anything
+		}
+		exact = append(exact, info.exact...)
+		required = append(required, set...)
+	}
+	if allExact && len(exact) <= maxLiteralSet {
+		return literalInfo{exact: dedupe(exact)}
+	}
+	if len(required) > maxLiteralSet {
+		return literalInfo{}
+	}
+	return literalInfo{required: dedupe(required)}
+}
+
+// best returns the most selective set describing the node
+func (info literalInfo) best() []string {
+	if info.exact != nil {
+		return info.exact
+	}
+	return info.required
+}
+
+// literalScore is the length of the shortest literal in the set (-1 for no
+// set); a set containing "" tells nothing about the text.
+func literalScore(set []string) int {
+	if set == nil {
+		return -1
+	}
+	score := -1
+	for _, lit := range set {
+		if score < 0 || len(lit) < score {
+			score = len(lit)
+		}
+	}
+	return score
+}
+
+func betterLiterals(a, b []string) []string {
+	if literalScore(b) > literalScore(a) {
+		return b
+	}
+	return a
+}
+
+func foldLiteral(runes []rune, foldCase bool) (string, bool) {
+	var sb strings.Builder
+	for _, r := range runes {
+		if r >= 0x80 && foldCase {
+			return "", false // Unicode folding is not modelled
+		}
+		if r >= 'A' && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), true
+}
+
+// charClassLiterals expands a small ASCII character class into its
+// (lowercased) members, or returns nil for large or non-ASCII classes.
+func charClassLiterals(ranges []rune) []string {
+	var members []string
+	seen := make(map[rune]bool)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if hi >= 0x80 || hi-lo >= maxLiteralSet {
+			return nil
+		}
+		for r := lo; r <= hi; r++ {
+			c := r
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			if !seen[c] {
+				seen[c] = true
+				members = append(members, string(c))
+			}
+		}
+		if len(members) > 16 {
+			return nil
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return members
+}
+
+func crossProduct(prefixes, suffixes []string) []string {
+	product := make([]string, 0, len(prefixes)*len(suffixes))
+	for _, p := range prefixes {
+		for _, s := range suffixes {
+			product = append(product, p+s)
+		}
+	}
+	return dedupe(product)
+}
+
+func dedupe(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	out := list[:0:0]
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// minimizeLiterals drops literals containing another literal of the set,
+// since the shorter one is found whenever the longer one is.
+func minimizeLiterals(set []string) []string {
+	sort.Slice(set, func(i, j int) bool { return len(set[i]) < len(set[j]) })
+	var kept []string
+	for _, lit := range set {
+		redundant := false
+		for _, k := range kept {
+			if strings.Contains(lit, k) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			kept = append(kept, lit)
+		}
+	}
+	return kept
+}
+
+// ==============================================
+// AHO-CORASICK AUTOMATON
+// ==============================================
+
+// ahoCorasick is a byte-level DFA over ASCII-lowercased input. Bytes that
+// never appear in a literal share one alphabet class to keep the table small.
+type ahoCorasick struct {
+	classes    [256]uint8
+	numClasses int
+	delta      []int32 // state*numClasses + class -> next state
+	outputs    [][]int // state -> rules whose literal ends here
+}
+
+func newAhoCorasick(literals []string, literalRules [][]int) *ahoCorasick {
+	ac := &ahoCorasick{numClasses: 1}
+	for _, lit := range literals {
+		for i := 0; i < len(lit); i++ {
+			if ac.classes[lit[i]] == 0 {
+				ac.classes[lit[i]] = uint8(ac.numClasses)
+				ac.numClasses++
+			}
+		}
+	}
+	// Upper-case ASCII reads as lower-case
+	for b := 'A'; b <= 'Z'; b++ {
+		ac.classes[b] = ac.classes[b+('a'-'A')]
+	}
+
+	nc := ac.numClasses
+	newState := func() int32 {
+		for i := 0; i < nc; i++ {
+			ac.delta = append(ac.delta, -1)
+		}
+		ac.outputs = append(ac.outputs, nil)
+		return int32(len(ac.outputs) - 1)
+	}
+	newState() // Root
+
+	for id, lit := range literals {
+		state := int32(0)
+		for i := 0; i < len(lit); i++ {
+			slot := int(state)*nc + int(ac.classes[lit[i]])
+			if ac.delta[slot] < 0 {
+				next := newState()
+				ac.delta[slot] = next
+			}
+			state = ac.delta[slot]
+		}
+		ac.outputs[state] = append(ac.outputs[state], literalRules[id]...)
+	}
+
+	// Breadth-first: compute failure links and turn the trie into a DFA
+	fail := make([]int32, len(ac.outputs))
+	var queue []int32
+	for c := 0; c < nc; c++ {
+		if next := ac.delta[c]; next > 0 {
+			queue = append(queue, next)
+		} else {
+			ac.delta[c] = 0
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if f := fail[state]; len(ac.outputs[f]) > 0 {
+			ac.outputs[state] = mergeRuleIndexes(ac.outputs[state], ac.outputs[f])
+		}
+		for c := 0; c < nc; c++ {
+			slot := int(state)*nc + c
+			fallback := ac.delta[int(fail[state])*nc+c]
+			if next := ac.delta[slot]; next >= 0 {
+				fail[next] = fallback
+				queue = append(queue, next)
+			} else {
+				ac.delta[slot] = fallback
+			}
+		}
+	}
+	return ac
+}
+
+func mergeRuleIndexes(a, b []int) []int {
+	merged := append([]int(nil), a...)
+	for _, idx := range b {
+		found := false
+		for _, existing := range a {
+			if existing == idx {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, idx)
+		}
+	}
+	return merged
+}
+
+// scan feeds text through the automaton, calling emit with the rules of
+// every literal found (possibly more than once per rule).
+func (ac *ahoCorasick) scan(text string, emit func(ruleIdxs []int)) {
+	if len(ac.outputs) == 1 {
+		return // No literals
+	}
+	nc := ac.numClasses
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = ac.delta[int(state)*nc+int(ac.classes[text[i]])]
+		if out := ac.outputs[state]; out != nil {
+			emit(out)
+		}
+	}
+}
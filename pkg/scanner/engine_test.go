@@ -0,0 +1,220 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// loadBundledRules loads every rule file of the repo's patterns directory
+func loadBundledRules(tb testing.TB) *RuleSet {
+	tb.Helper()
+	var paths []string
+	for _, glob := range []string{"*.txt", "*.toml"} {
+		matches, err := filepath.Glob(filepath.Join("..", "..", "patterns", glob))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	rules, err := LoadRules(paths, LoadOptions{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return rules
+}
+
+// engineCorpus returns the example URLs plus n URLs recombining their
+// hosts, paths and queries, so that most rules see matching and
+// non-matching texts
+func engineCorpus(tb testing.TB, n int) []string {
+	tb.Helper()
+	file, err := os.Open(filepath.Join("..", "..", "examples", "urls.txt"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+
+	var corpus, hosts, paths, queries []string
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		corpus = append(corpus, line)
+		if u, err := url.Parse(line); err == nil {
+			hosts = append(hosts, u.Scheme+"://"+u.Host)
+			paths = append(paths, u.EscapedPath())
+			queries = append(queries, u.RawQuery)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		tb.Fatal(err)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		u := hosts[random.Intn(len(hosts))] + paths[random.Intn(len(paths))]
+		if q := queries[random.Intn(len(queries))]; q != "" {
+			u += "?" + q
+		}
+		if i%7 == 0 {
+			u += fmt.Sprintf("&id=%d#section-%d", i, i%3)
+		}
+		corpus = append(corpus, u)
+	}
+	return corpus
+}
+
+// matchPerRule is the matching the engine replaces: every rule's regex on
+// every view it applies to, in rule order
+func matchPerRule(rules []Rule, views []ComponentView) []ruleMatch {
+	var matches []ruleMatch
+	for idx := range rules {
+		rule := &rules[idx]
+		var occurrences []Occurrence
+		for _, view := range views {
+			if !scopeAppliesTo(rule.Scope, view.Component) {
+				continue
+			}
+			if !hasKeyword(view.Value, rule.Keywords) {
+				continue
+			}
+			for _, loc := range rule.Compiled.FindAllStringIndex(view.Value, -1) {
+				occurrences = append(occurrences, Occurrence{
+					Value:     view.Value[loc[0]:loc[1]],
+					Start:     loc[0],
+					End:       loc[1],
+					Component: view.Component,
+					Key:       view.Key,
+				})
+			}
+		}
+		if occurrences != nil {
+			matches = append(matches, ruleMatch{Index: idx, Occurrences: occurrences})
+		}
+	}
+	return matches
+}
+
+// hasKeyword reports whether text contains one of keywords in any case, or
+// there are none
+func hasKeyword(text string, keywords []string) bool {
+	lower := strings.ToLower(text)
+	for _, k := range keywords {
+		if strings.Contains(lower, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return len(keywords) == 0
+}
+
+// matchPositions keeps what both matchers must agree on: which rules
+// matched, and where
+func matchPositions(matches []ruleMatch) []string {
+	var positions []string
+	for _, m := range matches {
+		for _, occ := range m.Occurrences {
+			positions = append(positions, fmt.Sprintf("%d %s:%s [%d:%d] %q", m.Index, occ.Component, occ.Key, occ.Start, occ.End, occ.Value))
+		}
+	}
+	return positions
+}
+
+func TestMatchEngineSameAsPerRule(t *testing.T) {
+	rules := loadBundledRules(t)
+	engine := newMatchEngine(rules.Rules)
+	matched := 0
+	for _, u := range engineCorpus(t, 5000) {
+		views := urlComponents(u)
+		got := matchPositions(engine.match(views, nil))
+		want := matchPositions(matchPerRule(rules.Rules, views))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s:\nengine:   %q\nper rule: %q", u, got, want)
+		}
+		if len(got) > 0 {
+			matched++
+		}
+	}
+	if matched == 0 {
+		t.Fatal("no URL of the corpus matched any rule")
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	for expr, want := range map[string][]string{
+		`tok_[a-z]{8}`: {"tok_"},
+		`NK_[A-Z]{6}`:  {"nk_"}, // Too many members: the class adds nothing
+		`[MNO]xy`:      {"mxy", "nxy", "oxy"},
+		`[A-C]{2}`:     {"aa", "ab", "ac", "ba", "bb", "bc", "ca", "cb", "cc"},
+		`x[MN]{2,3}`:   {"xmm", "xmn", "xnm", "xnn"},
+		`(?i)[MN]OP`:   {"mop", "nop"},
+		`[MN]`:         nil,
+	} {
+		if got := requiredLiterals(expr); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %q, want %q", expr, got, want)
+		}
+	}
+
+	// Uppercase-only classes must keep every member through the engine
+	rules := loadBundledRules(t)
+	engine := newMatchEngine(rules.Rules)
+	for _, u := range []string{"https://x.com/?k=A3TZ0123456789ABCDEF", "https://x.com/?k=A3TB0123456789ABCDEF"} {
+		views := urlComponents(u)
+		got, want := matchPositions(engine.match(views, nil)), matchPositions(matchPerRule(rules.Rules, views))
+		if len(want) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\nengine:   %q\nper rule: %q", u, got, want)
+		}
+	}
+}
+
+func BenchmarkMatchEngine(b *testing.B) {
+	rules := loadBundledRules(b)
+	corpus := engineCorpus(b, 10000)
+	engine := newMatchEngine(rules.Rules)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.match(urlComponents(corpus[i%len(corpus)]), nil)
+	}
+}
+
+func BenchmarkMatchPerRule(b *testing.B) {
+	rules := loadBundledRules(b)
+	corpus := engineCorpus(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchPerRule(rules.Rules, urlComponents(corpus[i%len(corpus)]))
+	}
+}
+
+func TestMatchEngineKeywords(t *testing.T) {
+	texts := []string{"https://x.com/?auth=tok_abcdefgh", "https://x.com/?Auth=tok_abcdefgh", "https://x.com/?q=tok_abcdefgh", "https://x.com/?ÄPI=tok_abcdefgh"}
+	// Non-ASCII keywords are checked outside the automaton, still in any case
+	for keywords, want := range map[string][]bool{
+		"AUTH":     {true, true, false, false},
+		"AUTH,äpi": {true, true, false, true},
+		"äpi":      {false, false, false, true},
+	} {
+		rules := []Rule{{ID: "kw", Regex: `tok_[a-z]{8}`, Keywords: SplitList(keywords)}}
+		rules[0].Compiled = regexp.MustCompile(rules[0].Regex)
+		engine := newMatchEngine(rules)
+		for i, text := range texts {
+			if got := len(engine.match(urlComponents(text), nil)) > 0; got != want[i] {
+				t.Errorf("keywords %s: %s matched %v, want %v", keywords, text, got, want[i])
+			}
+		}
+	}
+
+	// Keywords change what matches, so they are part of the hash
+	if textRules(t, "# No keywords", "tok_[a-z]{8}").Hash() == textRules(t, "#@ keywords: auth", "tok_[a-z]{8}").Hash() {
+		t.Error("rules differing only in keywords hash the same")
+	}
+}
@@ -0,0 +1,237 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ==============================================
+// CONTENT FETCHING
+// ==============================================
+// In fetch mode every worker GETs its URL and the rules are also applied to
+// the response headers (one "Name: value" line per header) and body, so
+// patterns written for JavaScript sources (js_secrets.txt) see real code.
+// The Fetcher is also usable on its own, e.g. by a crawler.
+
+const DefaultMaxBodySize = 5 << 20 // 5 MiB
+
+type FetchConfig struct {
+	Timeout       time.Duration
+	Headers       []string // Extra request headers in "Name: value" form
+	Proxy         string
+	MaxBodySize   int64
+	Insecure      bool   // Skip TLS certificate verification
+	TLSMinVersion string // "1.0" to "1.3"
+	RateLimit     float64
+	UserAgent     string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Fetcher GETs URLs with a shared client, headers and rate limit. It is safe
+// for concurrent use.
+type Fetcher struct {
+	client      *http.Client
+	headers     http.Header
+	maxBodySize int64
+	limiter     *time.Ticker // nil when --rate-limit is 0
+}
+
+// FetchResult is a response prepared for matching
+type FetchResult struct {
+	StatusCode  int
+	FinalURL    string // URL after redirects
+	ContentType string
+	Headers     []ComponentView
+	Body        string
+	Truncated   bool // Body was cut at MaxBodySize
+	Skipped     bool // Body not scanned (binary content type)
+}
+
+func NewFetcher(cfg FetchConfig) (*Fetcher, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS minimum version '%s' (use 1.0, 1.1, 1.2 or 1.3)", cfg.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL '%s'", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	headers := make(http.Header)
+	if cfg.UserAgent != "" {
+		headers.Set("User-Agent", cfg.UserAgent)
+	}
+	for _, h := range cfg.Headers {
+		name, value, found := strings.Cut(h, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("header '%s' must be in 'Name: value' form", h)
+		}
+		headers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	f := &Fetcher{
+		client:      &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		headers:     headers,
+		maxBodySize: cfg.MaxBodySize,
+	}
+	if f.maxBodySize <= 0 {
+		f.maxBodySize = DefaultMaxBodySize
+	}
+	if cfg.RateLimit > 0 {
+		interval := time.Duration(float64(time.Second) / cfg.RateLimit)
+		if interval <= 0 {
+			interval = 1 // Above 1e9 requests per second the division truncates to 0, which NewTicker rejects
+		}
+		f.limiter = time.NewTicker(interval)
+	}
+	return f, nil
+}
+
+func (f *Fetcher) Close() {
+	if f.limiter != nil {
+		f.limiter.Stop()
+	}
+	f.client.CloseIdleConnections()
+}
+
+// Fetch GETs rawURL, waiting for the rate limiter first
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*FetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range f.headers {
+		req.Header[name] = values
+	}
+	if f.limiter != nil {
+		select {
+		case <-f.limiter.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &FetchResult{
+		StatusCode:  resp.StatusCode,
+		FinalURL:    resp.Request.URL.String(),
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range resp.Header[name] {
+			result.Headers = append(result.Headers, ComponentView{
+				Component: ComponentHeader,
+				Key:       name,
+				Value:     name + ": " + value,
+			})
+		}
+	}
+
+	if isBinaryContentType(result.ContentType) {
+		result.Skipped = true
+		return result, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	if int64(len(body)) > f.maxBodySize {
+		body = body[:f.maxBodySize]
+		result.Truncated = true
+	}
+	result.Body = string(body)
+	return result, nil
+}
+
+// Views returns the header and body views to match rules against
+func (r *FetchResult) Views() []ComponentView {
+	views := append([]ComponentView(nil), r.Headers...)
+	if r.Body != "" {
+		views = append(views, ComponentView{Component: ComponentBody, Value: r.Body})
+	}
+	return views
+}
+
+func isBinaryContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) && mediaType != "image/svg+xml" {
+			return true
+		}
+	}
+	switch mediaType {
+	case "application/octet-stream", "application/zip", "application/gzip", "application/pdf", "application/wasm":
+		return true
+	}
+	return false
+}
+
+// ==============================================
+// BODY LOCATIONS
+// ==============================================
+
+// lineIndex maps byte offsets in a body to 1-based line and column
+// numbers. Columns count characters (code points), as editors do.
+type lineIndex []int // Offsets of each line start
+
+func newLineIndex(text string) lineIndex {
+	idx := lineIndex{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			idx = append(idx, i+1)
+		}
+	}
+	return idx
+}
+
+func (idx lineIndex) locate(text string, offset int) (line, column int) {
+	line = sort.Search(len(idx), func(i int) bool { return idx[i] > offset })
+	return line, utf8.RuneCountInString(text[idx[line-1]:offset]) + 1
+}
+
+// locateBodyOccurrences fills Line/Column for occurrences found in the body
+func locateBodyOccurrences(occurrences []Occurrence, body string, idx *lineIndex) {
+	for i := range occurrences {
+		if occurrences[i].Component != ComponentBody {
+			continue
+		}
+		if *idx == nil {
+			*idx = newLineIndex(body)
+		}
+		occurrences[i].Line, occurrences[i].Column = idx.locate(body, occurrences[i].Start)
+	}
+}
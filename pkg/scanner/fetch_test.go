@@ -0,0 +1,183 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// textRules builds a RuleSet from lines of a .txt pattern file
+func textRules(t *testing.T, lines ...string) *RuleSet {
+	t.Helper()
+	fail := func(line int, msg string) { t.Fatalf("line %d: %s", line, msg) }
+	rules, err := parseRuleFile(strings.NewReader(strings.Join(lines, "\n")), "test.txt", fail, fail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRuleSet(rules)
+}
+
+func newTestFetcher(t *testing.T, cfg FetchConfig) *Fetcher {
+	t.Helper()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	f, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestFetchViews(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/app.js", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("X-Debug-Token", r.Header.Get("Authorization"))
+		fmt.Fprint(w, "var a = 1;\nvar key = 'AKIA0123456789ABCDEF';\n")
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, FetchConfig{Headers: []string{"Authorization: Bearer t0k3n"}, UserAgent: "CodeHunter/test"})
+	result, err := f.Fetch(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != http.StatusOK || result.FinalURL != server.URL+"/app.js" {
+		t.Errorf("status %d, final URL %s", result.StatusCode, result.FinalURL)
+	}
+
+	var header, body bool
+	for _, view := range result.Views() {
+		switch {
+		case view.Component == ComponentHeader && view.Key == "X-Debug-Token":
+			header = view.Value == "X-Debug-Token: Bearer t0k3n"
+		case view.Component == ComponentBody:
+			body = strings.Contains(view.Value, "AKIA0123456789ABCDEF")
+		}
+	}
+	if !header || !body {
+		t.Errorf("header view found: %v, body view found: %v in %+v", header, body, result.Views())
+	}
+}
+
+func TestScanBodyLocation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "first line\n  kéy = AKIA0123456789ABCDEF\n") // Column 9, byte 10
+	}))
+	defer server.Close()
+
+	s, err := New(Options{Rules: textRules(t, "#@ scope: body", "AKIA[0-9A-Z]{16}"), Fetch: true, FetchConfig: FetchConfig{Timeout: 5 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	result := s.ScanURL(context.Background(), server.URL)
+	if result.FetchErr != nil {
+		t.Fatal(result.FetchErr)
+	}
+	if len(result.Matches) != 1 || len(result.Matches[0].Occurrences) != 1 {
+		t.Fatalf("matches: %+v", result.Matches)
+	}
+	occ := result.Matches[0].Occurrences[0]
+	if occ.Component != ComponentBody || occ.Line != 2 || occ.Column != 9 {
+		t.Errorf("got %s at line %d column %d, want body at line 2 column 9", occ.Component, occ.Line, occ.Column)
+	}
+}
+
+func TestFetchMaxBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, FetchConfig{MaxBodySize: 10})
+	result, err := f.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Truncated || len(result.Body) != 10 {
+		t.Errorf("truncated %v, %d body bytes, want 10", result.Truncated, len(result.Body))
+	}
+}
+
+func TestFetchSkipsBinary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		fmt.Fprint(w, "AKIA0123456789ABCDEF")
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, FetchConfig{})
+	for contentType, skipped := range map[string]bool{
+		"image/png":                true,
+		"application/octet-stream": true,
+		"image/svg+xml":            false,
+		"text/html; charset=utf-8": false,
+	} {
+		result, err := f.Fetch(context.Background(), server.URL+"/?type="+url.QueryEscape(contentType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Skipped != skipped || (result.Body == "") != skipped {
+			t.Errorf("%s: skipped %v with %d body bytes, want skipped %v", contentType, result.Skipped, len(result.Body), skipped)
+		}
+	}
+}
+
+func TestFetchProxy(t *testing.T) {
+	var requested atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(r.URL.String())
+		fmt.Fprint(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	f := newTestFetcher(t, FetchConfig{Proxy: proxy.URL})
+	result, err := f.Fetch(context.Background(), "http://target.invalid/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := requested.Load().(string); got != "http://target.invalid/app.js" || result.Body != "proxied" {
+		t.Errorf("proxy saw %q and returned %q", got, result.Body)
+	}
+
+	if _, err := NewFetcher(FetchConfig{Proxy: "not a proxy"}); err == nil {
+		t.Error("invalid proxy URL accepted")
+	}
+}
+
+func TestFetchRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, FetchConfig{RateLimit: 20}) // One request per 50ms
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := f.Fetch(context.Background(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("5 requests at 20/s took %v", elapsed)
+	}
+	if requests.Load() != 5 {
+		t.Errorf("server saw %d requests, want 5", requests.Load())
+	}
+
+	// Rates whose interval truncates to 0 must not panic
+	newTestFetcher(t, FetchConfig{RateLimit: 1e12})
+}
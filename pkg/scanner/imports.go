@@ -0,0 +1,663 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ==============================================
+// RULES FROM OTHER TOOLS
+// ==============================================
+// Rule files written for other scanners load like CodeHunter's own:
+//
+//	.toml with [[rules]]             gitleaks configuration
+//	.yaml/.yml with detectors:       trufflehog custom detectors
+//	.yaml/.yml with id: and info:    nuclei template (regex extractors and matchers)
+//
+// IDs, names, keywords, tags, entropy thresholds and allowlists carry over.
+// Entropy becomes an entropy= validator, allowlist regexes allowlist= and
+// stopwords stopwords=, so an allowlisted occurrence is not reported, as in
+// the source tool. What has no meaning for URLs, such as file paths, commits
+// and verification endpoints, is reported and dropped. ExportGitleaks
+// writes rules back as a gitleaks configuration.
+
+func isYAMLPatternFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// parseYAMLRules reads a trufflehog detector file or a nuclei template
+func parseYAMLRules(r io.Reader, usedPath, sourceName string, warn, notice func(line int, msg string)) ([]Rule, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseYAML(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := parsed.(map[string]any)
+	switch {
+	case ok && doc["detectors"] != nil:
+		return parseTrufflehogRules(doc, usedPath, sourceName, warn, notice)
+	case ok && doc["id"] != nil && doc["info"] != nil:
+		return parseNucleiRules(doc, usedPath, sourceName, warn, notice)
+	}
+	return nil, fmt.Errorf("neither a trufflehog detector file (detectors:) nor a nuclei template (id: and info:)")
+}
+
+// ==============================================
+// GITLEAKS
+// ==============================================
+
+// gitleaksAllowlist is an allowlist as far as it applies to URLs
+type gitleaksAllowlist struct {
+	validators  []Validator
+	targetRules []string // Rule IDs a global allowlist is limited to
+}
+
+// parseGitleaksRules converts the [[rules]] of a gitleaks configuration.
+// The rules are secret detectors, so they default to high severity.
+func parseGitleaksRules(doc map[string]any, usedPath, sourceName string, warn, notice func(line int, msg string)) ([]Rule, error) {
+	defaults := defaultsForSource(sourceName)
+	defaults.Severity = SeverityHigh
+	if _, ok := doc["extend"]; ok {
+		notice(0, fmt.Sprintf("[extend] in '%s' is not supported; only the rules of this file are loaded.", usedPath))
+	}
+
+	noted := make(map[string]bool) // Each feature is reported once per file
+	note := func(line int, feature, consequence string) {
+		if !noted[feature] {
+			noted[feature] = true
+			notice(line, fmt.Sprintf("Gitleaks %s in '%s': %s.", feature, usedPath, consequence))
+		}
+	}
+	var global []gitleaksAllowlist
+	for _, key := range []string{"allowlist", "allowlists"} {
+		tables, err := tomlTables(doc, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			allowlist, err := parseGitleaksAllowlist(table, note)
+			if err != nil {
+				return nil, fmt.Errorf("global %s (line %d): %v", key, tomlTableLine(table), err)
+			}
+			global = append(global, allowlist)
+		}
+	}
+
+	tables, err := tomlTables(doc, "rules")
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	for i, table := range tables {
+		line := tomlTableLine(table)
+		rule, err := ruleFromGitleaks(table, defaults, global, note)
+		if err != nil {
+			warn(line, fmt.Sprintf("Rule #%d in '%s': %v. Skipping.", i+1, usedPath, err))
+			continue
+		}
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("%s:%d", defaults.Category, i+1)
+		}
+		rule.SourceFile = sourceName
+		rule.Line = line
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func ruleFromGitleaks(table map[string]any, defaults ruleDefaults, global []gitleaksAllowlist, note func(line int, feature, consequence string)) (Rule, error) {
+	line := tomlTableLine(table)
+	p := Rule{Severity: defaults.Severity, Confidence: defaults.Confidence, Category: defaults.Category}
+	var err error
+	if p.ID, err = tomlString(table, "id"); err != nil {
+		return p, err
+	}
+	if p.Name, err = tomlString(table, "description"); err != nil {
+		return p, err
+	}
+	if p.Regex, err = tomlString(table, "regex"); err != nil {
+		return p, err
+	}
+	filePath, err := tomlString(table, "path")
+	if err != nil {
+		return p, err
+	}
+	switch {
+	case p.Regex == "" && filePath != "":
+		return p, fmt.Errorf("'%s' only matches file paths", p.ID)
+	case p.Regex == "":
+		return p, fmt.Errorf("missing 'regex'")
+	case filePath != "":
+		note(line, "rule 'path'", "no meaning for URLs. Ignoring")
+	}
+	if p.Keywords, err = tomlStringList(table, "keywords"); err != nil {
+		return p, err
+	}
+	if p.Tags, err = tomlStringList(table, "tags"); err != nil {
+		return p, err
+	}
+
+	group, _, err := tomlNumber(table, "secretGroup")
+	if err != nil {
+		return p, err
+	}
+	if p.Regex, p.Compiled, err = compileWithSecretGroup(p.Regex, int(group)); err != nil {
+		return p, err
+	}
+	if entropy, ok, err := tomlNumber(table, "entropy"); err != nil {
+		return p, err
+	} else if ok && entropy > 0 {
+		v, err := ParseValidator("entropy=" + strconv.FormatFloat(entropy, 'f', -1, 64))
+		if err != nil {
+			return p, err
+		}
+		p.Validators = append(p.Validators, v)
+	}
+
+	var own []map[string]any
+	for _, key := range []string{"allowlist", "allowlists"} {
+		tables, err := tomlTables(table, key)
+		if err != nil {
+			return p, err
+		}
+		own = append(own, tables...)
+	}
+	for _, t := range own {
+		allowlist, err := parseGitleaksAllowlist(t, note)
+		if err != nil {
+			return p, fmt.Errorf("allowlist (line %d): %v", tomlTableLine(t), err)
+		}
+		p.Validators = append(p.Validators, allowlist.validators...)
+	}
+	for _, allowlist := range global {
+		if len(allowlist.targetRules) == 0 || containsString(allowlist.targetRules, p.ID) {
+			p.Validators = append(p.Validators, allowlist.validators...)
+		}
+	}
+	return p, nil
+}
+
+func parseGitleaksAllowlist(table map[string]any, note func(line int, feature, consequence string)) (gitleaksAllowlist, error) {
+	var a gitleaksAllowlist
+	line := tomlTableLine(table)
+	regexes, err := tomlStringList(table, "regexes")
+	if err != nil {
+		return a, err
+	}
+	stopwords, err := tomlStringList(table, "stopwords")
+	if err != nil {
+		return a, err
+	}
+	if a.targetRules, err = tomlStringList(table, "targetRules"); err != nil {
+		return a, err
+	}
+	for _, key := range []string{"paths", "commits"} {
+		if list, err := tomlStringList(table, key); err != nil {
+			return a, err
+		} else if len(list) > 0 {
+			note(line, fmt.Sprintf("allowlist '%s'", key), "no meaning for URLs. Ignoring")
+		}
+	}
+	if target, err := tomlString(table, "regexTarget"); err != nil {
+		return a, err
+	} else if target != "" && target != "secret" {
+		note(line, fmt.Sprintf("allowlist regexTarget '%s'", target), "not supported. Its regexes are checked against the secret")
+	}
+	if condition, err := tomlString(table, "condition"); err != nil {
+		return a, err
+	} else if strings.EqualFold(condition, "and") && len(regexes)+len(stopwords) > 1 {
+		note(line, "allowlist condition 'AND'", "not supported. Each regex and stopword applies on its own")
+	}
+
+	for _, re := range regexes {
+		v, err := ParseValidator("allowlist=" + re)
+		if err != nil {
+			return a, err
+		}
+		a.validators = append(a.validators, v)
+	}
+	if len(stopwords) > 0 {
+		v, err := ParseValidator("stopwords=" + strings.Join(stopwords, "|"))
+		if err != nil {
+			return a, err
+		}
+		a.validators = append(a.validators, v)
+	}
+	return a, nil
+}
+
+// tomlNumber reads an integer or float, reporting whether it was set
+func tomlNumber(table map[string]any, key string) (float64, bool, error) {
+	switch v := table[key].(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return float64(v), true, nil
+	case float64:
+		return v, true, nil
+	default:
+		return 0, false, fmt.Errorf("'%s' must be a number", key)
+	}
+}
+
+// ==============================================
+// TRUFFLEHOG
+// ==============================================
+
+// parseTrufflehogRules converts trufflehog custom detectors. A detector
+// with several regexes requires all of them to match; here each becomes a
+// rule of its own, "<detector>.<regex name>".
+func parseTrufflehogRules(doc map[string]any, usedPath, sourceName string, warn, notice func(line int, msg string)) ([]Rule, error) {
+	defaults := defaultsForSource(sourceName)
+	detectors, err := yamlMaps(doc, "detectors")
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	verifyNoted := false
+	for i, detector := range detectors {
+		line := yamlMapLine(detector)
+		if detector["verify"] != nil && !verifyNoted {
+			verifyNoted = true
+			notice(line, fmt.Sprintf("Trufflehog verify endpoints in '%s' are not called. Ignoring.", usedPath))
+		}
+		converted, err := rulesFromTrufflehog(detector, defaults)
+		if err != nil {
+			warn(line, fmt.Sprintf("Detector #%d (line %d) in '%s': %v. Skipping.", i+1, line, usedPath, err))
+			continue
+		}
+		for j := range converted {
+			converted[j].SourceFile = sourceName
+			converted[j].Line = line
+		}
+		rules = append(rules, converted...)
+	}
+	return rules, nil
+}
+
+func rulesFromTrufflehog(detector map[string]any, defaults ruleDefaults) ([]Rule, error) {
+	name, err := yamlString(detector, "name")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("missing 'name'")
+	}
+	keywords, err := yamlStringList(detector, "keywords")
+	if err != nil {
+		return nil, err
+	}
+	regexes, ok := detector["regex"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'regex' must map names to regexes")
+	}
+
+	var validators []Validator
+	if entropy, err := yamlString(detector, "entropy"); err != nil {
+		return nil, err
+	} else if entropy != "" {
+		v, err := ParseValidator("entropy=" + entropy)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+	}
+	if words, err := yamlStringList(detector, "exclude_words"); err != nil {
+		return nil, err
+	} else if len(words) > 0 {
+		v, err := ParseValidator("stopwords=" + strings.Join(words, "|"))
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+	}
+	for _, key := range []string{"exclude_regexes_capture", "exclude_regexes_match"} {
+		list, err := yamlStringList(detector, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, re := range list {
+			v, err := ParseValidator("allowlist=" + re)
+			if err != nil {
+				return nil, err
+			}
+			validators = append(validators, v)
+		}
+	}
+
+	var names []string
+	for key := range regexes {
+		if key != yamlLineKey {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no regexes")
+	}
+	var rules []Rule
+	for _, key := range names {
+		expr, ok := regexes[key].(string)
+		if !ok {
+			return nil, fmt.Errorf("regex '%s' must be a string", key)
+		}
+		p := Rule{
+			ID:         name,
+			Name:       name,
+			Severity:   SeverityHigh,
+			Confidence: defaults.Confidence,
+			Category:   defaults.Category,
+			Keywords:   keywords,
+			Validators: validators,
+		}
+		if len(names) > 1 {
+			p.ID = name + "." + key
+		}
+		// trufflehog reports the first capture group
+		if p.Regex, p.Compiled, err = compileWithSecretGroup(expr, 0); err != nil {
+			return nil, fmt.Errorf("regex '%s': %v", key, err)
+		}
+		rules = append(rules, p)
+	}
+	return rules, nil
+}
+
+// ==============================================
+// NUCLEI
+// ==============================================
+
+// nucleiProtocols are the request blocks of a template, in the order
+// their regexes are numbered
+var nucleiProtocols = []string{"http", "requests", "file", "network", "tcp", "dns", "headless", "ssl", "websocket", "whois", "javascript", "code"}
+
+// parseNucleiRules converts the regex extractors and matchers of a nuclei
+// template. The template's severity, tags and references apply to all of
+// them; a template with several regexes gets IDs "<id>:1", "<id>:2", ...
+// in file order, each matching on its own whatever matchers-condition says.
+func parseNucleiRules(doc map[string]any, usedPath, sourceName string, warn, notice func(line int, msg string)) ([]Rule, error) {
+	id, err := yamlString(doc, "id")
+	if err != nil {
+		return nil, err
+	}
+	info, ok := doc["info"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("'info' must be a mapping")
+	}
+	defaults := defaultsForSource(sourceName)
+	base := Rule{Severity: defaults.Severity, Confidence: defaults.Confidence, Category: defaults.Category}
+	if base.Name, err = yamlString(info, "name"); err != nil {
+		return nil, err
+	}
+	if severity, err := yamlString(info, "severity"); err != nil {
+		return nil, err
+	} else if severity != "" && !strings.EqualFold(severity, "unknown") {
+		if base.Severity, err = ParseSeverity(severity); err != nil {
+			return nil, err
+		}
+	}
+	if base.Tags, err = yamlStringList(info, "tags"); err != nil {
+		return nil, err
+	}
+	if base.References, err = yamlStringList(info, "reference"); err != nil {
+		return nil, err
+	}
+
+	type nucleiRegex struct {
+		expr  string
+		group int
+		line  int
+	}
+	var found []nucleiRegex
+	for _, protocol := range nucleiProtocols {
+		blocks, err := yamlMaps(doc, protocol)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			for _, kind := range []string{"extractors", "matchers"} {
+				items, err := yamlMaps(block, kind)
+				if err != nil {
+					return nil, err
+				}
+				for _, item := range items {
+					if t, _ := yamlString(item, "type"); t != "regex" {
+						continue
+					}
+					line := yamlMapLine(item)
+					if negative, _ := yamlString(item, "negative"); negative == "true" {
+						notice(line, fmt.Sprintf("Negative matcher (line %d) in '%s' has no CodeHunter equivalent. Ignoring.", line, usedPath))
+						continue
+					}
+					group := 0
+					if value, _ := yamlString(item, "group"); value != "" && kind == "extractors" {
+						if group, err = strconv.Atoi(value); err != nil || group < 0 {
+							return nil, fmt.Errorf("line %d: 'group' must be a group number", line)
+						}
+					}
+					exprs, err := yamlStringList(item, "regex")
+					if err != nil {
+						return nil, err
+					}
+					for _, expr := range exprs {
+						found = append(found, nucleiRegex{expr, group, line})
+					}
+				}
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("template '%s' has no regex extractors or matchers", id)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].line < found[j].line })
+
+	var rules []Rule
+	for i, re := range found {
+		p := base
+		p.ID, p.SourceFile, p.Line = id, sourceName, re.line
+		if len(found) > 1 {
+			p.ID = fmt.Sprintf("%s:%d", id, i+1)
+		}
+		if re.group > 0 {
+			p.Regex, p.Compiled, err = compileWithSecretGroup(re.expr, re.group)
+		} else {
+			p.Regex = re.expr
+			p.Compiled, err = regexp.Compile(re.expr)
+		}
+		if err != nil {
+			warn(re.line, fmt.Sprintf("Invalid regex (line %d) in '%s': '%s' (%v). Skipping.", re.line, usedPath, re.expr, err))
+			continue
+		}
+		rules = append(rules, p)
+	}
+	return rules, nil
+}
+
+// ==============================================
+// SECRET GROUPS
+// ==============================================
+
+// compileWithSecretGroup compiles expr after naming capture group n
+// "secret", which is what validators check. With n = 0 the first group is
+// named, if there is one, as gitleaks and trufflehog report it. A regex
+// that already has a "secret" group is left as is.
+func compileWithSecretGroup(expr string, n int) (string, *regexp.Regexp, error) {
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid regex '%s' (%v)", expr, err)
+	}
+	if compiled.SubexpIndex("secret") >= 0 || (n == 0 && compiled.NumSubexp() == 0) {
+		return expr, compiled, nil
+	}
+	if n == 0 {
+		n = 1
+	}
+	if n > compiled.NumSubexp() {
+		return "", nil, fmt.Errorf("secret group %d does not exist in '%s'", n, expr)
+	}
+	named, ok := nameGroup(expr, n, "secret")
+	if !ok {
+		return expr, compiled, nil
+	}
+	renamed, err := regexp.Compile(named)
+	if err != nil || renamed.SubexpIndex("secret") != n {
+		return expr, compiled, nil // Keep the regex as written rather than guess
+	}
+	return named, renamed, nil
+}
+
+// nameGroup gives capture group n of expr the name, replacing the name it
+// already has. It skips escapes and character classes to count groups.
+func nameGroup(expr string, n int, name string) (string, bool) {
+	count := 0
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\' && strings.HasPrefix(expr[i:], `\Q`):
+			end := strings.Index(expr[i:], `\E`)
+			if end < 0 {
+				return "", false
+			}
+			i += end + 1
+		case c == '\\':
+			i++
+		case inClass && strings.HasPrefix(expr[i:], "[:"):
+			if end := strings.Index(expr[i:], ":]"); end >= 0 {
+				i += end + 1
+			}
+		case c == '[' && !inClass:
+			inClass = true
+			if strings.HasPrefix(expr[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(expr[i+1:], "]") {
+				i++
+			}
+		case c == ']' && inClass:
+			inClass = false
+		case c == '(' && !inClass:
+			rest := expr[i+1:]
+			var nameEnd int
+			switch {
+			case strings.HasPrefix(rest, "?P<"), strings.HasPrefix(rest, "?<"):
+				nameEnd = strings.IndexByte(rest, '>') + 1
+				if nameEnd == 0 {
+					return "", false
+				}
+			case strings.HasPrefix(rest, "?"):
+				continue
+			}
+			if count++; count == n {
+				return expr[:i+1] + "?P<" + name + ">" + rest[nameEnd:], true
+			}
+		}
+	}
+	return "", false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ==============================================
+// GITLEAKS EXPORT
+// ==============================================
+
+// ExportGitleaks writes rules as a gitleaks configuration. It returns what
+// could not be carried over: scopes, and validators other than entropy=,
+// stopwords= and deny= (stopwords), and allowlist= and exclude= (allowlist
+// regexes). Severity, confidence and category have no gitleaks field and are
+// dropped silently.
+func ExportGitleaks(w io.Writer, rules []Rule, title string) ([]string, error) {
+	var dropped []string
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# Generated by CodeHunter from %d rules\n", len(rules))
+	fmt.Fprintf(out, "title = %s\n", tomlQuote(title))
+
+	ids := make(map[string]int)
+	for _, r := range rules {
+		id := r.ID
+		if ids[r.ID]++; ids[r.ID] > 1 {
+			id = fmt.Sprintf("%s-%d", r.ID, ids[r.ID])
+		}
+		fmt.Fprintf(out, "\n[[rules]]\nid = %s\n", tomlQuote(id))
+		if r.Name != "" {
+			fmt.Fprintf(out, "description = %s\n", tomlQuote(r.Name))
+		}
+		fmt.Fprintf(out, "regex = %s\n", tomlQuote(r.Regex))
+		if group := r.Compiled.SubexpIndex("secret"); group > 0 {
+			fmt.Fprintf(out, "secretGroup = %d\n", group)
+		}
+		if len(r.Scope) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%s: scope %s (gitleaks rules match whole files)", r.ID, formatScope(r.Scope)))
+		}
+
+		entropy := 0.0
+		var regexes, stopwords []string
+		for _, v := range r.Validators {
+			name, arg, _ := strings.Cut(v.Spec, "=")
+			name, arg = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(arg)
+			switch name {
+			case "entropy":
+				if e, err := strconv.ParseFloat(arg, 64); err == nil && e > entropy {
+					entropy = e
+				}
+			case "allowlist", "exclude":
+				regexes = append(regexes, arg)
+			case "stopwords", "deny":
+				for _, word := range strings.Split(arg, "|") {
+					if word = strings.TrimSpace(word); word != "" {
+						stopwords = append(stopwords, word)
+					}
+				}
+			default:
+				dropped = append(dropped, fmt.Sprintf("%s: validator '%s'", r.ID, v.Spec))
+			}
+		}
+		if entropy > 0 {
+			fmt.Fprintf(out, "entropy = %s\n", strconv.FormatFloat(entropy, 'f', -1, 64))
+		}
+		if len(r.Keywords) > 0 {
+			keywords := make([]string, len(r.Keywords))
+			for i, k := range r.Keywords {
+				keywords[i] = strings.ToLower(k) // gitleaks compares lowercased keywords
+			}
+			fmt.Fprintf(out, "keywords = %s\n", tomlQuoteList(dedupe(keywords)))
+		}
+		if len(r.Tags) > 0 {
+			fmt.Fprintf(out, "tags = %s\n", tomlQuoteList(r.Tags))
+		}
+		if len(regexes) > 0 || len(stopwords) > 0 {
+			fmt.Fprintf(out, "[rules.allowlist]\n")
+			if len(regexes) > 0 {
+				fmt.Fprintf(out, "regexes = %s\n", tomlQuoteList(regexes))
+			}
+			if len(stopwords) > 0 {
+				fmt.Fprintf(out, "stopwords = %s\n", tomlQuoteList(stopwords))
+			}
+		}
+	}
+	return dropped, out.Flush()
+}
+
+func formatScope(scope []Component) string {
+	names := make([]string, len(scope))
+	for i, c := range scope {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ",")
+}
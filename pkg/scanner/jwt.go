@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==============================================
+// JWT ANALYSIS
+// ==============================================
+// Tokens in token=/id_token= parameters are worth more than their raw
+// string. Any occurrence holding a JWT gets its header and claims decoded,
+// offline: signatures are not checked, there is no key to check them with.
+
+// JWT issues
+const (
+	JWTAlgNone         = "alg-none"         // Unsigned token, accepted by misconfigured verifiers
+	JWTExpired         = "expired"          // exp is before the scan
+	JWTNoExpiry        = "no-expiry"        // No exp claim: valid until the key rotates
+	JWTSensitiveClaims = "sensitive-claims" // Personal data or permissions in the claims
+)
+
+// JWTInfo is the decoded form of a JWT found in an occurrence
+type JWTInfo struct {
+	Algorithm string         `json:"alg"`
+	Header    map[string]any `json:"header"`
+	Claims    map[string]any `json:"claims"`
+	IssuedAt  *time.Time     `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	Issues    []string       `json:"issues,omitempty"`
+	Sensitive []string       `json:"sensitive_claims,omitempty"` // Claim names, sorted
+}
+
+// sensitiveClaims are claims that identify a person or grant permissions.
+// Namespaced claims (e.g. "https://example.com/roles") match by suffix.
+var sensitiveClaims = []string{
+	"email", "phone_number", "name", "given_name", "family_name", "birthdate", "address",
+	"role", "roles", "groups", "scope", "scp", "scopes", "permissions", "admin", "is_admin",
+	"entitlements",
+}
+
+// AnalyzeJWT decodes the first JWT in value and flags its issues, judging
+// expiry at now. It returns nil if value holds no JWT.
+func AnalyzeJWT(value string, now time.Time) *JWTInfo {
+	if !strings.Contains(value, "ey") { // Every JSON object encodes to "ey..."
+		return nil
+	}
+	for _, candidate := range jwtCandidates(value) {
+		if info := parseJWT(candidate); info != nil {
+			info.analyze(now)
+			return info
+		}
+	}
+	return nil
+}
+
+// jwtCandidates returns the runs of base64url characters and dots in value
+// that have the three parts of a JWT
+func jwtCandidates(value string) []string {
+	var candidates []string
+	start := -1
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) && isJWTChar(value[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			// An unsigned token ends with its last dot: "header.claims."
+			if run := strings.TrimLeft(value[start:i], "."); strings.Count(run, ".") == 2 {
+				candidates = append(candidates, run)
+			}
+			start = -1
+		}
+	}
+	return candidates
+}
+
+func isJWTChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '='
+}
+
+// parseJWT decodes a compact JWS. The header must name an algorithm and
+// the payload must be a JSON object.
+func parseJWT(token string) *JWTInfo {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	info := &JWTInfo{}
+	if decodeJWTPart(parts[0], &info.Header) != nil || decodeJWTPart(parts[1], &info.Claims) != nil {
+		return nil
+	}
+	alg, _ := info.Header["alg"].(string)
+	if alg == "" || info.Claims == nil {
+		return nil
+	}
+	info.Algorithm = alg
+	return info
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (j *JWTInfo) analyze(now time.Time) {
+	j.IssuedAt = claimTime(j.Claims["iat"])
+	j.ExpiresAt = claimTime(j.Claims["exp"])
+	if strings.EqualFold(j.Algorithm, "none") {
+		j.Issues = append(j.Issues, JWTAlgNone)
+	}
+	switch {
+	case j.ExpiresAt == nil:
+		j.Issues = append(j.Issues, JWTNoExpiry)
+	case j.ExpiresAt.Before(now):
+		j.Issues = append(j.Issues, JWTExpired)
+	}
+	for claim := range j.Claims {
+		name := strings.ToLower(claim)
+		if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+			name = name[i+1:]
+		}
+		if containsFold(sensitiveClaims, name) {
+			j.Sensitive = append(j.Sensitive, claim)
+		}
+	}
+	if len(j.Sensitive) > 0 {
+		sort.Strings(j.Sensitive)
+		j.Issues = append(j.Issues, JWTSensitiveClaims)
+	}
+}
+
+// claimTime reads a NumericDate claim (seconds since the epoch)
+func claimTime(value any) *time.Time {
+	seconds, ok := value.(float64)
+	if !ok || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return nil
+	}
+	t := time.Unix(int64(seconds), 0).UTC()
+	return &t
+}
+
+// HasIssue reports whether the analysis flagged issue
+func (j *JWTInfo) HasIssue(issue string) bool {
+	for _, i := range j.Issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// String summarizes the token for logs, e.g.
+// "JWT alg=HS256 sub=42 exp=2024-05-01T00:00:00Z [expired, sensitive claims: email, roles]"
+func (j *JWTInfo) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "JWT alg=%s", j.Algorithm)
+	for _, claim := range []string{"iss", "sub"} {
+		if value, ok := j.Claims[claim]; ok {
+			fmt.Fprintf(&sb, " %s=%v", claim, value)
+		}
+	}
+	if j.ExpiresAt != nil {
+		fmt.Fprintf(&sb, " exp=%s", j.ExpiresAt.Format(time.RFC3339))
+	}
+	var notes []string
+	for _, issue := range j.Issues {
+		switch issue {
+		case JWTAlgNone:
+			notes = append(notes, "unsigned (alg none)")
+		case JWTExpired:
+			notes = append(notes, "expired")
+		case JWTNoExpiry:
+			notes = append(notes, "never expires")
+		case JWTSensitiveClaims:
+			notes = append(notes, "sensitive claims: "+strings.Join(j.Sensitive, ", "))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(&sb, " [%s]", strings.Join(notes, "; "))
+	}
+	return sb.String()
+}
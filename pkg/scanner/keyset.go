@@ -0,0 +1,389 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ==============================================
+// DEDUPLICATION STORES
+// ==============================================
+// Remembering every URL of a 50M-line input costs gigabytes. The store
+// behind a URLDeduper is selectable: an exact in-memory set, a Bloom filter
+// that grows as needed and stays under a false-positive rate, or a hash
+// table on disk holding 128-bit fingerprints of the keys.
+
+type DedupeStore string
+
+const (
+	StoreMemory DedupeStore = "memory" // Exact, about 100 bytes per URL plus the URL
+	StoreBloom  DedupeStore = "bloom"  // About 2-4 bytes per URL; a few new URLs are taken for duplicates
+	StoreDisk   DedupeStore = "disk"   // Exact, 32-64 bytes per URL on disk and a few MB of memory
+)
+
+const DefaultFPRate = 0.001
+
+func ParseDedupeStore(name string) (DedupeStore, error) {
+	switch store := DedupeStore(strings.ToLower(strings.TrimSpace(name))); store {
+	case "", StoreMemory:
+		return StoreMemory, nil
+	case StoreBloom, StoreDisk:
+		return store, nil
+	}
+	return "", fmt.Errorf("unknown dedupe store '%s' (use memory, bloom or disk)", name)
+}
+
+// KeySet remembers keys. Add records a key and reports whether it was
+// new; a Bloom filter may answer false for a new key, never true for a
+// known one. Sets are not safe for concurrent use.
+type KeySet interface {
+	Add(key string) bool
+	Close() error
+}
+
+// DedupeConfig selects how duplicates are found and remembered
+type DedupeConfig struct {
+	Mode   DedupeMode
+	Store  DedupeStore // StoreMemory if empty
+	FPRate float64     // Bloom filter false-positive rate, DefaultFPRate if 0
+	Dir    string      // Directory of the disk store, os.TempDir() if empty
+}
+
+// NewKeySet opens the store selected by the config
+func NewKeySet(config DedupeConfig) (KeySet, error) {
+	switch config.Store {
+	case StoreBloom:
+		rate := config.FPRate
+		if rate == 0 {
+			rate = DefaultFPRate
+		}
+		if rate <= 0 || rate >= 1 {
+			return nil, fmt.Errorf("false-positive rate %g must be between 0 and 1", rate)
+		}
+		return newBloomSet(rate), nil
+	case StoreDisk:
+		return newDiskSet(config.Dir)
+	}
+	return memorySet{}, nil
+}
+
+type memorySet map[string]struct{}
+
+func (s memorySet) Add(key string) bool {
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+func (s memorySet) Close() error { return nil }
+
+// keyHashes returns two independent 64-bit hashes of key
+func keyHashes(seeds *[2]maphash.Seed, key string) (uint64, uint64) {
+	return maphash.String(seeds[0], key), maphash.String(seeds[1], key)
+}
+
+func newSeeds() *[2]maphash.Seed {
+	return &[2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}
+}
+
+// ==============================================
+// SCALABLE BLOOM FILTER
+// ==============================================
+// The input size is unknown, so filters are chained: when one holds its
+// capacity a filter twice as large with half the false-positive rate is
+// added. The rates form a series that sums to the configured rate.
+
+const (
+	bloomInitialCapacity = 1 << 20
+	bloomGrowth          = 2
+	bloomTightening      = 0.5
+)
+
+type bloomSet struct {
+	seeds   *[2]maphash.Seed
+	rate    float64 // Of the next filter
+	filters []*bloomFilter
+}
+
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64 // Bits
+	k        uint64 // Hashes per key
+	capacity int
+	count    int
+}
+
+func newBloomSet(rate float64) *bloomSet {
+	s := &bloomSet{seeds: newSeeds(), rate: rate * (1 - bloomTightening)}
+	s.grow(bloomInitialCapacity)
+	return s
+}
+
+func (s *bloomSet) grow(capacity int) {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(s.rate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+	s.filters = append(s.filters, &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k, capacity: capacity})
+	s.rate *= bloomTightening
+}
+
+func (s *bloomSet) Add(key string) bool {
+	h1, h2 := keyHashes(s.seeds, key)
+	for _, f := range s.filters {
+		if f.contains(h1, h2) {
+			return false
+		}
+	}
+	last := s.filters[len(s.filters)-1]
+	last.add(h1, h2)
+	if last.count++; last.count >= last.capacity {
+		s.grow(last.capacity * bloomGrowth)
+	}
+	return true
+}
+
+func (s *bloomSet) Close() error {
+	s.filters = nil
+	return nil
+}
+
+// Double hashing: the i-th bit is h1 + i*h2
+func (f *bloomFilter) contains(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// ==============================================
+// DISK HASH TABLE
+// ==============================================
+// An open-addressing table of 16-byte fingerprints in a temporary file. New
+// fingerprints wait in a small in-memory batch and are written in slot
+// order, a 4 KiB block at a time. The table doubles when half full. Two
+// different keys share a fingerprint with a probability of about 2^-128
+// per pair.
+
+const (
+	diskSlotSize     = 16
+	diskBlockSlots   = 256
+	diskInitialSlots = 1 << 16
+	diskBatchKeys    = 1 << 16
+)
+
+type fingerprint [2]uint64
+
+type diskSet struct {
+	dir   string
+	seeds *[2]maphash.Seed
+	table *diskTable
+	batch map[fingerprint]struct{} // Not yet in the table
+	count uint64                   // In the table and the batch
+	err   error                    // First I/O error; keys are then reported as new
+}
+
+func newDiskSet(dir string) (*diskSet, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	table, err := newDiskTable(dir, diskInitialSlots)
+	if err != nil {
+		return nil, err
+	}
+	return &diskSet{dir: dir, seeds: newSeeds(), table: table, batch: make(map[fingerprint]struct{})}, nil
+}
+
+func (s *diskSet) Add(key string) bool {
+	if s.err != nil {
+		return true
+	}
+	hi, lo := keyHashes(s.seeds, key)
+	fp := fingerprint{hi | 1, lo} // An all-zero slot is empty
+	if _, ok := s.batch[fp]; ok {
+		return false
+	}
+	_, found, err := s.table.find(fp)
+	if err != nil {
+		s.err = err
+		return true
+	}
+	if found {
+		return false
+	}
+	s.batch[fp] = struct{}{}
+	s.count++
+	if len(s.batch) >= diskBatchKeys || s.count > s.table.slots/2 {
+		s.err = s.flush()
+	}
+	return true
+}
+
+// flush writes the batch to the table, growing it first if needed
+func (s *diskSet) flush() error {
+	if s.count > s.table.slots/2 {
+		if err := s.grow(); err != nil {
+			return err
+		}
+	}
+	fps := make([]fingerprint, 0, len(s.batch))
+	for fp := range s.batch {
+		fps = append(fps, fp)
+	}
+	s.batch = make(map[fingerprint]struct{})
+	return s.table.insertAll(fps)
+}
+
+// grow moves every fingerprint into a table twice as large
+func (s *diskSet) grow() error {
+	table, err := newDiskTable(s.dir, s.table.slots*2)
+	if err != nil {
+		return err
+	}
+	chunk := make([]byte, 1<<20)
+	var fps []fingerprint
+	for offset := int64(0); offset < int64(s.table.slots*diskSlotSize); offset += int64(len(chunk)) {
+		n, err := s.table.file.ReadAt(chunk, offset)
+		if err != nil && n == 0 {
+			table.remove()
+			return err
+		}
+		fps = fps[:0]
+		for i := 0; i+diskSlotSize <= n; i += diskSlotSize {
+			if hi := binary.LittleEndian.Uint64(chunk[i:]); hi != 0 {
+				fps = append(fps, fingerprint{hi, binary.LittleEndian.Uint64(chunk[i+8:])})
+			}
+		}
+		if err := table.insertAll(fps); err != nil {
+			table.remove()
+			return err
+		}
+	}
+	s.table.remove()
+	s.table = table
+	return nil
+}
+
+func (s *diskSet) Close() error {
+	if s.table != nil {
+		s.table.remove()
+		s.table = nil
+	}
+	if s.err != nil {
+		return fmt.Errorf("disk dedupe store in '%s': %w", s.dir, s.err)
+	}
+	return nil
+}
+
+// diskTable is the table file with one cached block
+type diskTable struct {
+	file   *os.File
+	slots  uint64 // Power of two
+	block  []byte
+	start  uint64 // First slot of the cached block
+	loaded bool
+	dirty  bool
+}
+
+// newDiskTable creates an empty (sparse) table file
+func newDiskTable(dir string, slots uint64) (*diskTable, error) {
+	file, err := os.CreateTemp(dir, "codehunter-dedupe-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	t := &diskTable{file: file, slots: slots, block: make([]byte, diskBlockSlots*diskSlotSize)}
+	if err := file.Truncate(int64(slots * diskSlotSize)); err != nil {
+		t.remove()
+		return nil, err
+	}
+	return t, nil
+}
+
+// find probes linearly from the fingerprint's home slot and returns its
+// entry in the cached block, or the empty entry where it belongs
+func (t *diskTable) find(fp fingerprint) ([]byte, bool, error) {
+	slot := fp[1] & (t.slots - 1)
+	for {
+		start := slot &^ (diskBlockSlots - 1)
+		if err := t.load(start); err != nil {
+			return nil, false, err
+		}
+		for i := slot - start; i < diskBlockSlots; i++ {
+			entry := t.block[i*diskSlotSize : (i+1)*diskSlotSize]
+			hi := binary.LittleEndian.Uint64(entry)
+			if hi == 0 {
+				return entry, false, nil
+			}
+			if hi == fp[0] && binary.LittleEndian.Uint64(entry[8:]) == fp[1] {
+				return entry, true, nil
+			}
+		}
+		slot = (start + diskBlockSlots) & (t.slots - 1)
+	}
+}
+
+// insertAll adds fingerprints in home slot order, so each block is read
+// and written about once
+func (t *diskTable) insertAll(fps []fingerprint) error {
+	mask := t.slots - 1
+	sort.Slice(fps, func(i, j int) bool { return fps[i][1]&mask < fps[j][1]&mask })
+	for _, fp := range fps {
+		entry, found, err := t.find(fp)
+		if err != nil {
+			return err
+		}
+		if !found {
+			binary.LittleEndian.PutUint64(entry, fp[0])
+			binary.LittleEndian.PutUint64(entry[8:], fp[1])
+			t.dirty = true
+		}
+	}
+	return t.sync()
+}
+
+func (t *diskTable) load(start uint64) error {
+	if t.loaded && t.start == start {
+		return nil
+	}
+	if err := t.sync(); err != nil {
+		return err
+	}
+	t.loaded = false
+	if _, err := t.file.ReadAt(t.block, int64(start*diskSlotSize)); err != nil {
+		return err
+	}
+	t.start, t.loaded = start, true
+	return nil
+}
+
+// sync writes the cached block back if it changed
+func (t *diskTable) sync() error {
+	if !t.dirty {
+		return nil
+	}
+	if _, err := t.file.WriteAt(t.block, int64(t.start*diskSlotSize)); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+func (t *diskTable) remove() {
+	t.file.Close()
+	os.Remove(t.file.Name())
+}
@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// keySetKey returns a wayback-style URL, distinct for each i
+func keySetKey(i int) string {
+	return fmt.Sprintf("https://www.example.com/assets/js/app.%x.js?v=%d&utm_source=feed", i*2654435761, i)
+}
+
+func TestKeySets(t *testing.T) {
+	const keys = 100000
+	for _, config := range []DedupeConfig{{Store: StoreMemory}, {Store: StoreBloom}, {Store: StoreDisk, Dir: t.TempDir()}} {
+		set, err := NewKeySet(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		falsePositives := 0
+		for i := 0; i < keys; i++ {
+			if !set.Add(keySetKey(i)) {
+				falsePositives++
+			}
+		}
+		for i := 0; i < keys; i += 7 {
+			if set.Add(keySetKey(i)) {
+				t.Errorf("%s: key %d was added twice", config.Store, i)
+				break
+			}
+		}
+		if err := set.Close(); err != nil {
+			t.Errorf("%s: %v", config.Store, err)
+		}
+		allowed := 0
+		if config.Store == StoreBloom {
+			allowed = int(2 * DefaultFPRate * keys)
+		}
+		if falsePositives > allowed {
+			t.Errorf("%s: %d of %d new keys taken for duplicates", config.Store, falsePositives, keys)
+		}
+	}
+}
+
+// benchmarkKeySet adds 1M keys, a fifth of them repeated, and reports the
+// heap still in use once they are all remembered
+func benchmarkKeySet(b *testing.B, config DedupeConfig) {
+	const keys, distinct = 1 << 20, 1 << 20 * 4 / 5
+	b.ReportAllocs()
+	var retained uint64
+	for n := 0; n < b.N; n++ {
+		var before, after runtime.MemStats
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		set, err := NewKeySet(config)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < keys; i++ {
+			set.Add(keySetKey(i % distinct))
+		}
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if after.HeapAlloc > before.HeapAlloc {
+			retained += after.HeapAlloc - before.HeapAlloc
+		}
+		runtime.KeepAlive(set)
+		if err := set.Close(); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(retained)/float64(b.N)/distinct, "heap-B/url")
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/keys, "ns/url")
+}
+
+func BenchmarkKeySetMemory(b *testing.B) { benchmarkKeySet(b, DedupeConfig{Store: StoreMemory}) }
+
+func BenchmarkKeySetBloom(b *testing.B) { benchmarkKeySet(b, DedupeConfig{Store: StoreBloom}) }
+
+func BenchmarkKeySetDisk(b *testing.B) {
+	benchmarkKeySet(b, DedupeConfig{Store: StoreDisk, Dir: b.TempDir()})
+}
@@ -0,0 +1,664 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// ==============================================
+// PATTERN LINTER
+// ==============================================
+// The loader skips invalid rules with a warning and accepts everything
+// else, including rules that repeat or shadow others, match every URL or
+// miss what they were written for. LintFiles reports those. The checks are
+// static analyses of the regex syntax, plus a match count over a sample
+// of URLs when one is given.
+
+type LintLevel string
+
+const (
+	LintError   LintLevel = "error"
+	LintWarning LintLevel = "warning"
+)
+
+// Lint checks
+const (
+	CheckInvalid    = "invalid"              // Does not parse or compile (error)
+	CheckIgnored    = "ignored"              // A field of an imported rule file with no equivalent
+	CheckEmptyMatch = "empty-match"          // Matches the empty string anywhere, so every URL (error)
+	CheckScope      = "scope"                // Requires a character its scope never contains
+	CheckDuplicate  = "duplicate"            // Matches the same URLs as an earlier rule
+	CheckSubsumed   = "subsumed"             // Every URL it matches is matched by a broader rule
+	CheckBroad      = "broad"                // Matches most of the corpus
+	CheckExtension  = "unanchored-extension" // A file extension that also matches longer names
+	CheckCase       = "case"                 // Case sensitivity that differs from a similar rule or the URL part
+)
+
+const DefaultMaxMatchRate = 0.5
+
+type LintIssue struct {
+	Level   LintLevel `json:"level"`
+	Check   string    `json:"check"`
+	File    string    `json:"file"`
+	Line    int       `json:"line,omitempty"`
+	RuleID  string    `json:"rule_id,omitempty"`
+	Regex   string    `json:"regex,omitempty"`
+	Message string    `json:"message"`
+	Related string    `json:"related,omitempty"` // The other rule, as file:line
+}
+
+// LintOptions configures LintFiles
+type LintOptions struct {
+	SearchDirs   []string   // As in LoadOptions
+	Packs        *PackStore // As in LoadOptions
+	Corpus       []string   // Sample URLs for CheckBroad, which is skipped without them
+	MaxMatchRate float64    // Share of the corpus above which a rule is broad, DefaultMaxMatchRate if 0
+}
+
+// LintReport holds the issues of the linted files, sorted by file and line
+type LintReport struct {
+	Files  []string    `json:"files"`
+	Rules  int         `json:"rules"`
+	Issues []LintIssue `json:"issues"`
+}
+
+// Count returns the number of issues at a level
+func (r *LintReport) Count(level LintLevel) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// LintFiles checks rule files. Files that cannot be opened are an error;
+// everything wrong inside them is an issue.
+func LintFiles(paths []string, opts LintOptions) (*LintReport, error) {
+	report := &LintReport{}
+	var rules []Rule
+	var ruleFiles []string // Path of each rule, as opened
+	var files []string
+	for _, name := range paths {
+		resolved, err := resolveRuleSource(name, opts.SearchDirs, opts.Packs)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, resolved...)
+	}
+	for _, usedPath := range files {
+		file, err := os.Open(usedPath)
+		if err != nil {
+			return nil, err
+		}
+		report.Files = append(report.Files, usedPath)
+		loaded, err := parseRuleFile(file, usedPath, func(line int, msg string) {
+			report.Issues = append(report.Issues, LintIssue{Level: LintError, Check: CheckInvalid, File: usedPath, Line: line, Message: msg})
+		}, func(line int, msg string) {
+			report.Issues = append(report.Issues, LintIssue{Level: LintWarning, Check: CheckIgnored, File: usedPath, Line: line, Message: msg})
+		})
+		file.Close()
+		if err != nil {
+			report.Issues = append(report.Issues, LintIssue{Level: LintError, Check: CheckInvalid, File: usedPath, Message: err.Error()})
+		}
+		for range loaded {
+			ruleFiles = append(ruleFiles, usedPath)
+		}
+		rules = append(rules, loaded...)
+	}
+	report.Rules = len(rules)
+	report.Issues = append(report.Issues, lintRules(rules, ruleFiles, opts)...)
+
+	fileOrder := make(map[string]int)
+	for i, path := range report.Files {
+		if _, ok := fileOrder[path]; !ok {
+			fileOrder[path] = i
+		}
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.File != b.File {
+			return fileOrder[a.File] < fileOrder[b.File]
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// lintRule is a rule with what the checks derive from its regex
+type lintRule struct {
+	*Rule
+	file       string
+	normalized string       // Simplified syntax tree, "" if it does not parse
+	folded     string       // Same with (?i), to compare case-insensitively
+	required   []string     // Lowercased literals every match contains, nil if unknown
+	exactCase  bool         // required is in the rule's own case
+	contains   []caseString // The rule matches exactly the texts containing one of these
+	components map[Component]bool
+}
+
+type caseString struct {
+	text string
+	fold bool
+}
+
+func lintRules(rules []Rule, files []string, opts LintOptions) []LintIssue {
+	var issues []LintIssue
+	add := func(r *lintRule, level LintLevel, check, related, format string, args ...any) {
+		issues = append(issues, LintIssue{Level: level, Check: check, File: r.file, Line: r.Line, RuleID: r.ID,
+			Regex: r.Regex, Message: fmt.Sprintf(format, args...), Related: related})
+	}
+
+	lint := make([]*lintRule, len(rules))
+	for i := range rules {
+		lint[i] = newLintRule(&rules[i], files[i])
+	}
+	for _, r := range lint {
+		if r.Compiled.MatchString("") {
+			if matchesEveryText(r.Compiled) {
+				add(r, LintError, CheckEmptyMatch, "", "matches the empty string, so it matches every URL")
+			} else {
+				add(r, LintWarning, CheckEmptyMatch, "", "matches the empty string, but empty URL parts are never matched, so that case never fires")
+			}
+		}
+		if c, ok := unreachableChar(r); ok {
+			add(r, LintWarning, CheckScope, "", "requires '%c', which its scope (%s) never contains unencoded; scope it to url or drop the '%c'", c, formatScope(ruleComponents(*r.Rule)), c)
+		}
+		for _, ext := range unanchoredExtensions(r.Regex) {
+			if strings.HasSuffix(ext, ".") {
+				add(r, LintWarning, CheckExtension, "", "'%s' followed by anything also matches names such as '%s'; list the suffixes or end it with [a-z]+$", ext, extensionExample(ext))
+			} else {
+				add(r, LintWarning, CheckExtension, "", "'%s' also matches names such as '%s'; end it with $, \\b or (?:[?#]|$)", ext, extensionExample(ext))
+			}
+		}
+		if onlyCaseInsensitiveParts(r.components) && hasUpperLiteral(r.Regex) {
+			add(r, LintWarning, CheckCase, "", "hosts and schemes are case-insensitive, but uppercase letters only match when the URL is written the same way; add (?i)")
+		}
+	}
+
+	// Each rule is reported against the first rule it repeats or is
+	// shadowed by
+	paired := make([]bool, len(lint))
+	for j, b := range lint {
+		for i, a := range lint[:j] {
+			where := fmt.Sprintf("%s:%d", a.file, a.Line)
+			sameParts := sameComponents(a.components, b.components)
+			if !paired[j] {
+				paired[j] = true
+				switch {
+				case a.normalized != "" && a.normalized == b.normalized && sameParts:
+					add(b, LintWarning, CheckDuplicate, where, "same pattern as %s '%s'", where, a.Regex)
+				case a.folded != "" && a.folded == b.folded && a.normalized != b.normalized:
+					add(b, LintWarning, CheckCase, where, "same pattern as %s '%s' but with different case sensitivity", where, a.Regex)
+				case sameParts && lintSubsumes(a, b) && lintSubsumes(b, a):
+					add(b, LintWarning, CheckDuplicate, where, "matches the same URLs as %s '%s'", where, a.Regex)
+				case lintSubsumes(a, b) && b.Severity <= a.Severity:
+					add(b, LintWarning, CheckSubsumed, where, "every URL it matches is also matched by %s '%s'", where, a.Regex)
+				default:
+					paired[j] = false
+				}
+			}
+			if !paired[i] && lintSubsumes(b, a) && !lintSubsumes(a, b) && a.Severity <= b.Severity {
+				paired[i] = true
+				related := fmt.Sprintf("%s:%d", b.file, b.Line)
+				add(a, LintWarning, CheckSubsumed, related, "every URL it matches is also matched by %s '%s'", related, b.Regex)
+			}
+		}
+	}
+
+	if len(opts.Corpus) > 0 {
+		maxRate := opts.MaxMatchRate
+		if maxRate <= 0 {
+			maxRate = DefaultMaxMatchRate
+		}
+		counts := make([]int, len(rules))
+		engine := newMatchEngine(rules)
+		for _, url := range opts.Corpus {
+			for _, m := range engine.match(urlComponents(url), nil) {
+				counts[m.Index]++
+			}
+		}
+		for i, r := range lint {
+			if rate := float64(counts[i]) / float64(len(opts.Corpus)); rate > maxRate {
+				add(r, LintWarning, CheckBroad, "", "matches %.0f%% of the corpus (%d of %d URLs)", rate*100, counts[i], len(opts.Corpus))
+			}
+		}
+	}
+	return issues
+}
+
+func newLintRule(rule *Rule, file string) *lintRule {
+	r := &lintRule{Rule: rule, file: file, components: make(map[Component]bool)}
+	for _, c := range ruleComponents(*rule) {
+		r.components[c] = true
+	}
+	re, err := syntax.Parse(rule.Regex, syntax.Perl)
+	if err != nil {
+		return r
+	}
+	re = re.Simplify()
+	r.normalized = re.String()
+	if folded, err := syntax.Parse("(?i)"+rule.Regex, syntax.Perl); err == nil {
+		r.folded = folded.Simplify().String()
+	}
+	r.required = requiredLiterals(rule.Regex)
+	r.exactCase = !hasFoldOrUpper(re)
+	r.contains, _ = containsForm(re)
+	return r
+}
+
+// lintSubsumes reports whether every URL narrow matches is also matched by
+// broad: broad applies to all of narrow's URL parts and matches any text
+// containing one of its literals, and every match of narrow contains one.
+func lintSubsumes(broad, narrow *lintRule) bool {
+	if broad.contains == nil || narrow.required == nil {
+		return false
+	}
+	for c := range narrow.components {
+		if !broad.components[c] {
+			return false
+		}
+	}
+	for _, lit := range narrow.required {
+		found := false
+		for _, s := range broad.contains {
+			switch {
+			case s.fold || !hasASCIILetter(s.text):
+				found = strings.Contains(lit, strings.ToLower(s.text))
+			case narrow.exactCase:
+				found = strings.Contains(lit, s.text)
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsForm returns the literals a regex is equivalent to searching
+// for, if it is: a literal surrounded by parts that may match nothing, or
+// alternatives of such. It returns nil otherwise.
+func containsForm(re *syntax.Regexp) ([]caseString, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		fold := re.Flags&syntax.FoldCase != 0
+		if fold && hasNonASCII(re.Rune) {
+			return nil, false // Unicode folding is not modelled
+		}
+		return []caseString{{string(re.Rune), fold}}, true
+	case syntax.OpCapture:
+		return containsForm(re.Sub[0])
+	case syntax.OpAlternate:
+		var all []caseString
+		for _, sub := range re.Sub {
+			lits, ok := containsForm(sub)
+			if !ok {
+				return nil, false
+			}
+			all = append(all, lits...)
+		}
+		return all, true
+	case syntax.OpConcat:
+		var lit *syntax.Regexp
+		for _, sub := range re.Sub {
+			switch {
+			case matchesEmptyFreely(sub):
+			case lit == nil && sub.Op == syntax.OpLiteral:
+				lit = sub
+			default:
+				return nil, false
+			}
+		}
+		if lit == nil {
+			return nil, false
+		}
+		return containsForm(lit)
+	}
+	return nil, false
+}
+
+// matchesEmptyFreely reports whether a node can match the empty string
+// without asserting anything about its position
+func matchesEmptyFreely(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpStar, syntax.OpQuest:
+		return true
+	case syntax.OpRepeat:
+		return re.Min == 0
+	case syntax.OpCapture:
+		return matchesEmptyFreely(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !matchesEmptyFreely(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if matchesEmptyFreely(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasFoldOrUpper reports whether a regex folds case or holds an uppercase
+// letter, i.e. whether lowercasing its literals changes what they match
+func hasFoldOrUpper(re *syntax.Regexp) bool {
+	if re.Flags&syntax.FoldCase != 0 && (re.Op == syntax.OpLiteral || re.Op == syntax.OpCharClass) {
+		return true
+	}
+	if re.Op == syntax.OpLiteral || re.Op == syntax.OpCharClass {
+		for i, r := range re.Rune {
+			if r >= 'A' && r <= 'Z' {
+				return true
+			}
+			// A class range such as '0'-'z' spans the uppercase letters
+			if re.Op == syntax.OpCharClass && i%2 == 0 && i+1 < len(re.Rune) && r < 'A' && re.Rune[i+1] >= 'A' {
+				return true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if hasFoldOrUpper(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasASCIILetter(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c >= 'a' && c <= 'z' {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNonASCII(runes []rune) bool {
+	for _, r := range runes {
+		if r >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
+
+func sameComponents(a, b map[Component]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for c := range a {
+		if !b[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// emptyMatchProbes are texts that a regex matching the empty string at any
+// position matches too, but that "^$" does not
+var emptyMatchProbes = []string{"x", "/a?b=c"}
+
+func matchesEveryText(re *regexp.Regexp) bool {
+	for _, probe := range emptyMatchProbes {
+		if !re.MatchString(probe) {
+			return false
+		}
+	}
+	return true
+}
+
+// scopeExcludes lists, per URL part, characters it never contains unless
+// they were percent-encoded in the URL. Other parts can contain anything.
+var scopeExcludes = map[Component]string{
+	ComponentScheme:  ":/?#@=&",
+	ComponentHost:    "/?#@=&",
+	ComponentPort:    ":/?#@=&.",
+	ComponentPath:    "?#",
+	ComponentSegment: "/?#",
+}
+
+// unreachableChar returns a character that every match of the rule
+// contains and that none of the parts it applies to does
+func unreachableChar(r *lintRule) (rune, bool) {
+	re, err := syntax.Parse(r.Regex, syntax.Perl)
+	if err != nil || len(r.components) == 0 {
+		return 0, false
+	}
+	var excluded string
+	for c := range r.components {
+		chars, ok := scopeExcludes[c]
+		if !ok {
+			return 0, false
+		}
+		if excluded == "" {
+			excluded = chars
+			continue
+		}
+		excluded = strings.Map(func(ch rune) rune {
+			if strings.ContainsRune(chars, ch) {
+				return ch
+			}
+			return -1
+		}, excluded)
+	}
+	for _, ch := range excluded {
+		if requiresChar(re, ch) {
+			return ch, true
+		}
+	}
+	return 0, false
+}
+
+// requiresChar reports whether every match of a regex contains ch
+func requiresChar(re *syntax.Regexp, ch rune) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return strings.ContainsRune(string(re.Rune), ch)
+	case syntax.OpCharClass:
+		return len(re.Rune) == 2 && re.Rune[0] == ch && re.Rune[1] == ch
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiresChar(re.Sub[0], ch)
+	case syntax.OpRepeat:
+		return re.Min > 0 && requiresChar(re.Sub[0], ch)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if requiresChar(sub, ch) {
+				return true
+			}
+		}
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !requiresChar(sub, ch) {
+				return false
+			}
+		}
+		return len(re.Sub) > 0
+	}
+	return false
+}
+
+// onlyCaseInsensitiveParts reports whether a rule only applies to the
+// host and scheme
+func onlyCaseInsensitiveParts(components map[Component]bool) bool {
+	for c := range components {
+		if c != ComponentHost && c != ComponentScheme {
+			return false
+		}
+	}
+	return len(components) > 0
+}
+
+// hasUpperLiteral reports whether a regex matches uppercase letters
+// case-sensitively
+func hasUpperLiteral(expr string) bool {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		if re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 {
+			for _, r := range re.Rune {
+				if r >= 'A' && r <= 'Z' {
+					return true
+				}
+			}
+		}
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(re)
+}
+
+// ==============================================
+// FILE EXTENSIONS
+// ==============================================
+// Rules are searched anywhere in the URL, so "\.sql" also fires on
+// "/x.sqlite-docs" and "\.env\..*" on any name containing ".env.". An
+// extension is anchored when what follows it cannot continue the name:
+// $, \b, or a character such as '?', '#', '/' or '&'.
+
+var lintExtensions = map[string]bool{
+	"7z": true, "asp": true, "aspx": true, "bak": true, "backup": true, "bz2": true, "cfg": true, "conf": true,
+	"config": true, "csv": true, "db": true, "dump": true, "env": true, "gz": true, "ini": true, "jar": true,
+	"js": true, "json": true, "jsp": true, "key": true, "log": true, "map": true, "old": true, "orig": true,
+	"pem": true, "php": true, "pfx": true, "ppk": true, "properties": true, "py": true, "rar": true, "rb": true,
+	"save": true, "sh": true, "sql": true, "sqlite": true, "swp": true, "tar": true, "tgz": true, "tmp": true,
+	"txt": true, "war": true, "xls": true, "xlsx": true, "xml": true, "yaml": true, "yml": true, "zip": true,
+}
+
+// unanchoredExtensions returns the extensions of a regex (".ext", or
+// ".ext." for a suffix) that are followed by nothing or by a part that can
+// continue the name
+func unanchoredExtensions(expr string) []string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	re = re.Simplify()
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	var found []string
+	for i, sub := range subs {
+		if sub.Op != syntax.OpLiteral {
+			continue
+		}
+		text := strings.ToLower(string(sub.Rune))
+		rest := subs[i+1:]
+		for dot := strings.IndexByte(text, '.'); dot >= 0; {
+			end := dot + 1
+			for end < len(text) && isAlnum(text[end]) {
+				end++
+			}
+			ext := text[dot+1 : end]
+			switch {
+			case !lintExtensions[ext]:
+			case end == len(text):
+				// ".ext" ends the literal: the next part decides
+				if len(rest) == 0 || !endsName(rest[0]) {
+					found = append(found, "."+ext)
+				}
+			case end == len(text)-1 && text[end] == '.':
+				// ".ext." followed by anything, as in "\.env\..*"
+				if len(rest) == 0 || matchesAnything(rest[0]) {
+					found = append(found, "."+ext+".")
+				}
+			}
+			next := strings.IndexByte(text[end:], '.')
+			if next < 0 {
+				break
+			}
+			dot = end + next
+		}
+	}
+	return found
+}
+
+// matchesAnything reports whether a node is .* or .+
+func matchesAnything(re *syntax.Regexp) bool {
+	if re.Op != syntax.OpStar && re.Op != syntax.OpPlus {
+		return false
+	}
+	op := re.Sub[0].Op
+	return op == syntax.OpAnyChar || op == syntax.OpAnyCharNotNL
+}
+
+// extensionExample names a file an unanchored extension also matches:
+// a longer extension such as ".json" for ".js", or one with a suffix
+func extensionExample(ext string) string {
+	if strings.HasSuffix(ext, ".") {
+		return "notes" + ext + "html"
+	}
+	var longer []string
+	for known := range lintExtensions {
+		if strings.HasPrefix("."+known, ext) && "."+known != ext {
+			longer = append(longer, known)
+		}
+	}
+	if len(longer) == 0 {
+		return "file" + ext + "s"
+	}
+	sort.Strings(longer)
+	return "file." + longer[0]
+}
+
+// endsName reports whether a node stops a file name: an end or word
+// boundary assertion, or characters that cannot be part of a name
+func endsName(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEndText, syntax.OpEndLine, syntax.OpWordBoundary:
+		return true
+	case syntax.OpLiteral:
+		return !isNameChar(re.Rune[0])
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1] && r < 0x80; r++ {
+				if isNameChar(r) {
+					return false
+				}
+			}
+		}
+		return true
+	case syntax.OpCapture, syntax.OpConcat:
+		return endsName(re.Sub[0])
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !endsName(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isNameChar(r rune) bool {
+	return r < 0x80 && (isAlnum(byte(r)) || r == '.' || r == '-' || r == '_')
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
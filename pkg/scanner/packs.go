@@ -0,0 +1,813 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================
+// PATTERN PACKS
+// ==============================================
+// A pattern pack is a directory of rule files described by a pack.toml
+// manifest:
+//
+//	name = "cloud-keys"
+//	version = "1.2.0"
+//	description = "Cloud provider credentials"
+//
+//	[[file]]
+//	path = "cloud.toml"
+//	sha256 = "<sha256sum of cloud.toml>"
+//
+// A PackStore installs packs into <dir>/<name>/<version> from a directory,
+// a .tar.gz or .zip archive, the URL of either an archive or a pack.toml
+// (files are then fetched relative to it), or a git repository. Every file
+// is checked against its checksum when installed and again when loaded, so
+// a pack is used exactly as published. -r then names packs as name,
+// name@version or name@version/file; without a version the highest
+// installed one is used.
+
+// PackManifest is the manifest file name of a pack
+const PackManifest = "pack.toml"
+
+const (
+	packSourceFile = ".source.json" // Where an installed pack came from, for Update
+	maxPackSize    = 64 << 20       // Limit on downloads and extracted archives
+)
+
+// ErrPackNotInstalled is returned by Resolve for packs not in the store
+var ErrPackNotInstalled = errors.New("pattern pack is not installed")
+
+var (
+	packNamePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	packVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]*$`)
+	sha256Pattern      = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// PackFile is a rule file of a pack, relative to the pack directory
+type PackFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Pack is a pack manifest with the directory it was read from
+type Pack struct {
+	Name        string     `json:"name"`
+	Version     string     `json:"version"`
+	Description string     `json:"description,omitempty"`
+	Files       []PackFile `json:"files"`
+	Dir         string     `json:"dir"`
+	Source      string     `json:"source,omitempty"` // As given to Install, empty outside a store
+	Installed   time.Time  `json:"installed"`
+}
+
+// Ref is the name@version reference of the pack
+func (p *Pack) Ref() string {
+	return p.Name + "@" + p.Version
+}
+
+// Paths returns the full paths of the pack's files, in manifest order
+func (p *Pack) Paths() []string {
+	paths := make([]string, len(p.Files))
+	for i, f := range p.Files {
+		paths[i] = filepath.Join(p.Dir, filepath.FromSlash(f.Path))
+	}
+	return paths
+}
+
+// Verify checks every file of the pack against its checksum
+func (p *Pack) Verify() error {
+	for _, f := range p.Files {
+		sum, err := fileSHA256(filepath.Join(p.Dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+		if sum != f.SHA256 {
+			return fmt.Errorf("'%s' of %s does not match its sha256 checksum", f.Path, p.Ref())
+		}
+	}
+	return nil
+}
+
+// ReadPack reads and checks the manifest of the pack in dir. It does not
+// verify the files, see Pack.Verify.
+func ReadPack(dir string) (*Pack, error) {
+	data, err := os.ReadFile(filepath.Join(dir, PackManifest))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseTOML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", PackManifest, err)
+	}
+	pack := &Pack{Dir: dir}
+	for key, field := range map[string]*string{"name": &pack.Name, "version": &pack.Version, "description": &pack.Description} {
+		if *field, err = tomlString(doc, key); err != nil {
+			return nil, fmt.Errorf("%s: %v", PackManifest, err)
+		}
+	}
+	if !packNamePattern.MatchString(pack.Name) {
+		return nil, fmt.Errorf("%s: invalid name '%s' (use lowercase letters, digits, '-' and '_')", PackManifest, pack.Name)
+	}
+	if !packVersionPattern.MatchString(pack.Version) {
+		return nil, fmt.Errorf("%s: invalid version '%s'", PackManifest, pack.Version)
+	}
+
+	files, err := tomlTables(doc, "file")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", PackManifest, err)
+	}
+	seen := make(map[string]bool)
+	for _, table := range files {
+		var f PackFile
+		if f.Path, err = tomlString(table, "path"); err == nil {
+			f.SHA256, err = tomlString(table, "sha256")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", PackManifest, tomlTableLine(table), err)
+		}
+		f.SHA256 = strings.ToLower(f.SHA256)
+		clean := path.Clean(f.Path)
+		switch {
+		case f.Path == "" || clean != f.Path || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../"):
+			return nil, fmt.Errorf("%s line %d: path '%s' must be relative to the pack and use '/'", PackManifest, tomlTableLine(table), f.Path)
+		case !isRuleFileExt(path.Ext(clean)) || clean == PackManifest:
+			return nil, fmt.Errorf("%s line %d: '%s' is not a .txt, .toml or .yaml rule file", PackManifest, tomlTableLine(table), f.Path)
+		case !sha256Pattern.MatchString(f.SHA256):
+			return nil, fmt.Errorf("%s line %d: '%s' needs a sha256 checksum of 64 hex digits", PackManifest, tomlTableLine(table), f.Path)
+		case seen[clean]:
+			return nil, fmt.Errorf("%s line %d: '%s' is listed twice", PackManifest, tomlTableLine(table), f.Path)
+		}
+		seen[clean] = true
+		pack.Files = append(pack.Files, f)
+	}
+	if len(pack.Files) == 0 {
+		return nil, fmt.Errorf("%s: no [[file]] entries", PackManifest)
+	}
+	return pack, nil
+}
+
+func isRuleFileExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".txt", ".toml", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// ==============================================
+// PACK STORE
+// ==============================================
+
+// PackStore manages the packs installed under Dir
+type PackStore struct {
+	Dir    string
+	Client *http.Client // For HTTP sources, http.DefaultClient if nil
+	Git    string       // git executable for git sources, "git" if empty
+}
+
+// InstallOptions configures PackStore.Install
+type InstallOptions struct {
+	SHA256 string // Expected checksum of the archive or pack.toml fetched, if set
+	Force  bool   // Replace the version if it is already installed
+}
+
+// DefaultPackDir is $CODEHUNTER_HOME/packs, else codehunter/packs in
+// $XDG_DATA_HOME or ~/.local/share, next to the patterns of a user install.
+// It is empty if no home directory is known.
+func DefaultPackDir() string {
+	if dir := os.Getenv("CODEHUNTER_HOME"); dir != "" {
+		return filepath.Join(dir, "packs")
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "codehunter", "packs")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "codehunter", "packs")
+	}
+	return ""
+}
+
+// IsPackRef reports whether name has the form of a pack reference:
+// name, name@version or either followed by /file
+func IsPackRef(name string) bool {
+	head, _, _ := strings.Cut(name, "/")
+	packName, _, _ := strings.Cut(head, "@")
+	return packNamePattern.MatchString(packName)
+}
+
+// Install fetches a pack, verifies it and installs it into the store
+func (s *PackStore) Install(ctx context.Context, source string, opts InstallOptions) (*Pack, error) {
+	pack, cleanup, err := s.fetch(ctx, source, opts.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if _, _, isGit := gitSource(source); !isGit && !strings.Contains(source, "://") {
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs // Recorded for Update, which may run elsewhere
+		}
+	}
+	return s.store(pack, source, opts.Force)
+}
+
+// Update fetches the pack again from the source its highest installed
+// version came from and installs the fetched version if it is higher. It
+// returns the highest installed version and whether it is new.
+func (s *PackStore) Update(ctx context.Context, name string) (*Pack, bool, error) {
+	current, err := s.find(name, "")
+	if err != nil {
+		return nil, false, err
+	}
+	if current.Source == "" {
+		return current, false, fmt.Errorf("%s has no recorded source to update from", current.Ref())
+	}
+	pack, cleanup, err := s.fetch(ctx, current.Source, "")
+	if err != nil {
+		return current, false, err
+	}
+	defer cleanup()
+	if pack.Name != current.Name {
+		return current, false, fmt.Errorf("'%s' now provides pack '%s', not '%s'", current.Source, pack.Name, current.Name)
+	}
+	if compareVersions(pack.Version, current.Version) <= 0 {
+		return current, false, nil
+	}
+	installed, err := s.store(pack, current.Source, false)
+	if err != nil {
+		return current, false, err
+	}
+	return installed, true, nil
+}
+
+// List returns the installed packs by name, each name's versions from the
+// lowest to the highest
+func (s *PackStore) List() ([]*Pack, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var packs []*Pack
+	for _, entry := range entries {
+		if !entry.IsDir() || !packNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		versions, err := s.versions(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, versions...)
+	}
+	return packs, nil
+}
+
+// Resolve returns the files of the pack a reference names, verified
+// against their checksums
+func (s *PackStore) Resolve(ref string) ([]string, error) {
+	head, file, hasFile := strings.Cut(ref, "/")
+	name, version, _ := strings.Cut(head, "@")
+	pack, err := s.find(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := pack.Verify(); err != nil {
+		return nil, fmt.Errorf("%v; reinstall the pack", err)
+	}
+	if !hasFile {
+		return pack.Paths(), nil
+	}
+	for i, f := range pack.Files {
+		if f.Path == file {
+			return pack.Paths()[i : i+1], nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no file '%s'", pack.Ref(), file)
+}
+
+// find returns an installed version of a pack, the highest if version is
+// empty
+func (s *PackStore) find(name, version string) (*Pack, error) {
+	versions, err := s.versions(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("'%s': %w", name, ErrPackNotInstalled)
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, pack := range versions {
+		if pack.Version == version {
+			return pack, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s@%s': %w", name, version, ErrPackNotInstalled)
+}
+
+func (s *PackStore) versions(name string) ([]*Pack, error) {
+	if !packNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid pack name '%s'", name)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var packs []*Pack
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		pack, err := s.readInstalled(filepath.Join(s.Dir, name, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("installed pack '%s/%s': %v", name, entry.Name(), err)
+		}
+		packs = append(packs, pack)
+	}
+	sort.Slice(packs, func(i, j int) bool { return compareVersions(packs[i].Version, packs[j].Version) < 0 })
+	return packs, nil
+}
+
+type packSource struct {
+	Source    string    `json:"source"`
+	Installed time.Time `json:"installed"`
+}
+
+func (s *PackStore) readInstalled(dir string) (*Pack, error) {
+	pack, err := ReadPack(dir)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, packSourceFile)); err == nil {
+		var src packSource
+		if err := json.Unmarshal(data, &src); err != nil {
+			return nil, fmt.Errorf("%s: %v", packSourceFile, err)
+		}
+		pack.Source, pack.Installed = src.Source, src.Installed
+	}
+	return pack, nil
+}
+
+// store copies a fetched and verified pack into <dir>/<name>/<version>.
+// The copy is staged next to its destination and renamed into place, so
+// an interrupted install leaves no partial version behind.
+func (s *PackStore) store(pack *Pack, source string, force bool) (*Pack, error) {
+	base := filepath.Join(s.Dir, pack.Name)
+	dest := filepath.Join(base, pack.Version)
+	if _, err := os.Stat(dest); err == nil && !force {
+		return nil, fmt.Errorf("%s is already installed (reinstall it with --force)", pack.Ref())
+	}
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(base, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return nil, err
+	}
+
+	names := []string{PackManifest}
+	for _, f := range pack.Files {
+		names = append(names, filepath.FromSlash(f.Path))
+	}
+	for _, name := range names {
+		if err := copyFile(filepath.Join(pack.Dir, name), filepath.Join(staging, name)); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.MarshalIndent(packSource{Source: source, Installed: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, packSourceFile), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		old := staging + ".old"
+		if err := os.Rename(dest, old); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(old)
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		return nil, err
+	}
+	return s.readInstalled(dest)
+}
+
+// ==============================================
+// PACK SOURCES
+// ==============================================
+
+// fetch makes a source available as a verified pack directory. cleanup
+// removes whatever fetch downloaded.
+func (s *PackStore) fetch(ctx context.Context, source, sum string) (pack *Pack, cleanup func(), err error) {
+	if sum != "" && !sha256Pattern.MatchString(strings.ToLower(sum)) {
+		return nil, nil, fmt.Errorf("invalid sha256 checksum '%s'", sum)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	tmp, err := os.MkdirTemp(s.Dir, ".fetch-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(tmp) }
+
+	root, err := s.fetchSource(ctx, source, strings.ToLower(sum), tmp)
+	if err == nil {
+		root, err = findManifest(root)
+	}
+	if err == nil {
+		pack, err = ReadPack(root)
+	}
+	if err == nil {
+		err = pack.Verify()
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("'%s': %v", source, err)
+	}
+	return pack, cleanup, nil
+}
+
+// fetchSource returns the directory a source provides, downloading or
+// extracting it into tmp
+func (s *PackStore) fetchSource(ctx context.Context, source, sum, tmp string) (string, error) {
+	if repo, ref, ok := gitSource(source); ok {
+		if sum != "" {
+			return "", fmt.Errorf("a sha256 checksum applies to archives and pack.toml URLs, not to git repositories")
+		}
+		return s.clone(ctx, repo, ref, filepath.Join(tmp, "repo"))
+	}
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return "", err
+		}
+		if path.Ext(u.Path) == ".toml" {
+			dir := filepath.Join(tmp, "pack")
+			if err := s.download(ctx, source, filepath.Join(dir, PackManifest), sum); err != nil {
+				return "", err
+			}
+			manifest, err := ReadPack(dir)
+			if err != nil {
+				return "", err
+			}
+			for _, f := range manifest.Files {
+				fileURL := u.ResolveReference(&url.URL{Path: f.Path}).String()
+				if err := s.download(ctx, fileURL, filepath.Join(dir, filepath.FromSlash(f.Path)), ""); err != nil {
+					return "", err
+				}
+			}
+			return dir, nil
+		}
+		archive := filepath.Join(tmp, "archive")
+		if err := s.download(ctx, source, archive, sum); err != nil {
+			return "", err
+		}
+		return extractArchive(archive, filepath.Join(tmp, "pack"))
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case info.IsDir():
+		if sum != "" {
+			return "", fmt.Errorf("a sha256 checksum applies to archives and pack.toml files, not to directories")
+		}
+		return source, nil
+	case sum != "":
+		if err := checkSHA256(source, sum); err != nil {
+			return "", err
+		}
+	}
+	if filepath.Ext(source) == ".toml" {
+		return filepath.Dir(source), nil
+	}
+	return extractArchive(source, filepath.Join(tmp, "pack"))
+}
+
+// gitSource recognizes git+<url>, <url>.git, git@host:path and ssh://
+// sources, with an optional #branch-or-tag
+func gitSource(source string) (repo, ref string, ok bool) {
+	repo, ref, _ = strings.Cut(source, "#")
+	switch {
+	case strings.HasPrefix(repo, "git+"):
+		return strings.TrimPrefix(repo, "git+"), ref, true
+	case strings.HasSuffix(repo, ".git"), strings.HasPrefix(repo, "git@"), strings.HasPrefix(repo, "ssh://"):
+		return repo, ref, true
+	}
+	return "", "", false
+}
+
+func (s *PackStore) clone(ctx context.Context, repo, ref, dest string) (string, error) {
+	git := s.Git
+	if git == "" {
+		git = "git"
+	}
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", repo, dest)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, git, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git clone failed: %s", msg)
+		}
+		return "", fmt.Errorf("git clone failed: %v", err)
+	}
+	return dest, nil
+}
+
+// download saves a URL to dest, checking it against sum if set
+func (s *PackStore) download(ctx context.Context, rawURL, dest, sum string) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET '%s': %s", rawURL, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(resp.Body, maxPackSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("GET '%s': %v", rawURL, err)
+	}
+	if n > maxPackSize {
+		return fmt.Errorf("GET '%s': larger than %d MB", rawURL, maxPackSize>>20)
+	}
+	if sum != "" {
+		return checkSHA256(dest, sum)
+	}
+	return nil
+}
+
+// findManifest returns dir if it holds a pack.toml, else its only
+// subdirectory if that does, as in the archives forges build from tags
+func findManifest(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, PackManifest)); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var subdirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			subdirs = append(subdirs, entry.Name())
+		}
+	}
+	if len(subdirs) == 1 {
+		if _, err := os.Stat(filepath.Join(dir, subdirs[0], PackManifest)); err == nil {
+			return filepath.Join(dir, subdirs[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no %s found", PackManifest)
+}
+
+// ==============================================
+// ARCHIVES AND CHECKSUMS
+// ==============================================
+
+// extractArchive unpacks a .tar.gz or .zip archive, told apart by their
+// magic bytes. Only regular files and directories are extracted; entries
+// leaving dest are an error.
+func extractArchive(archive, dest string) (string, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return "", fmt.Errorf("not a .tar.gz or .zip archive")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	var total int64
+	write := func(name string, r io.Reader) error {
+		target, err := archivePath(dest, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.LimitReader(r, maxPackSize-total+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if total += n; err == nil && total > maxPackSize {
+			err = fmt.Errorf("archive expands to more than %d MB", maxPackSize>>20)
+		}
+		return err
+	}
+
+	switch {
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return "", err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return "", err
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				if err := write(hdr.Name, tr); err != nil {
+					return "", err
+				}
+			}
+		}
+	case string(magic) == "PK\x03\x04":
+		info, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		zr, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range zr.File {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return "", err
+			}
+			err = write(entry.Name, r)
+			r.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	default:
+		return "", fmt.Errorf("not a .tar.gz or .zip archive")
+	}
+	return dest, nil
+}
+
+func archivePath(dest, name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, `\`) {
+		return "", fmt.Errorf("archive entry '%s' points outside the pack", name)
+	}
+	return filepath.Join(dest, filepath.FromSlash(clean)), nil
+}
+
+func fileSHA256(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func checkSHA256(name, want string) error {
+	sum, err := fileSHA256(name)
+	if err != nil {
+		return err
+	}
+	if sum != want {
+		return fmt.Errorf("sha256 checksum mismatch: got %s, want %s", sum, want)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// compareVersions orders versions by their dot-separated fields, numeric
+// fields numerically, ignoring a leading 'v'. A pre-release (1.2.0-rc1)
+// comes before its release.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	a, b = strings.SplitN(a, "+", 2)[0], strings.SplitN(b, "+", 2)[0]
+	coreA, preA, _ := strings.Cut(a, "-")
+	coreB, preB, _ := strings.Cut(b, "-")
+	fieldsA, fieldsB := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < len(fieldsA) || i < len(fieldsB); i++ {
+		fa, fb := "0", "0"
+		if i < len(fieldsA) {
+			fa = fieldsA[i]
+		}
+		if i < len(fieldsB) {
+			fb = fieldsB[i]
+		}
+		if c := compareVersionField(fa, fb); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return compareVersionField(preA, preB)
+}
+
+func compareVersionField(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil && na != nb:
+		if na < nb {
+			return -1
+		}
+		return 1
+	case errA == nil && errB == nil:
+		return 0
+	}
+	return strings.Compare(a, b)
+}
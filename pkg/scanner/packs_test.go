@@ -0,0 +1,294 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPack returns the files of a pack, pack.toml included
+func testPack(version string) map[string]string {
+	files := map[string]string{
+		"keys.txt":       "#@ severity: high\nAKIA[0-9A-Z]{16}\n",
+		"tokens/gh.toml": "[[rule]]\nid = \"gh\"\nregex = 'ghp_[A-Za-z0-9]{36}'\n",
+	}
+	var manifest strings.Builder
+	fmt.Fprintf(&manifest, "name = \"cloud-keys\"\nversion = \"%s\"\n", version)
+	for _, name := range []string{"keys.txt", "tokens/gh.toml"} {
+		sum := sha256.Sum256([]byte(files[name]))
+		fmt.Fprintf(&manifest, "\n[[file]]\npath = \"%s\"\nsha256 = \"%s\"\n", name, hex.EncodeToString(sum[:]))
+	}
+	files[PackManifest] = manifest.String()
+	return files
+}
+
+func tarGz(t *testing.T, prefix string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedKeys(files) {
+		if err := tw.WriteHeader(&tar.Header{Name: prefix + name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[name]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// packServer serves files by path; serve replaces what a path returns
+type packServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newPackServer(t *testing.T) *packServer {
+	s := &packServer{files: make(map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		data, ok := s.files[r.URL.Path]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *packServer) serve(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = data
+}
+
+// serveDir serves the files of a pack under dir and returns its pack.toml URL
+func (s *packServer) serveDir(dir string, files map[string]string) string {
+	for name, content := range files {
+		s.serve(dir+"/"+name, []byte(content))
+	}
+	return s.URL + dir + "/" + PackManifest
+}
+
+func newTestStore(t *testing.T) *PackStore {
+	return &PackStore{Dir: filepath.Join(t.TempDir(), "packs")}
+}
+
+func TestPackInstallFromManifestURL(t *testing.T) {
+	server := newPackServer(t)
+	source := server.serveDir("/packs/cloud-keys", testPack("1.0.0"))
+	store := newTestStore(t)
+
+	pack, err := store.Install(context.Background(), source, InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Ref() != "cloud-keys@1.0.0" || pack.Source != source || len(pack.Files) != 2 {
+		t.Errorf("installed %s from %s with %d files", pack.Ref(), pack.Source, len(pack.Files))
+	}
+	if err := pack.Verify(); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.Install(context.Background(), source, InstallOptions{}); err == nil {
+		t.Error("installing the same version twice without Force succeeded")
+	}
+	if _, err := store.Install(context.Background(), source, InstallOptions{Force: true}); err != nil {
+		t.Errorf("reinstalling with Force: %v", err)
+	}
+}
+
+func TestPackInstallFromArchive(t *testing.T) {
+	server := newPackServer(t)
+	files := testPack("1.2.0")
+	archives := map[string][]byte{
+		"/cloud-keys-1.2.0.tar.gz": tarGz(t, "cloud-keys-1.2.0/", files), // One top-level directory, as forges build
+		"/cloud-keys.zip":          zipArchive(t, files),
+	}
+	for name, data := range archives {
+		server.serve(name, data)
+		sum := sha256.Sum256(data)
+		store := newTestStore(t)
+		pack, err := store.Install(context.Background(), server.URL+name, InstallOptions{SHA256: hex.EncodeToString(sum[:])})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if pack.Ref() != "cloud-keys@1.2.0" {
+			t.Errorf("%s: installed %s", name, pack.Ref())
+		}
+		if err := pack.Verify(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestPackInstallChecksumMismatch(t *testing.T) {
+	server := newPackServer(t)
+	server.serve("/cloud-keys.tar.gz", tarGz(t, "", testPack("1.0.0")))
+	store := newTestStore(t)
+
+	_, err := store.Install(context.Background(), server.URL+"/cloud-keys.tar.gz", InstallOptions{SHA256: strings.Repeat("0", 64)})
+	if err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("wrong checksum: %v", err)
+	}
+
+	// A file that does not match the manifest is rejected too
+	files := testPack("1.0.0")
+	files["keys.txt"] += ".*\n"
+	_, err = store.Install(context.Background(), server.serveDir("/tampered", files), InstallOptions{})
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("tampered file: %v", err)
+	}
+	if packs, _ := store.List(); len(packs) != 0 {
+		t.Errorf("%d packs installed after failed installs", len(packs))
+	}
+}
+
+func TestArchivePathTraversal(t *testing.T) {
+	dest := t.TempDir()
+	for _, name := range []string{"../evil.txt", "/etc/passwd", "a/../../evil.txt", `a\..\..\evil.txt`, ".."} {
+		if _, err := archivePath(dest, name); err == nil {
+			t.Errorf("archivePath accepted '%s'", name)
+		}
+	}
+	for name, want := range map[string]string{"keys.txt": "keys.txt", "./a/b.txt": "a/b.txt", "a/../b.txt": "b.txt"} {
+		got, err := archivePath(dest, name)
+		if err != nil || got != filepath.Join(dest, filepath.FromSlash(want)) {
+			t.Errorf("archivePath('%s') = %s, %v", name, got, err)
+		}
+	}
+
+	server := newPackServer(t)
+	files := testPack("1.0.0")
+	files["../evil.txt"] = "x"
+	server.serve("/evil.tar.gz", tarGz(t, "", files))
+	store := newTestStore(t)
+	if _, err := store.Install(context.Background(), server.URL+"/evil.tar.gz", InstallOptions{}); err == nil || !strings.Contains(err.Error(), "outside the pack") {
+		t.Errorf("archive with '../evil.txt': %v", err)
+	}
+}
+
+func TestPackUpdate(t *testing.T) {
+	server := newPackServer(t)
+	source := server.serveDir("/packs/cloud-keys", testPack("1.0.0"))
+	store := newTestStore(t)
+	if _, err := store.Install(context.Background(), source, InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if pack, updated, err := store.Update(context.Background(), "cloud-keys"); err != nil || updated || pack.Version != "1.0.0" {
+		t.Errorf("update without a new version: %v, %v, %v", pack, updated, err)
+	}
+	server.serveDir("/packs/cloud-keys", testPack("1.10.0"))
+	pack, updated, err := store.Update(context.Background(), "cloud-keys")
+	if err != nil || !updated || pack.Version != "1.10.0" {
+		t.Fatalf("update to 1.10.0: %v, %v, %v", pack, updated, err)
+	}
+	packs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, p := range packs {
+		versions = append(versions, p.Version)
+	}
+	if strings.Join(versions, ",") != "1.0.0,1.10.0" {
+		t.Errorf("installed versions %v, want 1.0.0,1.10.0", versions)
+	}
+	if _, _, err := store.Update(context.Background(), "missing"); !errors.Is(err, ErrPackNotInstalled) {
+		t.Errorf("updating a pack that is not installed: %v", err)
+	}
+}
+
+func TestPackResolve(t *testing.T) {
+	server := newPackServer(t)
+	store := newTestStore(t)
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		if _, err := store.Install(context.Background(), server.serveDir("/v"+version, testPack(version)), InstallOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for ref, want := range map[string][]string{
+		"cloud-keys":                      {"2.0.0/keys.txt", "2.0.0/tokens/gh.toml"},
+		"cloud-keys@1.0.0":                {"1.0.0/keys.txt", "1.0.0/tokens/gh.toml"},
+		"cloud-keys@1.0.0/tokens/gh.toml": {"1.0.0/tokens/gh.toml"},
+	} {
+		paths, err := store.Resolve(ref)
+		if err != nil {
+			t.Errorf("%s: %v", ref, err)
+			continue
+		}
+		for i := range want {
+			want[i] = filepath.Join(store.Dir, "cloud-keys", filepath.FromSlash(want[i]))
+		}
+		if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: %v, want %v", ref, paths, want)
+		}
+	}
+	if _, err := store.Resolve("cloud-keys@3.0.0"); !errors.Is(err, ErrPackNotInstalled) {
+		t.Errorf("missing version: %v", err)
+	}
+	if _, err := store.Resolve("cloud-keys@1.0.0/nope.txt"); err == nil {
+		t.Error("missing file resolved")
+	}
+
+	rules, err := LoadRules([]string{"cloud-keys@1.0.0/keys.txt"}, LoadOptions{Packs: store})
+	if err != nil || rules.Len() != 1 {
+		t.Fatalf("loading a pack file: %v", err)
+	}
+
+	paths, _ := store.Resolve("cloud-keys@1.0.0/keys.txt")
+	if err := os.WriteFile(paths[0], []byte(".*\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Resolve("cloud-keys@1.0.0"); err == nil {
+		t.Error("a modified pack resolved")
+	}
+}
@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ==============================================
+// RULE PROFILING
+// ==============================================
+// With Options.ProfileRules the scanner counts, for every rule, how often
+// its regex ran, what it reported and how long it took. The counters are
+// sharded by worker, so workers do not contend over the rules they all
+// evaluate, and summed by RuleProfiles.
+
+// RuleProfile holds the counters of one rule
+type RuleProfile struct {
+	Rule        *Rule
+	Evaluations int64         // Regex runs: views the literal prefilter let through
+	Hits        int64         // Occurrences reported
+	URLs        int64         // URLs with at least one reported occurrence
+	Time        time.Duration // Time spent running the regex and scoring its matches
+}
+
+type ruleCounters struct {
+	evaluations atomic.Int64
+	hits        atomic.Int64
+	urls        atomic.Int64
+	nanos       atomic.Int64
+	_           [32]byte // Keeps neighbouring rules off the same cache line
+}
+
+type ruleProfiler struct {
+	shards [][]ruleCounters
+}
+
+func newRuleProfiler(rules, workers int) *ruleProfiler {
+	p := &ruleProfiler{shards: make([][]ruleCounters, workers)}
+	for i := range p.shards {
+		p.shards[i] = make([]ruleCounters, rules)
+	}
+	return p
+}
+
+// shard returns the counters of a worker. ScanURL calls share shard 0,
+// which the atomic counters make safe.
+func (p *ruleProfiler) shard(workerID int) []ruleCounters {
+	return p.shards[workerID%len(p.shards)]
+}
+
+// RuleProfiles returns the counters of every rule, in rule order, or nil
+// without Options.ProfileRules
+func (s *Scanner) RuleProfiles() []RuleProfile {
+	if s.profiler == nil {
+		return nil
+	}
+	profiles := make([]RuleProfile, s.rules.Len())
+	for i := range profiles {
+		profiles[i].Rule = &s.rules.Rules[i]
+	}
+	for _, shard := range s.profiler.shards {
+		for i := range shard {
+			c := &shard[i]
+			profiles[i].Evaluations += c.evaluations.Load()
+			profiles[i].Hits += c.hits.Load()
+			profiles[i].URLs += c.urls.Load()
+			profiles[i].Time += time.Duration(c.nanos.Load())
+		}
+	}
+	return profiles
+}
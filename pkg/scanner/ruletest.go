@@ -0,0 +1,206 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ==============================================
+// PATTERN TESTS
+// ==============================================
+// Rules carry examples they must match and negatives they must not (see
+// the .txt and .toml formats). A test corpus adds expectations about
+// whole URLs: every URL line may be followed by
+//
+//	#+ admin_panels        Some rule with this ID or category matches it
+//	#- secrets             No rule with this ID or category matches it
+//
+// RunRuleTests checks both, the way a scan would match them. Expectations
+// naming no loaded rule are skipped, so one corpus serves any set of files.
+
+// Test failure kinds
+const (
+	TestMissedExample = "missed-example" // A rule does not match one of its examples
+	TestHitNegative   = "hit-negative"   // A rule matches one of its negatives
+	TestMissedURL     = "missed-url"     // No selected rule matches a corpus URL marked #+
+	TestHitURL        = "hit-url"        // A selected rule matches a corpus URL marked #-
+)
+
+// CorpusURL is a URL of a test corpus with its expectations
+type CorpusURL struct {
+	URL    string
+	Line   int
+	Expect []string // Rule IDs or categories, one of which must match
+	Reject []string // Rule IDs or categories none of which may match
+}
+
+type TestFailure struct {
+	Kind     string `json:"kind"`
+	File     string `json:"file"` // Rule file, or the corpus for URL expectations
+	Line     int    `json:"line,omitempty"`
+	RuleID   string `json:"rule_id,omitempty"`
+	Input    string `json:"input"`
+	Selector string `json:"selector,omitempty"` // The #+/#- selector of a corpus URL
+	Message  string `json:"message"`
+}
+
+// TestOptions configures RunRuleTests
+type TestOptions struct {
+	Corpus     []CorpusURL
+	CorpusName string // Reported as the File of corpus failures
+	MinScore   int    // Occurrences scoring lower do not count, as with Options.MinScore
+}
+
+type TestReport struct {
+	Rules      int           `json:"rules"`
+	Tested     int           `json:"tested"`   // Rules with at least one example or negative
+	Examples   int           `json:"examples"` // Examples and negatives checked
+	CorpusURLs int           `json:"corpus_urls"`
+	Checks     int           `json:"checks"`  // Examples, negatives and corpus expectations
+	Skipped    int           `json:"skipped"` // Corpus expectations naming no loaded rule
+	Failures   []TestFailure `json:"failures"`
+}
+
+// LoadTestCorpus reads a test corpus file
+func LoadTestCorpus(path string) ([]CorpusURL, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseTestCorpus(file, path)
+}
+
+// ParseTestCorpus reads URLs, one per line, with their #+ and #- lines.
+// Other '#' lines are comments. name is used in errors.
+func ParseTestCorpus(r io.Reader, name string) ([]CorpusURL, error) {
+	var corpus []CorpusURL
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for lines.Scan() {
+		lineNum++
+		line := strings.TrimSpace(lines.Text())
+		if text, positive, ok := exampleLine(line); ok {
+			if len(corpus) == 0 {
+				return nil, fmt.Errorf("%s line %d: expectation before any URL", name, lineNum)
+			}
+			selectors := SplitList(text)
+			last := &corpus[len(corpus)-1]
+			if positive {
+				last.Expect = append(last.Expect, selectors...)
+			} else {
+				last.Reject = append(last.Reject, selectors...)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		corpus = append(corpus, CorpusURL{URL: line, Line: lineNum})
+	}
+	return corpus, lines.Err()
+}
+
+// RunRuleTests matches every rule against its examples and negatives, and
+// the whole rule set against the corpus
+func RunRuleTests(rs *RuleSet, opts TestOptions) *TestReport {
+	report := &TestReport{Rules: rs.Len(), CorpusURLs: len(opts.Corpus)}
+	matching := func(input string) map[int]bool {
+		indexes := make(map[int]bool)
+		for _, match := range rs.engine.match(urlComponents(input), nil) {
+			if opts.MinScore > 0 && len(dropBelow(match.Occurrences, opts.MinScore)) == 0 {
+				continue
+			}
+			indexes[match.Index] = true
+		}
+		return indexes
+	}
+	fail := func(kind string, rule *Rule, input, format string, args ...any) {
+		report.Failures = append(report.Failures, TestFailure{
+			Kind: kind, File: rule.SourceFile, Line: rule.Line, RuleID: rule.ID, Input: input, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if len(rule.Examples) == 0 && len(rule.Negatives) == 0 {
+			continue
+		}
+		report.Tested++
+		for _, example := range rule.Examples {
+			report.Examples++
+			if !matching(example)[i] {
+				fail(TestMissedExample, rule, example, "does not match its example '%s'", example)
+			}
+		}
+		for _, negative := range rule.Negatives {
+			report.Examples++
+			if matching(negative)[i] {
+				fail(TestHitNegative, rule, negative, "matches its negative '%s'", negative)
+			}
+		}
+	}
+	report.Checks = report.Examples
+
+	for _, entry := range opts.Corpus {
+		if len(entry.Expect) == 0 && len(entry.Reject) == 0 {
+			continue
+		}
+		matched := matching(entry.URL)
+		failURL := func(kind, selector, ruleID, format string, args ...any) {
+			report.Failures = append(report.Failures, TestFailure{
+				Kind: kind, File: opts.CorpusName, Line: entry.Line, RuleID: ruleID, Input: entry.URL, Selector: selector, Message: fmt.Sprintf(format, args...),
+			})
+		}
+		for _, selector := range entry.Expect {
+			if !selectsAny(selector, rs.Rules) {
+				report.Skipped++
+				continue
+			}
+			report.Checks++
+			found := false
+			for i := range matched {
+				if selects(selector, &rs.Rules[i]) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				failURL(TestMissedURL, selector, "", "no '%s' rule matches '%s'", selector, entry.URL)
+			}
+		}
+		for _, selector := range entry.Reject {
+			if !selectsAny(selector, rs.Rules) {
+				report.Skipped++
+				continue
+			}
+			report.Checks++
+			for i := range rs.Rules {
+				if matched[i] && selects(selector, &rs.Rules[i]) {
+					rule := &rs.Rules[i]
+					failURL(TestHitURL, selector, rule.ID, "%s (%s:%d) matches '%s', marked #- %s", rule.ID, rule.SourceFile, rule.Line, entry.URL, selector)
+				}
+			}
+		}
+	}
+	return report
+}
+
+// selects reports whether a #+ or #- selector names the rule's ID or
+// category
+func selects(selector string, rule *Rule) bool {
+	return selector == rule.ID || strings.EqualFold(selector, rule.Category)
+}
+
+func selectsAny(selector string, rules []Rule) bool {
+	for i := range rules {
+		if selects(selector, &rules[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,439 @@
+// Package scanner matches URLs, and optionally their HTTP responses, against
+// CodeHunter rule files. It is the engine behind the codehunter command:
+//
+//	rules, err := scanner.LoadRules([]string{"secrets.txt"}, scanner.LoadOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	s, err := scanner.New(scanner.Options{Rules: rules, Workers: 20})
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	for match := range s.Scan(ctx, urls) {
+//		fmt.Println(match.URL, match.Rule.ID, match.Values())
+//	}
+//	if err := s.Err(); err != nil {
+//		return err
+//	}
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultWorkers = 10
+
+// Occurrence is a single regex hit, with byte offsets into the matched
+// component (the whole URL unless the rule is scoped)
+type Occurrence struct {
+	Value     string    `json:"value"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Component Component `json:"component"`
+	Key       string    `json:"key,omitempty"`      // Query parameter, path segment index or header name
+	Line      int       `json:"line,omitempty"`     // Body line (fetch mode)
+	Column    int       `json:"column,omitempty"`   // Body column in characters (fetch mode)
+	Score     int       `json:"score"`              // Confidence 0-100, see Rule.Score
+	Failed    []string  `json:"failed,omitempty"`   // Validators the value failed
+	JWT       *JWTInfo  `json:"jwt,omitempty"`      // Set when the value holds a JWT
+	Decoding  []Decoder `json:"decoding,omitempty"` // Decoders that produced the matched text, outermost first
+}
+
+// String renders the occurrence for the text log, naming the component
+// when the match did not come from the whole URL and the decoding chain
+// when it came from a decoded view
+func (o Occurrence) String() string {
+	s := o.Value
+	switch {
+	case o.Component == ComponentURL || o.Component == "":
+	case o.Line > 0:
+		s = fmt.Sprintf("%s [%s:%d:%d]", o.Value, o.Component, o.Line, o.Column)
+	case o.Key != "":
+		s = fmt.Sprintf("%s [%s:%s]", o.Value, o.Component, o.Key)
+	default:
+		s = fmt.Sprintf("%s [%s]", o.Value, o.Component)
+	}
+	if len(o.Decoding) > 0 {
+		s += fmt.Sprintf(" [decoded: %s]", DecodingChain(o.Decoding))
+	}
+	return s
+}
+
+// Match is one rule matching one URL
+type Match struct {
+	URL         string
+	Seq         int64 // Position of the URL in the input, see Result
+	Rule        *Rule // Points into the scanner's RuleSet
+	Occurrences []Occurrence
+	WorkerID    int
+	Timestamp   time.Time
+}
+
+// Score is the highest score among the occurrences
+func (m Match) Score() int {
+	score := 0
+	for _, occ := range m.Occurrences {
+		score = max(score, occ.Score)
+	}
+	return score
+}
+
+// Values returns the rendered occurrences in the order they were found
+func (m Match) Values() []string {
+	values := make([]string, len(m.Occurrences))
+	for i, occ := range m.Occurrences {
+		values[i] = occ.String()
+	}
+	return values
+}
+
+// Result is the outcome of scanning one URL, matched or not
+type Result struct {
+	URL      string
+	Seq      int64 // 0-based index among the URL lines of the input
+	Offset   int64 // Input byte offset just past this URL's line
+	WorkerID int
+	Matches  []Match // In rule order
+	Fetch    *FetchResult
+	FetchErr error
+}
+
+// Options configures a Scanner. Rules is required.
+type Options struct {
+	Rules       *RuleSet
+	Workers     int // Concurrent URLs, DefaultWorkers if 0
+	Fetch       bool
+	FetchConfig FetchConfig
+	MinScore    int // Occurrences scoring lower are dropped, see Rule.Score
+	Decode      DecodeConfig
+
+	// Scope, if set, skips the input URLs it does not contain and Exclude
+	// those it contains. Dedupe, if set, skips input URLs it has already
+	// seen. Skipped lines get no Seq and no Result, see Admit.
+	Scope   *ScopeList
+	Exclude *ScopeList
+	Dedupe  *URLDeduper
+
+	// ProfileRules counts the evaluations, hits and time of every rule,
+	// see RuleProfiles. It costs two clock reads per regex run.
+	ProfileRules bool
+
+	// OnResult, if set, is called for every scanned URL from the worker
+	// goroutine that scanned it, before its matches are sent on the Scan
+	// channel. It must be safe for concurrent use.
+	OnResult func(Result)
+}
+
+// Stats are running totals over everything a Scanner has scanned
+type Stats struct {
+	URLsProcessed      int                       `json:"urls_processed"`
+	URLsMatched        int                       `json:"urls_matched"`
+	URLsFetched        int                       `json:"urls_fetched"`
+	FetchErrors        int                       `json:"fetch_errors"`
+	Findings           int                       `json:"findings"`
+	OutOfScope         int                       `json:"out_of_scope,omitempty"`       // Input URLs outside Options.Scope
+	Excluded           int                       `json:"excluded,omitempty"`           // Input URLs in Options.Exclude
+	DuplicatesSkipped  int                       `json:"duplicates_skipped,omitempty"` // Input URLs dropped by Options.Dedupe
+	FindingsBySeverity [SeverityCritical + 1]int `json:"findings_by_severity"`         // Matches per rule severity
+}
+
+// Scanner applies a RuleSet to URLs. Scan runs one input at a time;
+// ScanURL may be called concurrently.
+type Scanner struct {
+	opts    Options
+	rules   *RuleSet
+	fetcher *Fetcher
+
+	mu       sync.Mutex
+	stats    Stats
+	last     *scanState    // State of the last Scan
+	profiler *ruleProfiler // nil without Options.ProfileRules
+}
+
+type scanState struct {
+	mu  sync.Mutex
+	err error
+}
+
+// setErr records the first error that stopped a scan
+func (st *scanState) setErr(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.err == nil {
+		st.err = err
+	}
+}
+
+func New(opts Options) (*Scanner, error) {
+	if opts.Rules == nil || opts.Rules.Len() == 0 {
+		return nil, errors.New("scanner: no rules")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	s := &Scanner{opts: opts, rules: opts.Rules}
+	if opts.ProfileRules {
+		s.profiler = newRuleProfiler(opts.Rules.Len(), opts.Workers)
+	}
+	if opts.Fetch {
+		fetcher, err := NewFetcher(opts.FetchConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.fetcher = fetcher
+	}
+	return s, nil
+}
+
+// Close releases the HTTP client used in fetch mode
+func (s *Scanner) Close() {
+	if s.fetcher != nil {
+		s.fetcher.Close()
+	}
+}
+
+func (s *Scanner) Rules() *RuleSet { return s.rules }
+
+func (s *Scanner) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Err returns the error, if any, that stopped the last Scan before the end of
+// its input: a read error, or ctx.Err() if it was cancelled. Call it after
+// the match channel is closed.
+func (s *Scanner) Err() error {
+	s.mu.Lock()
+	st := s.last
+	s.mu.Unlock()
+	if st == nil {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err
+}
+
+type inputURL struct {
+	url    string
+	seq    int64
+	offset int64
+}
+
+// Scan reads one URL per line from r (blank lines and '#' comments are
+// skipped), scans them with the configured number of workers and sends every
+// match on the returned channel, which is closed once the input is exhausted
+// or ctx is cancelled. The channel must be drained.
+//
+// On cancellation no new URLs are started. URLs already being scanned
+// finish and their matches are still delivered, except those whose fetch
+// was cut short: they are dropped, as if never read, and OnResult is not
+// called for them.
+func (s *Scanner) Scan(ctx context.Context, r io.Reader) <-chan Match {
+	urls := make(chan inputURL, s.opts.Workers*2)
+	matches := make(chan Match, s.opts.Workers*2)
+	st := &scanState{}
+	s.mu.Lock()
+	s.last = st
+	s.mu.Unlock()
+
+	// The reader may stay blocked in Read after cancellation (e.g. an idle
+	// stdin), so workers watch ctx instead of waiting for urls to close.
+	go func() {
+		defer close(urls)
+		if err := s.readURLs(ctx, r, urls); err != nil {
+			st.setErr(err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for {
+				var in inputURL
+				var ok bool
+				select {
+				case in, ok = <-urls:
+				case <-ctx.Done():
+				}
+				if !ok || ctx.Err() != nil {
+					return
+				}
+
+				result := s.scanURL(ctx, in.url, workerID)
+				if result.FetchErr != nil && ctx.Err() != nil {
+					return // Cancelled mid-fetch
+				}
+				result.Seq, result.Offset = in.seq, in.offset
+				for i := range result.Matches {
+					result.Matches[i].Seq = in.seq
+				}
+				s.record(result)
+				if s.opts.OnResult != nil {
+					s.opts.OnResult(result)
+				}
+				for _, m := range result.Matches {
+					matches <- m
+				}
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		if ctx.Err() != nil {
+			st.setErr(ctx.Err())
+		}
+		close(matches)
+	}()
+	return matches
+}
+
+func (s *Scanner) readURLs(ctx context.Context, r io.Reader, urls chan<- inputURL) error {
+	reader := bufio.NewReader(r)
+	var seq, offset int64
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if url := strings.TrimSpace(line); url != "" && !strings.HasPrefix(url, "#") && s.Admit(url) {
+			select {
+			case urls <- inputURL{url: url, seq: seq, offset: offset}:
+				seq++
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Admit reports whether an input URL is to be scanned: it must be in
+// Options.Scope, not in Options.Exclude and new to Options.Dedupe. URLs
+// turned away are counted in Stats. Scan calls it for every input line;
+// call it for lines skipped another way, such as the part of the input a
+// resumed scan already covered, to keep the counts and the deduper whole.
+func (s *Scanner) Admit(url string) bool {
+	var skipped *int
+	switch {
+	case s.opts.Scope != nil && !s.opts.Scope.Contains(url):
+		skipped = &s.stats.OutOfScope
+	case s.opts.Exclude != nil && s.opts.Exclude.Contains(url):
+		skipped = &s.stats.Excluded
+	case s.opts.Dedupe != nil && !s.opts.Dedupe.Add(url):
+		skipped = &s.stats.DuplicatesSkipped
+	default:
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*skipped++
+	return false
+}
+
+// ScanURL scans a single URL, fetching it first in fetch mode
+func (s *Scanner) ScanURL(ctx context.Context, url string) Result {
+	result := s.scanURL(ctx, url, 0)
+	s.record(result)
+	return result
+}
+
+func (s *Scanner) scanURL(ctx context.Context, url string, workerID int) Result {
+	result := Result{URL: url, WorkerID: workerID}
+
+	views := []ComponentView{{Component: ComponentURL, Value: url}}
+	if s.rules.scoped {
+		views = urlComponents(url)
+	}
+	if len(s.opts.Decode.Decoders) > 0 {
+		views = append(views, decodeViews(views, s.opts.Decode)...)
+	}
+	var body string
+	if s.fetcher != nil {
+		result.Fetch, result.FetchErr = s.fetcher.Fetch(ctx, url)
+		if result.FetchErr == nil {
+			views = append(views, result.Fetch.Views()...)
+			body = result.Fetch.Body
+		}
+	}
+	var bodyLines lineIndex
+
+	// One prefiltered pass over the URL (or the scoped components) instead of every regex in turn
+	var counters []ruleCounters
+	if s.profiler != nil {
+		counters = s.profiler.shard(workerID)
+	}
+	timestamp := time.Now()
+	for _, match := range s.rules.engine.match(views, counters) {
+		if s.opts.MinScore > 0 {
+			match.Occurrences = dropBelow(match.Occurrences, s.opts.MinScore)
+			if len(match.Occurrences) == 0 {
+				continue
+			}
+		}
+		if counters != nil {
+			counters[match.Index].hits.Add(int64(len(match.Occurrences)))
+			counters[match.Index].urls.Add(1)
+		}
+		locateBodyOccurrences(match.Occurrences, body, &bodyLines)
+		for i := range match.Occurrences {
+			match.Occurrences[i].JWT = AnalyzeJWT(match.Occurrences[i].Value, timestamp)
+		}
+		result.Matches = append(result.Matches, Match{
+			URL:         url,
+			Rule:        &s.rules.Rules[match.Index],
+			Occurrences: match.Occurrences,
+			WorkerID:    workerID,
+			Timestamp:   timestamp,
+		})
+	}
+	return result
+}
+
+func dropBelow(occurrences []Occurrence, minScore int) []Occurrence {
+	kept := occurrences[:0]
+	for _, occ := range occurrences {
+		if occ.Score >= minScore {
+			kept = append(kept, occ)
+		}
+	}
+	return kept
+}
+
+// record adds a scanned URL to the running totals
+func (s *Scanner) record(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Add(result)
+}
+
+// Add counts one scanned URL
+func (st *Stats) Add(result Result) {
+	st.URLsProcessed++
+	if result.FetchErr != nil {
+		st.FetchErrors++
+	} else if result.Fetch != nil {
+		st.URLsFetched++
+	}
+	if len(result.Matches) > 0 {
+		st.URLsMatched++
+	}
+	for _, m := range result.Matches {
+		st.Findings++
+		st.FindingsBySeverity[m.Rule.Severity]++
+	}
+}
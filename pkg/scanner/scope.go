@@ -0,0 +1,284 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ==============================================
+// SCOPE & EXCLUSION LISTS
+// ==============================================
+// Bug bounty programs list what may be tested. A scope file holds one entry
+// per line ('#' comments and text after the first word are ignored):
+//
+//	example.com                  The host itself
+//	*.example.com                The domain and all its subdomains
+//	api-*.example.com            '*' elsewhere matches any run of characters
+//	https://example.com:8443     Scheme and port must match too
+//	example.com/api/             Host and path prefix
+//	/admin/                      Path prefix on any host
+//	10.0.0.0/8, 192.0.2.10       IP literals in the range (hosts are not resolved)
+//	re:^https?://[^/]+/v[0-9]+/  Regexp matched against the whole URL
+//
+// HackerOne scope CSV exports are read as well: rows of the URL, WILDCARD,
+// DOMAIN, CIDR and IP_ADDRESS types become entries, and rows not eligible
+// for submission (the out-of-scope assets) are kept out of the list.
+
+// ScopeList is a parsed scope file
+type ScopeList struct {
+	Name     string // File it was loaded from
+	entries  []scopeEntry
+	excluded []scopeEntry // Out-of-scope rows of a CSV export
+}
+
+type scopeEntry struct {
+	text       string
+	re         *regexp.Regexp
+	cidr       *net.IPNet
+	scheme     string
+	host       string         // Lowercased; "" matches any host
+	subdomains bool           // host also matches its subdomains
+	hostGlob   *regexp.Regexp // Host with wildcards
+	port       string
+	path       string // Prefix of the URL path
+}
+
+// scopeAssetTypes are the HackerOne asset types that name web targets
+var scopeAssetTypes = map[string]bool{"URL": true, "WILDCARD": true, "DOMAIN": true, "CIDR": true, "IP_ADDRESS": true}
+
+func LoadScope(path string) (*ScopeList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseScope(file, path)
+}
+
+// ParseScope reads a scope file; name is used in errors
+func ParseScope(r io.Reader, name string) (*ScopeList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM of spreadsheet exports
+	list := &ScopeList{Name: name}
+	if isScopeCSV(data) {
+		return list, list.parseCSV(data)
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseScopeEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", name, i+1, err)
+		}
+		list.entries = append(list.entries, entry)
+	}
+	return list, nil
+}
+
+// isScopeCSV reports whether data starts with a CSV header naming the
+// identifier and asset_type columns
+func isScopeCSV(data []byte) bool {
+	header, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	columns := strings.Split(strings.ToLower(string(header)), ",")
+	return scopeColumn(columns, "identifier") >= 0 && scopeColumn(columns, "asset_type") >= 0
+}
+
+func scopeColumn(columns []string, name string) int {
+	for i, column := range columns {
+		if strings.Trim(strings.TrimSpace(column), `"`) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *ScopeList) parseCSV(data []byte) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("%s: %w", l.Name, err)
+	}
+	header := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+	}
+	identifier, assetType, eligible := scopeColumn(header, "identifier"), scopeColumn(header, "asset_type"), scopeColumn(header, "eligible_for_submission")
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	for row, record := range records[1:] {
+		if !scopeAssetTypes[strings.ToUpper(field(record, assetType))] {
+			continue
+		}
+		// An identifier may list several targets
+		for _, target := range strings.FieldsFunc(field(record, identifier), func(r rune) bool { return r == ',' || r == ' ' }) {
+			entry, err := parseScopeEntry(target)
+			if err != nil {
+				return fmt.Errorf("%s row %d: %w", l.Name, row+2, err)
+			}
+			if strings.EqualFold(field(record, eligible), "false") {
+				l.excluded = append(l.excluded, entry)
+			} else {
+				l.entries = append(l.entries, entry)
+			}
+		}
+	}
+	return nil
+}
+
+func parseScopeEntry(text string) (scopeEntry, error) {
+	for _, prefix := range []string{"re:", "regex:"} {
+		if strings.HasPrefix(text, prefix) {
+			re, err := regexp.Compile(text[len(prefix):])
+			if err != nil {
+				return scopeEntry{}, fmt.Errorf("invalid regexp '%s': %w", text, err)
+			}
+			return scopeEntry{text: text, re: re}, nil
+		}
+	}
+	text = strings.Fields(text)[0]
+	entry := scopeEntry{text: text}
+	if _, cidr, err := net.ParseCIDR(text); err == nil {
+		entry.cidr = cidr
+		return entry, nil
+	}
+	if ip := net.ParseIP(strings.Trim(text, "[]")); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		entry.cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		return entry, nil
+	}
+	if strings.HasPrefix(text, "/") {
+		entry.path = text
+		return entry, nil
+	}
+	raw := text
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return scopeEntry{}, fmt.Errorf("invalid scope entry '%s'", text)
+	}
+	entry.scheme = strings.ToLower(u.Scheme)
+	entry.port = u.Port()
+	entry.path = u.Path
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	switch {
+	case strings.HasPrefix(host, "*.") && !strings.Contains(host[2:], "*"):
+		entry.host, entry.subdomains = host[2:], true
+	case strings.Contains(host, "*"):
+		entry.hostGlob = regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(host), `\*`, ".*") + "$")
+	default:
+		entry.host = host
+	}
+	return entry, nil
+}
+
+// Len is the number of entries, out-of-scope CSV rows included
+func (l *ScopeList) Len() int { return len(l.entries) + len(l.excluded) }
+
+// Contains reports whether rawURL matches an entry of the list, and no
+// out-of-scope row of a CSV export
+func (l *ScopeList) Contains(rawURL string) bool {
+	u := parseScopeURL(rawURL)
+	return matchesScope(l.entries, rawURL, u) && !matchesScope(l.excluded, rawURL, u)
+}
+
+// Hash identifies the entries of the list
+func (l *ScopeList) Hash() string {
+	h := sha256.New()
+	for _, e := range l.entries {
+		fmt.Fprintf(h, "+%q\n", e.text)
+	}
+	for _, e := range l.excluded {
+		fmt.Fprintf(h, "-%q\n", e.text)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseScopeURL parses an input URL, which may lack a scheme. It returns
+// nil if it cannot be parsed: only regexps match it then.
+func parseScopeURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err == nil && u.Host == "" && !strings.Contains(rawURL, "://") {
+		u, err = url.Parse("//" + rawURL)
+	}
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+func matchesScope(entries []scopeEntry, rawURL string, u *url.URL) bool {
+	for _, e := range entries {
+		if e.matches(rawURL, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e scopeEntry) matches(rawURL string, u *url.URL) bool {
+	if e.re != nil {
+		return e.re.MatchString(rawURL)
+	}
+	if u == nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if e.cidr != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && e.cidr.Contains(ip)
+	}
+	if e.scheme != "" && !strings.EqualFold(u.Scheme, e.scheme) {
+		return false
+	}
+	switch {
+	case e.hostGlob != nil:
+		if !e.hostGlob.MatchString(host) {
+			return false
+		}
+	case e.subdomains:
+		if host != e.host && !strings.HasSuffix(host, "."+e.host) {
+			return false
+		}
+	case e.host != "":
+		if host != e.host {
+			return false
+		}
+	}
+	if e.port != "" {
+		port := u.Port()
+		if port == "" {
+			port = defaultPorts[strings.ToLower(u.Scheme)]
+		}
+		if port != e.port {
+			return false
+		}
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.HasPrefix(path, e.path)
+}
@@ -0,0 +1,606 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==============================================
+// MINIMAL TOML PARSER
+// ==============================================
+// CodeHunter ships without third-party dependencies, so rule packs are read
+// with this small parser. It supports the TOML subset used by rule files:
+// tables, arrays of tables, dotted keys, basic/literal/multi-line strings,
+// integers, floats, booleans, arrays and inline tables. Dates are not supported.
+
+// tomlLineKey is set on every [table] and [[table]] to the line of its header,
+// so rule loaders can point users at the right place in the file.
+const tomlLineKey = "\x00line"
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// parseTOML decodes a TOML document into nested map[string]any values.
+// Arrays of tables are returned as []map[string]any, other arrays as []any.
+func parseTOML(src string) (map[string]any, error) {
+	p := &tomlParser{src: src, line: 1}
+	root := map[string]any{}
+	current := root
+
+	for {
+		p.skipWhitespaceAndNewlines()
+		if p.eof() {
+			return root, nil
+		}
+		var err error
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "[["):
+			p.pos += 2
+			current, err = p.parseTableHeader(root, true)
+		case p.peek() == '[':
+			p.pos++
+			current, err = p.parseTableHeader(root, false)
+		default:
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("toml line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) skipSpaces() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipWhitespaceAndNewlines skips blank lines and comments, as allowed
+// between statements and inside arrays.
+func (p *tomlParser) skipWhitespaceAndNewlines() {
+	for !p.eof() {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expectLineEnd() error {
+	p.skipSpaces()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	p.pos++
+	p.line++
+	return nil
+}
+
+func (p *tomlParser) parseTableHeader(root map[string]any, isArray bool) (map[string]any, error) {
+	keys, err := p.parseKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %q to close table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.walkTables(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	if isArray {
+		var list []map[string]any
+		switch existing := parent[last].(type) {
+		case nil:
+		case []map[string]any:
+			list = existing
+		default:
+			return nil, p.errorf("key %q is already defined and is not an array of tables", last)
+		}
+		table := map[string]any{tomlLineKey: p.line}
+		parent[last] = append(list, table)
+		return table, nil
+	}
+	switch existing := parent[last].(type) {
+	case nil:
+		table := map[string]any{tomlLineKey: p.line}
+		parent[last] = table
+		return table, nil
+	case map[string]any:
+		return existing, nil
+	default:
+		return nil, p.errorf("key %q is already defined and is not a table", last)
+	}
+}
+
+// walkTables descends through keys, creating intermediate tables and
+// selecting the last element of arrays of tables, as TOML headers do.
+func (p *tomlParser) walkTables(table map[string]any, keys []string) (map[string]any, error) {
+	for _, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			child := map[string]any{}
+			table[key] = child
+			table = child
+		case map[string]any:
+			table = next
+		case []map[string]any:
+			if len(next) == 0 {
+				return nil, p.errorf("empty array of tables %q", key)
+			}
+			table = next[len(next)-1]
+		default:
+			return nil, p.errorf("key %q is already defined and is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) parseKeyPath() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaces()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseKey() (string, error) {
+	switch p.peek() {
+	case '"':
+		return p.parseBasicString()
+	case '\'':
+		return p.parseLiteralString()
+	}
+	start := p.pos
+	for !p.eof() {
+		c := p.src[p.pos]
+		if c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if start == p.pos {
+		return "", p.errorf("expected key, found %q", p.peek())
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]any) error {
+	keys, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+	p.skipSpaces()
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpaces()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.walkTables(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return p.errorf("duplicate key %q", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		return p.parseMultilineBasicString()
+	case strings.HasPrefix(p.src[p.pos:], `'''`):
+		return p.parseMultilineLiteralString()
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	default:
+		return p.parseNumber()
+	}
+}
+
+func (p *tomlParser) parseNumber() (any, error) {
+	start := p.pos
+	for !p.eof() && strings.IndexByte("+-0123456789_.eExobabcdefABCDEFinfa", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	raw := strings.ReplaceAll(p.src[start:p.pos], "_", "")
+	if raw == "" {
+		return nil, p.errorf("unexpected %q where a value was expected", p.peek())
+	}
+	if n, err := strconv.ParseInt(raw, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f, nil
+	}
+	return nil, p.errorf("invalid value %q", raw)
+}
+
+func (p *tomlParser) parseArray() ([]any, error) {
+	p.pos++ // '['
+	values := []any{}
+	for {
+		p.skipWhitespaceAndNewlines()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipWhitespaceAndNewlines()
+		switch {
+		case p.eof():
+			return nil, p.errorf("unterminated array")
+		case p.peek() == ',':
+			p.pos++
+		case p.peek() == ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array, found %q", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]any, error) {
+	p.pos++ // '{'
+	table := map[string]any{}
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		p.skipSpaces()
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		switch {
+		case p.eof() || p.peek() == '\n':
+			return nil, p.errorf("unterminated inline table")
+		case p.peek() == ',':
+			p.pos++
+		case p.peek() == '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table, found %q", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++ // opening quote
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated literal string")
+	}
+	value := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return value, nil
+}
+
+func (p *tomlParser) parseMultilineLiteralString() (string, error) {
+	p.pos += 3
+	p.trimLeadingNewline()
+	end := strings.Index(p.src[p.pos:], `'''`)
+	if end < 0 {
+		return "", p.errorf("unterminated multi-line literal string")
+	}
+	// Up to two quotes may directly precede the closing delimiter
+	for end+3 < len(p.src[p.pos:]) && p.src[p.pos+end+3] == '\'' {
+		end++
+	}
+	value := p.src[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 3
+	return value, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++ // opening quote
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			return sb.String(), nil
+		}
+		if c == '\\' {
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseMultilineBasicString() (string, error) {
+	start := p.line
+	p.pos += 3
+	p.trimLeadingNewline()
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			p.line = start // Point at the opening quotes
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], `"""`) && !strings.HasPrefix(p.src[p.pos+1:], `"""`) {
+			p.pos += 3
+			return sb.String(), nil
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '\\' && p.lineEndingBackslash():
+			// Line-ending backslash trims the newline and following whitespace
+			p.pos++
+			for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+				if p.src[p.pos] == '\n' {
+					p.line++
+				}
+				p.pos++
+			}
+		case c == '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) lineEndingBackslash() bool {
+	rest := strings.TrimLeft(p.src[p.pos+1:], " \t\r")
+	return strings.HasPrefix(rest, "\n")
+}
+
+func (p *tomlParser) trimLeadingNewline() {
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+		p.line++
+	} else if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++ // backslash
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'e':
+		sb.WriteByte(0x1b)
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("short unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape %q", p.src[p.pos:p.pos+size])
+		}
+		sb.WriteRune(rune(code))
+		p.pos += size
+	default:
+		return p.errorf("invalid escape sequence \\%c (use a literal '...' string for regexes)", c)
+	}
+	return nil
+}
+
+// ==============================================
+// TOML VALUE HELPERS
+// ==============================================
+
+func tomlString(table map[string]any, key string) (string, error) {
+	switch v := table[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("'%s' must be a string", key)
+	}
+}
+
+func tomlStringList(table map[string]any, key string) ([]string, error) {
+	switch v := table[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must be a list of strings", key)
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a list of strings", key)
+	}
+}
+
+func tomlTableLine(table map[string]any) int {
+	line, _ := table[tomlLineKey].(int)
+	return line
+}
+
+func tomlTables(table map[string]any, key string) ([]map[string]any, error) {
+	switch v := table[key].(type) {
+	case nil:
+		return nil, nil
+	case []map[string]any:
+		return v, nil
+	case map[string]any:
+		return []map[string]any{v}, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a table or array of tables", key)
+	}
+}
+
+// ==============================================
+// TOML WRITING
+// ==============================================
+
+// tomlQuote writes s as a literal string if it can, which keeps regexes
+// readable, and as a basic string otherwise
+func tomlQuote(s string) string {
+	literal := !strings.Contains(s, "'") && utf8.ValidString(s)
+	for _, r := range s {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			literal = false
+		}
+	}
+	if literal {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'") && !strings.ContainsAny(s, "\r\n") && utf8.ValidString(s) {
+		return "'''" + s + "'''"
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func tomlQuoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = tomlQuote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want map[string]any
+	}{
+		{`a = "x" # comment` + "\nb = 'y#z'", map[string]any{"a": "x", "b": "y#z"}},
+		{`a = "tab\tq\"\\\u00e9\U0001F600"`, map[string]any{"a": "tab\tq\"\\é😀"}},
+		{`a = '\d+\.\w' # regexes stay literal`, map[string]any{"a": `\d+\.\w`}},
+		{"a = '''\n(?i)key\n'''", map[string]any{"a": "(?i)key\n"}},
+		{"a = '''it's ''quoted'''''", map[string]any{"a": "it's ''quoted''"}},
+		{"a = \"\"\"\nl1\n  l2\\n\"\"\"", map[string]any{"a": "l1\n  l2\n"}},
+		{"a = \"\"\"one \\\n    two \\\n\n  three\"\"\"", map[string]any{"a": "one two three"}},
+		{`a = """say "hi"""""`, map[string]any{"a": `say "hi""`}},
+		{"a = [\n  'x', # first\n  \"y\",\n\n  # blank and comment lines\n  'z',\n]", map[string]any{"a": []any{"x", "y", "z"}}},
+		{"a = [[1, 2], ['b']]", map[string]any{"a": []any{[]any{int64(1), int64(2)}, []any{"b"}}}},
+		{"a = 1_000\nb = -0.5\nc = 0x1f\nd = true\ne = false\nf = 1e3", map[string]any{"a": int64(1000), "b": -0.5, "c": int64(31), "d": true, "e": false, "f": 1000.0}},
+		{"a = { b = 'c', d.e = 1 }", map[string]any{"a": map[string]any{"b": "c", "d": map[string]any{"e": int64(1)}}}},
+		{"x.y = 1\n\"quoted key\" = 2\n'lit.key' = 3", map[string]any{"x": map[string]any{"y": int64(1)}, "quoted key": int64(2), "lit.key": int64(3)}},
+		{"[a]\nb = 1\n[a.c]\nd = 2", map[string]any{"a": map[string]any{"b": int64(1), "c": map[string]any{"d": int64(2)}}}},
+		{"[[r]]\nid = 'a'\n[r.s]\nk = 1\n[[r]]\nid = 'b'", map[string]any{"r": []map[string]any{
+			{"id": "a", "s": map[string]any{"k": int64(1)}}, {"id": "b"},
+		}}},
+		{"\r\na = 'x'\r\n[t]\r\nb = [\r\n 1,\r\n]\r\n", map[string]any{"a": "x", "t": map[string]any{"b": []any{int64(1)}}}},
+	} {
+		got, err := parseTOML(tc.src)
+		if err != nil {
+			t.Errorf("%q: %v", tc.src, err)
+			continue
+		}
+		if got := withoutLines(got); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\ngot  %#v\nwant %#v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`a = "open`:                      "toml line 1: unterminated string",
+		"a = \"open\nb = 1":              "toml line 1: unterminated string",
+		"a = 'open":                      "toml line 1: unterminated literal string",
+		"a = 1\nb = '''open\n\n":         "toml line 2: unterminated multi-line literal string",
+		"a = 1\nb = \"\"\"open\nmore\n":  "toml line 2: unterminated multi-line string",
+		`a = "\q"`:                       `toml line 1: invalid escape sequence \q`,
+		`a = "\u00"`:                     "toml line 1: short unicode escape",
+		`a = "\uZZZZ"`:                   "toml line 1: invalid unicode escape",
+		"a = [1,\n2":                     "toml line 2: unterminated array",
+		"a = [1 2]":                      "toml line 1: expected ',' or ']' in array",
+		"a = {b = 1\nc = 2}":             "toml line 1: unterminated inline table",
+		"a = {b = 1 c = 2}":              "toml line 1: expected ',' or '}' in inline table",
+		"a = 1\nb = 2\na = 3":            `toml line 3: duplicate key "a"`,
+		"[t]\nx.y = 1\nx.y = 2":          `toml line 3: duplicate key "x.y"`,
+		"a = {b = 1, b = 2}":             `toml line 1: duplicate key "b"`,
+		"[t":                             `toml line 1: expected "]" to close table header`,
+		"[[t]\n":                         `toml line 1: expected "]]" to close table header`,
+		"[]":                             "toml line 1: expected key",
+		"a = 1\n[a]":                     `toml line 2: key "a" is already defined and is not a table`,
+		"a = 1\n[a.b]":                   `toml line 2: key "a" is already defined and is not a table`,
+		"[a]\n[[a]]":                     `toml line 2: key "a" is already defined and is not an array of tables`,
+		"a = 1 b = 2":                    "toml line 1: unexpected 'b' after value",
+		"a 1":                            `toml line 1: expected '=' after key "a"`,
+		"a =":                            "toml line 1: unexpected",
+		"a = 2024-01-01":                 `toml line 1: invalid value "2024-01-01"`,
+		"# c\n\n[r]\nregex = \"\\d+\"\n": `toml line 4: invalid escape sequence \d (use a literal '...' string for regexes)`,
+	} {
+		_, err := parseTOML(src)
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: error %v, want %s...", src, err, want)
+		}
+	}
+}
+
+func TestParseTOMLLines(t *testing.T) {
+	doc, err := parseTOML("# header\n[[rule]]\nid = 'a'\nregex = '''\nx\n'''\n\n[[rule]]\nid = 'b'\n[rule.meta]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _ := tomlTables(doc, "rule")
+	if len(rules) != 2 || tomlTableLine(rules[0]) != 2 || tomlTableLine(rules[1]) != 8 {
+		t.Fatalf("rules %v", rules)
+	}
+	if meta, _ := rules[1]["meta"].(map[string]any); tomlTableLine(meta) != 10 {
+		t.Errorf("[rule.meta] on line %d, want 10", tomlTableLine(meta))
+	}
+}
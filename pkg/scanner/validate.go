@@ -0,0 +1,379 @@
+package scanner
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ==============================================
+// VALIDATORS & CONFIDENCE SCORES
+// ==============================================
+// A regex cannot tell "api_key=9fQ2xLw7Tn4kZp1R" from "api_key=your_api_key_here".
+// Validators look at each occurrence after it matched and turn the rule's
+// confidence into a 0-100 score for that occurrence:
+//
+//	#@ validate: entropy=3.5, charset=alnum, placeholder      (.txt)
+//	validate = ["checksum=github"]                             (.toml)
+//
+// They check the secret part of the occurrence: the regex group named
+// "secret" if there is one, otherwise the value after the last '=', ':' or
+// space, with quotes trimmed.
+
+// Scores a rule's confidence starts from before validators adjust it
+const (
+	ScoreLow    = 40
+	ScoreMedium = 60
+	ScoreHigh   = 80
+)
+
+const (
+	softPassBonus   = 10 // Entropy or charset check passed
+	softFailPenalty = 25
+	strongPassBonus = 15 // Format or checksum check passed
+	strongFailCap   = 10 // Highest score after a placeholder or failed format
+)
+
+// Validator is one post-match check parsed from a "name[=arg]" spec
+type Validator struct {
+	Spec   string // As written in the rule file, e.g. "entropy=3.5"
+	strong bool   // Failing rules the occurrence out; passing is strong evidence
+	drop   bool   // Failing drops the occurrence; passing leaves the score alone
+	check  func(secret string) bool
+}
+
+// ParseValidator parses a validator spec:
+//
+//	entropy=N        Shannon entropy of at least N bits per character
+//	charset=NAME     only characters of alnum, hex, base64, base64url, base32, digits, upper or lower
+//	placeholder      not a known placeholder (example, your_..._here, xxxx, changeme, ...)
+//	deny=A|B         contains none of these substrings (case-insensitive)
+//	exclude=REGEX    is not matched by the regex (commas need the .toml list form)
+//	checksum=NAME    passes the github, jwt, aws or luhn format check
+//	allowlist=REGEX  like exclude=, but a value the regex matches is not reported
+//	stopwords=A|B    like deny=, but a value containing one is not reported
+func ParseValidator(spec string) (Validator, error) {
+	spec = strings.TrimSpace(spec)
+	name, arg, _ := strings.Cut(spec, "=")
+	name, arg = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(arg)
+	v := Validator{Spec: spec}
+	needArg := func() error {
+		if arg == "" {
+			return fmt.Errorf("validator '%s' needs a value, e.g. %s=...", spec, name)
+		}
+		return nil
+	}
+	switch name {
+	case "entropy":
+		if err := needArg(); err != nil {
+			return v, err
+		}
+		min, err := strconv.ParseFloat(arg, 64)
+		if err != nil || min < 0 || min > 8 {
+			return v, fmt.Errorf("validator '%s': entropy must be a number of bits per character from 0 to 8", spec)
+		}
+		v.check = func(secret string) bool { return ShannonEntropy(secret) >= min }
+	case "charset":
+		if err := needArg(); err != nil {
+			return v, err
+		}
+		allowed, ok := charsets[strings.ToLower(arg)]
+		if !ok {
+			return v, fmt.Errorf("validator '%s': unknown charset '%s' (use %s)", spec, arg, charsetNames)
+		}
+		v.check = func(secret string) bool { return secret != "" && inCharset(secret, allowed) }
+	case "placeholder":
+		v.strong = true
+		v.check = func(secret string) bool { return !IsPlaceholder(secret) }
+	case "deny", "stopwords":
+		if err := needArg(); err != nil {
+			return v, err
+		}
+		var words []string
+		for _, word := range strings.Split(arg, "|") {
+			if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+				words = append(words, word)
+			}
+		}
+		v.strong, v.drop = name == "deny", name == "stopwords"
+		v.check = func(secret string) bool { return !containsAny(strings.ToLower(secret), words) }
+	case "exclude", "allowlist":
+		if err := needArg(); err != nil {
+			return v, err
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return v, fmt.Errorf("validator '%s': invalid regex (%v)", spec, err)
+		}
+		v.strong, v.drop = name == "exclude", name == "allowlist"
+		v.check = func(secret string) bool { return !re.MatchString(secret) }
+	case "checksum":
+		if err := needArg(); err != nil {
+			return v, err
+		}
+		check, ok := checksums[strings.ToLower(arg)]
+		if !ok {
+			return v, fmt.Errorf("validator '%s': unknown checksum '%s' (use github, jwt, aws or luhn)", spec, arg)
+		}
+		v.strong = true
+		v.check = check
+	default:
+		return v, fmt.Errorf("unknown validator '%s' (use entropy, charset, placeholder, deny, exclude, checksum, allowlist or stopwords)", name)
+	}
+	return v, nil
+}
+
+func parseValidators(specs []string) ([]Validator, error) {
+	var validators []Validator
+	for _, spec := range specs {
+		v, err := ParseValidator(spec)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+	}
+	return validators, nil
+}
+
+// Check reports whether secret passes the validator
+func (v Validator) Check(secret string) bool { return v.check(secret) }
+
+func (v Validator) String() string { return v.Spec }
+
+// ConfidenceScore is the score a rule's confidence level starts from
+func ConfidenceScore(c Confidence) int {
+	switch c {
+	case ConfidenceLow:
+		return ScoreLow
+	case ConfidenceHigh:
+		return ScoreHigh
+	}
+	return ScoreMedium
+}
+
+// Allowlisted reports whether secret fails an allowlist= or stopwords=
+// validator, so its occurrence is not reported
+func (r *Rule) Allowlisted(secret string) bool {
+	for _, v := range r.Validators {
+		if v.drop && !v.check(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Score rates one secret matched by the rule from 0 to 100, returning the
+// validators it failed. Without validators the score is the rule's
+// confidence level (40, 60 or 80). Allowlists do not count, see Allowlisted.
+func (r *Rule) Score(secret string) (score int, failed []string) {
+	score = ConfidenceScore(r.Confidence)
+	capped := false
+	for _, v := range r.Validators {
+		if v.drop {
+			continue
+		}
+		switch ok := v.check(secret); {
+		case ok && v.strong:
+			score += strongPassBonus
+		case ok:
+			score += softPassBonus
+		case v.strong:
+			capped = true
+			failed = append(failed, v.Spec)
+		default:
+			score -= softFailPenalty
+			failed = append(failed, v.Spec)
+		}
+	}
+	if capped && score > strongFailCap {
+		score = strongFailCap
+	}
+	return min(max(score, 0), 100), failed
+}
+
+// ParseScore reads a --min-confidence value: a score from 0 to 100 or a
+// confidence level name, which stands for the score that level starts from
+func ParseScore(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 || n > 100 {
+			return 0, fmt.Errorf("confidence score %d is out of range (0-100)", n)
+		}
+		return n, nil
+	}
+	c, err := ParseConfidence(value)
+	if err != nil {
+		return 0, fmt.Errorf("unknown confidence '%s' (use a score from 0 to 100, or low, medium, high)", value)
+	}
+	return ConfidenceScore(c), nil
+}
+
+// secretPart is what validators look at when the regex has no "secret"
+// group: the value of a "key=value", "key: value" or "Bearer value" match.
+// Trailing '=' is base64 padding, not an assignment.
+func secretPart(value string) string {
+	value = strings.TrimRight(value, " \t\"'`")
+	if i := strings.LastIndexAny(strings.TrimRight(value, "="), "=: \t"); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.TrimLeft(value, " \t\"'`")
+}
+
+// ==============================================
+// ENTROPY, CHARSETS & PLACEHOLDERS
+// ==============================================
+
+// ShannonEntropy returns the entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+const (
+	digits = "0123456789"
+	upper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lower  = "abcdefghijklmnopqrstuvwxyz"
+)
+
+var charsets = map[string]string{
+	"alnum":     digits + upper + lower,
+	"hex":       digits + "abcdefABCDEF",
+	"base64":    digits + upper + lower + "+/=",
+	"base64url": digits + upper + lower + "-_=",
+	"base32":    upper + "234567=",
+	"digits":    digits,
+	"upper":     upper,
+	"lower":     lower,
+}
+
+const charsetNames = "alnum, hex, base64, base64url, base32, digits, upper, lower"
+
+func inCharset(s, allowed string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(allowed, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// placeholderWords mark values copied from documentation and templates
+var placeholderWords = []string{
+	"example", "sample", "placeholder", "changeme", "change_me", "dummy", "fake",
+	"redacted", "your_", "your-", "yourapi", "yourkey", "yoursecret", "yourtoken",
+	"_here", "-here", "insert", "replace", "xxxx", "****", "<", ">", "${", "{{", "%s",
+	"1234567890", "abcdefghij", "0123456789",
+}
+
+// IsPlaceholder reports whether a matched value looks like a documentation
+// or template placeholder rather than a real secret: it contains a known
+// placeholder word or is one character repeated.
+func IsPlaceholder(value string) bool {
+	if value == "" {
+		return true
+	}
+	if containsAny(strings.ToLower(value), placeholderWords) {
+		return true
+	}
+	return strings.Count(value, value[:1]) == len(value)
+}
+
+func containsAny(s string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==============================================
+// FORMAT CHECKSUMS
+// ==============================================
+
+var checksums = map[string]func(string) bool{
+	"github": validGitHubToken,
+	"jwt":    validJWT,
+	"aws":    validAWSKeyID,
+	"luhn":   validLuhn,
+}
+
+const base62Alphabet = digits + upper + lower
+
+// validGitHubToken checks the CRC32 at the end of ghp_/gho_/ghu_/ghs_/ghr_
+// tokens: 30 random characters followed by their CRC32 in base62, padded
+// to 6 characters
+func validGitHubToken(token string) bool {
+	prefix, rest, found := strings.Cut(token, "_")
+	if !found || len(prefix) != 3 || !strings.HasPrefix(prefix, "gh") || len(rest) != 36 {
+		return false
+	}
+	random, checksum := rest[:30], rest[30:]
+	crc := crc32.ChecksumIEEE([]byte(random))
+	var encoded []byte
+	for ; crc > 0; crc /= 62 {
+		encoded = append([]byte{base62Alphabet[crc%62]}, encoded...)
+	}
+	for len(encoded) < 6 {
+		encoded = append([]byte{'0'}, encoded...)
+	}
+	return string(encoded) == checksum
+}
+
+// validJWT checks that the header and payload are base64url-encoded JSON
+// objects and that the header names an algorithm
+func validJWT(token string) bool { return parseJWT(token) != nil }
+
+// validAWSKeyID checks the prefix, length and base32 alphabet of an AWS
+// access key ID
+func validAWSKeyID(id string) bool {
+	if len(id) != 20 || !inCharset(id[4:], upper+"234567") {
+		return false
+	}
+	for _, prefix := range []string{"AKIA", "ASIA", "ABIA", "ACCA", "AGPA", "AIDA", "AROA", "AIPA", "ANPA", "ANVA", "APKA"} {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(id, "A3T")
+}
+
+// validLuhn checks the Luhn digit of a card number, ignoring spaces and dashes
+func validLuhn(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 12 && sum%10 == 0
+}
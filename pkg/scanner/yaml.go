@@ -0,0 +1,599 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==============================================
+// MINIMAL YAML PARSER
+// ==============================================
+// trufflehog detector files and nuclei templates are YAML. Like the TOML
+// parser, this one covers what those files use and nothing more: block
+// mappings and sequences, plain, quoted and block (| >) scalars, and flow
+// [...] and {...} collections. Every scalar is returned as a string; the
+// importers convert what they need. Anchors, aliases, tags and multiple
+// documents are not supported.
+
+// yamlLineKey is set on every mapping to the line of its first key, like
+// tomlLineKey
+const yamlLineKey = "\x00line"
+
+type yamlParser struct {
+	lines []string
+	pos   int // Index of the next line to read
+}
+
+type yamlLine struct {
+	indent int
+	text   string // Without indentation; comments are only stripped by the callers
+}
+
+// parseYAML decodes one YAML document into map[string]any, []any and
+// string values
+func parseYAML(src string) (any, error) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	src = strings.TrimSuffix(strings.ReplaceAll(src, "\r\n", "\n"), "\n") // Ends the last line, not a blank one
+	p := &yamlParser{lines: strings.Split(src, "\n")}
+	if line, ok := p.next(); ok && (line.text == "---" || strings.HasPrefix(line.text, "--- ")) {
+		p.pos++
+	}
+	line, ok := p.next()
+	if !ok {
+		return map[string]any{}, nil
+	}
+	doc, err := p.parseNode(line.indent)
+	if err != nil {
+		return nil, err
+	}
+	if line, ok := p.next(); ok {
+		if line.text == "---" || line.text == "..." {
+			return doc, p.errorf("multiple documents are not supported")
+		}
+		return nil, p.errorf("unexpected indentation")
+	}
+	return doc, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos+1, format, args...)
+}
+
+// errorAt reports an error on a line counted from 1. Scalars are parsed
+// after moving past their first line, so p.pos is that line's number.
+func (p *yamlParser) errorAt(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// next returns the next line that is neither blank nor a comment, leaving
+// p.pos on it
+func (p *yamlParser) next() (yamlLine, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos]
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") || strings.TrimSpace(text) == "" {
+			continue
+		}
+		return yamlLine{indent: len(raw) - len(text), text: strings.TrimRight(text, " \t")}, true
+	}
+	return yamlLine{}, false
+}
+
+// parseNode parses the block node starting on the current line
+func (p *yamlParser) parseNode(indent int) (any, error) {
+	line, _ := p.next()
+	if strings.HasPrefix(line.text, "\t") {
+		return nil, p.errorf("tabs are not allowed in indentation")
+	}
+	if isSequenceItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return p.parseScalar(line.text, indent-1)
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	m := map[string]any{yamlLineKey: p.pos + 1}
+	for {
+		line, ok := p.next()
+		if !ok || line.indent < indent {
+			return m, nil
+		}
+		if line.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf("expected 'key: value', found '%s'", line.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key '%s'", key)
+		}
+		p.pos++
+		value, err := p.parseValue(rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	list := []any{}
+	for {
+		line, ok := p.next()
+		if !ok || line.indent != indent || !isSequenceItem(line.text) {
+			if ok && line.indent > indent {
+				return nil, p.errorf("unexpected indentation")
+			}
+			return list, nil
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		column := indent + len(line.text) - len(rest)
+		var item any
+		var err error
+		switch _, _, isKey := splitYAMLKey(rest); {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			p.pos++
+			item, err = p.parseValue("", indent, false)
+		case isKey || isSequenceItem(rest):
+			// "- key: value" starts a mapping whose keys line up with "key"
+			p.lines[p.pos] = strings.Repeat(" ", column) + rest
+			item, err = p.parseNode(column)
+		default:
+			p.pos++
+			item, err = p.parseValue(rest, indent, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+}
+
+// parseValue parses what follows "key:" or "- ". Nested blocks must be
+// indented deeper than parent, except a sequence under a mapping key, which
+// YAML allows at the key's own indentation.
+func (p *yamlParser) parseValue(rest string, parent int, inMapping bool) (any, error) {
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return p.parseScalarOrFlow(rest, parent)
+	}
+	line, ok := p.next()
+	switch {
+	case ok && line.indent > parent:
+		return p.parseNode(line.indent)
+	case ok && inMapping && line.indent == parent && isSequenceItem(line.text):
+		return p.parseSequence(parent)
+	}
+	return "", nil
+}
+
+func (p *yamlParser) parseScalarOrFlow(rest string, parent int) (any, error) {
+	start := p.pos
+	switch rest[0] {
+	case '[', '{':
+		text := stripYAMLComment(rest)
+		for !flowBalanced(text) {
+			if p.pos >= len(p.lines) {
+				return nil, p.errorAt(start, "unterminated flow collection")
+			}
+			text += " " + stripYAMLComment(strings.TrimSpace(p.lines[p.pos]))
+			p.pos++
+		}
+		f := &yamlFlow{src: text}
+		value, err := f.parse()
+		if err == nil {
+			if f.skipSpaces(); f.pos < len(f.src) {
+				err = fmt.Errorf("unexpected '%s' after flow collection", f.src[f.pos:])
+			}
+		}
+		if err != nil {
+			return nil, p.errorAt(start, "%v", err)
+		}
+		return value, nil
+	case '|', '>':
+		return p.parseBlockScalar(rest, parent)
+	case '&', '*', '!':
+		return nil, p.errorAt(start, "anchors, aliases and tags are not supported")
+	}
+	return p.parseScalar(rest, parent)
+}
+
+// parseScalar parses a plain or quoted scalar, continued on the following
+// lines indented deeper than parent
+func (p *yamlParser) parseScalar(text string, parent int) (string, error) {
+	start := p.pos
+	if text[0] == '"' || text[0] == '\'' {
+		for {
+			value, end, err := parseYAMLQuoted(text)
+			if err == nil {
+				if tail := strings.TrimSpace(text[end:]); tail != "" && !strings.HasPrefix(tail, "#") {
+					return "", p.errorAt(start, "unexpected '%s' after quoted string", tail)
+				}
+				return value, nil
+			}
+			if p.pos >= len(p.lines) || err != errYAMLUnterminated {
+				return "", p.errorAt(start, "%v", err)
+			}
+			next := strings.TrimSpace(p.lines[p.pos])
+			p.pos++
+			if next == "" {
+				text += "\n" // A blank line folds to a line break
+			} else if strings.HasSuffix(text, "\n") {
+				text += next
+			} else {
+				text += " " + next
+			}
+		}
+	}
+
+	value := stripYAMLComment(text)
+	for {
+		line, ok := p.next()
+		if !ok || line.indent <= parent {
+			break
+		}
+		value += " " + stripYAMLComment(line.text)
+		p.pos++
+	}
+	if value == "~" || value == "null" {
+		return "", nil
+	}
+	return value, nil
+}
+
+// parseBlockScalar parses a | (literal) or > (folded) scalar with an
+// optional - or + chomping indicator
+func (p *yamlParser) parseBlockScalar(header string, parent int) (string, error) {
+	start := p.pos
+	header = stripYAMLComment(header)
+	folded := header[0] == '>'
+	chomp := byte(0)
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+		default:
+			return "", p.errorAt(start, "invalid block scalar header '%s'", header)
+		}
+	}
+
+	var lines []string
+	indent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := strings.TrimRight(p.lines[p.pos], " \t")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lineIndent := len(raw) - len(text)
+		if lineIndent <= parent || (indent >= 0 && lineIndent < indent) {
+			break
+		}
+		if indent < 0 {
+			indent = lineIndent
+		}
+		lines = append(lines, raw[indent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	// Folding joins lines with a space. A line break before blank lines is
+	// dropped and each blank line kept as one, except around more-indented
+	// lines, whose breaks are all kept.
+	var sb strings.Builder
+	lastText := ""
+	for i, line := range lines {
+		moreIndented := strings.HasPrefix(line, " ") || strings.HasPrefix(lastText, " ")
+		switch {
+		case i == 0:
+		case !folded || line == "":
+			sb.WriteByte('\n')
+		case lines[i-1] == "":
+			if moreIndented {
+				sb.WriteByte('\n')
+			}
+		case moreIndented:
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(' ')
+		}
+		if line != "" {
+			lastText = line
+		}
+		sb.WriteString(line)
+	}
+	value := sb.String()
+	switch {
+	case len(lines) == 0 || chomp == '-':
+	case chomp == '+':
+		value += strings.Repeat("\n", trailing+1)
+	default:
+		value += "\n"
+	}
+	return value, nil
+}
+
+// splitYAMLKey splits "key: rest" or "key:", with a plain or quoted key
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" || strings.ContainsRune("[{&*!|>%@`#", rune(text[0])) || isSequenceItem(text) {
+		return "", "", false
+	}
+	end := -1
+	if text[0] == '"' || text[0] == '\'' {
+		var err error
+		key, end, err = parseYAMLQuoted(text)
+		if err != nil || !strings.HasPrefix(text[end:], ":") {
+			return "", "", false
+		}
+	} else {
+		for i := 0; i < len(text); i++ {
+			if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+				end = i
+				break
+			}
+			if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+				return "", "", false
+			}
+		}
+		if end < 0 {
+			return "", "", false
+		}
+		key = strings.TrimSpace(text[:end])
+	}
+	return key, strings.TrimSpace(text[end+1:]), true
+}
+
+// stripYAMLComment removes a " #" comment from a plain scalar
+func stripYAMLComment(text string) string {
+	if strings.HasPrefix(text, "#") {
+		return ""
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	if i := strings.Index(text, "\t#"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+var errYAMLUnterminated = fmt.Errorf("unterminated quoted string")
+
+// parseYAMLQuoted decodes the quoted string at the start of text and
+// returns the index just past its closing quote
+func parseYAMLQuoted(text string) (string, int, error) {
+	quote := text[0]
+	var sb strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			sb.WriteByte('\'')
+			i++
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && quote == '"':
+			if i+1 >= len(text) {
+				return "", 0, errYAMLUnterminated
+			}
+			i++
+			n, err := yamlEscape(text[i:], &sb)
+			if err != nil {
+				return "", 0, err
+			}
+			i += n - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, errYAMLUnterminated
+}
+
+// yamlEscape decodes the escape sequence after a backslash and returns its
+// length
+func yamlEscape(s string, sb *strings.Builder) (int, error) {
+	simple := map[byte]string{'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+		'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': " "}
+	if r, ok := simple[s[0]]; ok {
+		sb.WriteString(r)
+		return 1, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) < 1+size {
+		return 0, fmt.Errorf("invalid escape '\\%c'", s[0])
+	}
+	code, err := strconv.ParseUint(s[1:1+size], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, fmt.Errorf("invalid escape '\\%s'", s[:1+size])
+	}
+	sb.WriteRune(rune(code))
+	return 1 + size, nil
+}
+
+// flowBalanced reports whether every [ and { outside quotes is closed
+func flowBalanced(text string) bool {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			_, end, err := parseYAMLQuoted(text[i:])
+			if err != nil {
+				return false
+			}
+			i += end - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// yamlFlow parses a flow collection: [a, 'b', {c: d}]
+type yamlFlow struct {
+	src string
+	pos int
+}
+
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.src) && (f.src[f.pos] == ' ' || f.src[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (any, error) {
+	f.skipSpaces()
+	if f.pos >= len(f.src) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.src[f.pos] {
+	case '[':
+		f.pos++
+		list := []any{}
+		for {
+			if f.skipSpaces(); f.pos < len(f.src) && f.src[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			item, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]any{}
+		for {
+			if f.skipSpaces(); f.pos < len(f.src) && f.src[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			key, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			keyText, ok := key.(string)
+			if f.skipSpaces(); !ok || f.pos >= len(f.src) || f.src[f.pos] != ':' {
+				return nil, fmt.Errorf("expected 'key: value' in flow mapping")
+			}
+			f.pos++
+			value, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			m[keyText] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		value, end, err := parseYAMLQuoted(f.src[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += end
+		return value, nil
+	}
+	start := f.pos
+	for f.pos < len(f.src) && !strings.ContainsRune(",]}", rune(f.src[f.pos])) &&
+		!(f.src[f.pos] == ':' && (f.pos+1 == len(f.src) || f.src[f.pos+1] == ' ')) {
+		f.pos++
+	}
+	return strings.TrimSpace(f.src[start:f.pos]), nil
+}
+
+// separator consumes the ',' after an item, or stops before the closing
+// bracket
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpaces()
+	switch {
+	case f.pos < len(f.src) && f.src[f.pos] == ',':
+		f.pos++
+		return nil
+	case f.pos < len(f.src) && f.src[f.pos] == closing:
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c' in flow collection", closing)
+}
+
+// ==============================================
+// YAML VALUE HELPERS
+// ==============================================
+
+func yamlString(m map[string]any, key string) (string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("'%s' must be a string", key)
+	}
+}
+
+// yamlStringList reads a list of strings; a single string is split on
+// commas, as nuclei writes tags
+func yamlStringList(m map[string]any, key string) ([]string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return SplitList(v), nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must be a list of strings", key)
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a list of strings", key)
+	}
+}
+
+func yamlMaps(m map[string]any, key string) ([]map[string]any, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		maps := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			table, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must be a list of mappings", key)
+			}
+			maps = append(maps, table)
+		}
+		return maps, nil
+	default:
+		return nil, fmt.Errorf("'%s' must be a list of mappings", key)
+	}
+}
+
+func yamlMapLine(m map[string]any) int {
+	line, _ := m[yamlLineKey].(int)
+	return line
+}
@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withoutLines drops the line entries parseYAML adds to mappings and
+// parseTOML to tables (yamlLineKey and tomlLineKey)
+func withoutLines(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			if key != yamlLineKey && key != tomlLineKey {
+				m[key] = withoutLines(value)
+			}
+		}
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = withoutLines(item)
+		}
+		return list
+	case []map[string]any:
+		list := make([]map[string]any, len(v))
+		for i, item := range v {
+			list[i] = withoutLines(item).(map[string]any)
+		}
+		return list
+	}
+	return v
+}
+
+func TestParseYAML(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want any
+	}{
+		{"a: plain # comment\nb: x#y", map[string]any{"a": "plain", "b": "x#y"}},
+		{"a: 'it''s'\nb: \"tab\\tq\\u00e9\"", map[string]any{"a": "it's", "b": "tab\tqé"}},
+		{"a: 'quoted\n  over lines'", map[string]any{"a": "quoted over lines"}},
+		{"a: plain\n  over lines\nb: ~", map[string]any{"a": "plain over lines", "b": ""}},
+		{"a: >-\n  folded\n  text\n\n  para\nb: x", map[string]any{"a": "folded text\npara", "b": "x"}},
+		{"a: >\n  a\n\n    more\n  c\n", map[string]any{"a": "a\n\n  more\nc\n"}},
+		{"a: |\n  l1\n   l2\n\n", map[string]any{"a": "l1\n l2\n"}},
+		{"a: |-\n  l1\n  l2\n", map[string]any{"a": "l1\nl2"}},
+		{"a: |+\n  l1\n\n", map[string]any{"a": "l1\n\n"}},
+		{"a: [x, 'y, z', \"w\"]", map[string]any{"a": []any{"x", "y, z", "w"}}},
+		{"a: [x, # spread\n  y]", map[string]any{"a": []any{"x", "y"}}},
+		{"a: {b: c, d: [e, f]}", map[string]any{"a": map[string]any{"b": "c", "d": []any{"e", "f"}}}},
+		{"a:\n  - x\n  - k: v\n    j: w\n", map[string]any{"a": []any{"x", map[string]any{"k": "v", "j": "w"}}}},
+		{"a:\n- x\n- y\nb: z", map[string]any{"a": []any{"x", "y"}, "b": "z"}},
+		{"---\n- a\n- - b\n  - c", []any{"a", []any{"b", "c"}}},
+		{"# only a comment\n", map[string]any{}},
+	} {
+		got, err := parseYAML(tc.src)
+		if err != nil {
+			t.Errorf("%q: %v", tc.src, err)
+			continue
+		}
+		if got = withoutLines(got); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\ngot  %#v\nwant %#v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"a: 'unterminated":    "line 1: unterminated quoted string",
+		"b: 1\na: [x, y":      "line 2: unterminated flow collection",
+		"a: {b: c":            "line 1: unterminated flow collection",
+		"a: \"bad \\q\"":      "line 1: invalid escape",
+		"a: |x\n  b":          "line 1: invalid block scalar header",
+		"a: 1\na: 2":          "line 2: duplicate key 'a'",
+		"a:\n\t- x":           "line 2: expected 'key: value'",
+		"a: &anchor 1":        "line 1: anchors, aliases and tags are not supported",
+		"a: 'x' y":            "line 1: unexpected 'y' after quoted string",
+		"a: 1\n---\nb: 2":     "line 2: ",
+		"a:\n  b: 1\n c: 2\n": "line 3: unexpected indentation",
+	} {
+		_, err := parseYAML(src)
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: error %v, want %s...", src, err, want)
+		}
+	}
+}
+
+func TestParseYAMLLines(t *testing.T) {
+	doc, err := parseYAML("# header\nid: x\nitems:\n  - name: a\n\n  - name: b\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.(map[string]any)
+	items, _ := yamlMaps(m, "items")
+	if yamlMapLine(m) != 2 || len(items) != 2 || yamlMapLine(items[0]) != 4 || yamlMapLine(items[1]) != 6 {
+		t.Errorf("lines %d, %v", yamlMapLine(m), items)
+	}
+}
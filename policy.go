@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// FAIL POLICY (--fail-on, --fail-on-count)
+// ==============================================
+// By default any finding makes the run exit with exitFindings. A policy
+// narrows that down for CI gates: the run fails only if at least
+// --fail-on-count findings match one of the --fail-on values. The
+// comma-separated conditions of one value must all hold, e.g.
+//
+//   --fail-on "severity>=high"
+//   --fail-on "category=secrets,confidence=high" --fail-on-count 3
+//   --fail-on "severity=critical" --fail-on "rule=aws-access-key-id"
+
+type failPolicy struct {
+	exprs    []string
+	terms    [][]policyCondition // Any of all of; none means every finding counts
+	minCount int
+}
+
+// failOnFlags collects repeatable --fail-on values
+type failOnFlags []string
+
+func (f *failOnFlags) String() string { return strings.Join(*f, " or ") }
+
+func (f *failOnFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type policyCondition struct {
+	field string // severity, confidence, category, tag or rule
+	op    string // =, !=, >=, >, <=, <
+	value string
+	level int // Parsed severity/confidence for ordered comparisons
+}
+
+var policyOperators = []string{">=", "<=", "!=", "==", "=", ">", "<"} // Longest first
+
+func parseFailPolicy(exprs []string, minCount int) (*failPolicy, error) {
+	if len(exprs) == 0 && minCount == 0 {
+		return nil, nil
+	}
+	if minCount < 0 {
+		return nil, fmt.Errorf("--fail-on-count must be positive")
+	}
+	if minCount == 0 {
+		minCount = 1
+	}
+	policy := &failPolicy{exprs: exprs, minCount: minCount}
+	for _, expr := range exprs {
+		var conditions []policyCondition
+		for _, term := range scanner.SplitList(expr) {
+			condition, err := parsePolicyCondition(term)
+			if err != nil {
+				return nil, fmt.Errorf("--fail-on '%s': %w", term, err)
+			}
+			conditions = append(conditions, condition)
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("--fail-on '%s': no conditions", expr)
+		}
+		policy.terms = append(policy.terms, conditions)
+	}
+	return policy, nil
+}
+
+func parsePolicyCondition(term string) (policyCondition, error) {
+	var c policyCondition
+	for _, op := range policyOperators {
+		if field, value, found := strings.Cut(term, op); found {
+			c.field = strings.ToLower(strings.TrimSpace(field))
+			c.op = strings.TrimPrefix(op, "=") // "==" is the same as "="
+			if c.op == "" {
+				c.op = "="
+			}
+			c.value = strings.TrimSpace(value)
+			break
+		}
+	}
+	if c.op == "" {
+		return c, fmt.Errorf("expected <field><operator><value>, e.g. severity>=high")
+	}
+
+	var err error
+	switch c.field {
+	case "severity":
+		var severity scanner.Severity
+		severity, err = scanner.ParseSeverity(c.value)
+		c.level = int(severity)
+	case "confidence":
+		var confidence scanner.Confidence
+		confidence, err = scanner.ParseConfidence(c.value)
+		c.level = int(confidence)
+	case "category", "tag", "rule":
+		if c.op != "=" && c.op != "!=" {
+			err = fmt.Errorf("'%s' only supports = and !=", c.field)
+		}
+	default:
+		err = fmt.Errorf("unknown field '%s' (use severity, confidence, category, tag or rule)", c.field)
+	}
+	return c, err
+}
+
+// matches reports whether a finding of rule counts against the policy: it
+// meets every condition of any --fail-on value
+func (p *failPolicy) matches(rule *scanner.Rule) bool {
+	if len(p.terms) == 0 {
+		return true
+	}
+	for _, conditions := range p.terms {
+		if matchesAll(conditions, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(conditions []policyCondition, rule *scanner.Rule) bool {
+	for _, c := range conditions {
+		if !c.matches(rule) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c policyCondition) matches(rule *scanner.Rule) bool {
+	switch c.field {
+	case "severity":
+		return compareLevel(int(rule.Severity), c.op, c.level)
+	case "confidence":
+		return compareLevel(int(rule.Confidence), c.op, c.level)
+	case "category":
+		return strings.EqualFold(rule.Category, c.value) == (c.op == "=")
+	case "tag":
+		return containsFold(rule.Tags, c.value) == (c.op == "=")
+	case "rule":
+		return strings.EqualFold(rule.ID, c.value) == (c.op == "=")
+	}
+	return false
+}
+
+func compareLevel(have int, op string, want int) bool {
+	switch op {
+	case "=":
+		return have == want
+	case "!=":
+		return have != want
+	case ">=":
+		return have >= want
+	case ">":
+		return have > want
+	case "<=":
+		return have <= want
+	case "<":
+		return have < want
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies the policy in --resume state ("" if nil)
+func (p *failPolicy) key() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s;%d", strings.Join(p.exprs, "|"), p.minCount)
+}
+
+func (p *failPolicy) String() string {
+	if len(p.exprs) == 0 {
+		return fmt.Sprintf("'any finding' (threshold %d)", p.minCount)
+	}
+	return fmt.Sprintf("'%s' (threshold %d)", strings.Join(p.exprs, "' or '"), p.minCount)
+}
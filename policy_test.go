@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+func TestParsePolicyCondition(t *testing.T) {
+	for term, want := range map[string]policyCondition{
+		"severity>=high":     {field: "severity", op: ">=", value: "high", level: int(scanner.SeverityHigh)},
+		" Severity == low ":  {field: "severity", op: "=", value: "low", level: int(scanner.SeverityLow)},
+		"confidence<medium":  {field: "confidence", op: "<", value: "medium", level: int(scanner.ConfidenceMedium)},
+		"category!=secrets":  {field: "category", op: "!=", value: "secrets"},
+		"tag=aws":            {field: "tag", op: "=", value: "aws"},
+		"rule=stripe-secret": {field: "rule", op: "=", value: "stripe-secret"},
+	} {
+		got, err := parsePolicyCondition(term)
+		if err != nil || got != want {
+			t.Errorf("%q: %+v, %v; want %+v", term, got, err, want)
+		}
+	}
+
+	for term, want := range map[string]string{
+		"severity":          "expected <field><operator><value>",
+		"severity>=extreme": "unknown severity 'extreme'",
+		"confidence=sure":   "unknown confidence 'sure'",
+		"category>=secrets": "'category' only supports = and !=",
+		"owner=me":          "unknown field 'owner'",
+	} {
+		if _, err := parsePolicyCondition(term); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: error %v, want %s...", term, err, want)
+		}
+	}
+}
+
+func TestParseFailPolicy(t *testing.T) {
+	if policy, err := parseFailPolicy(nil, 0); policy != nil || err != nil {
+		t.Errorf("no flags: %v, %v; want no policy", policy, err)
+	}
+	policy, err := parseFailPolicy(nil, 2)
+	if err != nil || policy.minCount != 2 || !policy.matches(&scanner.Rule{}) {
+		t.Errorf("--fail-on-count alone: %+v, %v; want every finding to count", policy, err)
+	}
+	if policy.String() != "'any finding' (threshold 2)" {
+		t.Errorf("String() = %s", policy)
+	}
+
+	for _, tc := range []struct {
+		exprs []string
+		count int
+		want  string
+	}{
+		{nil, -1, "--fail-on-count must be positive"},
+		{[]string{"severity>=high,bogus"}, 0, "--fail-on 'bogus': expected"},
+		{[]string{"severity>=high", " , "}, 0, "--fail-on ' , ': no conditions"},
+	} {
+		if _, err := parseFailPolicy(tc.exprs, tc.count); err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%q: error %v, want %s...", tc.exprs, err, tc.want)
+		}
+	}
+}
+
+func TestFailPolicyMatches(t *testing.T) {
+	rules := map[string]*scanner.Rule{
+		"key":   {ID: "key", Category: "secrets", Severity: scanner.SeverityHigh, Confidence: scanner.ConfidenceHigh},
+		"guess": {ID: "guess", Category: "secrets", Severity: scanner.SeverityHigh, Confidence: scanner.ConfidenceLow},
+		"admin": {ID: "admin", Category: "Endpoints", Severity: scanner.SeverityCritical, Confidence: scanner.ConfidenceHigh, Tags: []string{"Admin"}},
+		"debug": {ID: "debug", Category: "endpoints", Severity: scanner.SeverityLow, Confidence: scanner.ConfidenceMedium},
+	}
+	for _, tc := range []struct {
+		exprs []string
+		want  string
+	}{
+		{[]string{"severity>=high"}, "admin guess key"},
+		{[]string{"category=secrets,confidence=high"}, "key"},                    // All of one value
+		{[]string{"category=secrets,confidence=high", "tag=admin"}, "admin key"}, // Any of the values
+		{[]string{"category=endpoints,severity<high"}, "debug"},
+		{[]string{"rule!=key,category=SECRETS"}, "guess"},
+	} {
+		policy, err := parseFailPolicy(tc.exprs, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, id := range []string{"admin", "debug", "guess", "key"} {
+			if policy.matches(rules[id]) {
+				got = append(got, id)
+			}
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%q: matched %v, want %s", tc.exprs, got, tc.want)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	patterns := write("rules.toml", `
+[[rule]]
+id = "key"
+regex = '''key_[0-9]{6}'''
+category = "secrets"
+severity = "high"
+confidence = "high"
+
+[[rule]]
+id = "admin"
+regex = '''/admin\b'''
+category = "endpoints"
+severity = "low"
+`)
+	clean := write("clean.txt", "https://a.com/\nhttps://b.com/login\n")
+	found := write("found.txt", "https://a.com/?k=key_123456\nhttps://b.com/admin\nhttps://c.com/admin\n")
+
+	for _, tc := range []struct {
+		name     string
+		patterns string
+		urls     string
+		failOn   []string
+		count    int
+		want     int
+	}{
+		{"no findings", patterns, clean, nil, 0, exitOK},
+		{"findings", patterns, found, nil, 0, exitFindings},
+		{"findings below the policy", patterns, found, []string{"category=secrets,confidence=high"}, 2, exitOK},
+		{"policy failed", patterns, found, []string{"category=secrets,confidence=high"}, 1, exitFindings},
+		{"either policy value", patterns, found, []string{"category=secrets", "rule=admin"}, 3, exitFindings},
+		{"missing patterns", filepath.Join(dir, "missing.toml"), found, nil, 0, exitConfigError},
+		{"missing input", patterns, filepath.Join(dir, "missing.txt"), nil, 0, exitConfigError},
+		{"unreadable input", patterns, dir, nil, 0, exitRuntimeError}, // A directory opens, then fails to read
+	} {
+		config := Config{PatternsFile: tc.patterns, UrlsFile: tc.urls, Threads: 2}
+		var err error
+		if config.Policy, err = parseFailPolicy(tc.failOn, tc.count); err != nil {
+			t.Fatal(err)
+		}
+		if got := runScan(config); got != tc.want {
+			t.Errorf("%s: exit %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Acorzo1983/Codehunter/pkg/scanner"
+)
+
+// ==============================================
+// RULE PROFILE REPORT (--profile-rules)
+// ==============================================
+// The report ranks the rules by regex time (cost) and by the share of URLs
+// they hit (noise), and lists the rules that never matched. It covers the
+// URLs scanned by this run: a resumed scan starts its counters from zero.
+
+// profileTop is the length of the ranked tables of the text report
+const profileTop = 25
+
+type ruleProfileEntry struct {
+	RuleID      string  `json:"rule_id"`
+	SourceFile  string  `json:"source_file"`
+	Line        int     `json:"line"`
+	Pattern     string  `json:"pattern"`
+	Severity    string  `json:"severity"`
+	Evaluations int64   `json:"evaluations"`
+	Hits        int64   `json:"hits"`
+	URLs        int64   `json:"urls"`
+	URLRate     float64 `json:"url_rate"` // Share of the scanned URLs hit
+	TimeNanos   int64   `json:"time_ns"`
+	NanosPerRun int64   `json:"ns_per_evaluation"`
+	TimeShare   float64 `json:"time_share"` // Share of the regex time of all rules
+}
+
+type ruleProfileReport struct {
+	URLs      int                `json:"urls"`
+	Elapsed   float64            `json:"elapsed_seconds"`
+	RegexTime float64            `json:"regex_seconds"` // Summed over workers
+	Rules     []ruleProfileEntry `json:"rules"`         // By time, most expensive first
+}
+
+func newRuleProfileReport(profiles []scanner.RuleProfile, urls int, elapsed time.Duration) ruleProfileReport {
+	report := ruleProfileReport{URLs: urls, Elapsed: elapsed.Seconds()}
+	var total time.Duration
+	for _, p := range profiles {
+		total += p.Time
+	}
+	report.RegexTime = total.Seconds()
+	for _, p := range profiles {
+		entry := ruleProfileEntry{
+			RuleID:      p.Rule.ID,
+			SourceFile:  p.Rule.SourceFile,
+			Line:        p.Rule.Line,
+			Pattern:     p.Rule.Regex,
+			Severity:    p.Rule.Severity.String(),
+			Evaluations: p.Evaluations,
+			Hits:        p.Hits,
+			URLs:        p.URLs,
+			TimeNanos:   int64(p.Time),
+		}
+		if urls > 0 {
+			entry.URLRate = float64(p.URLs) / float64(urls)
+		}
+		if p.Evaluations > 0 {
+			entry.NanosPerRun = int64(p.Time) / p.Evaluations
+		}
+		if total > 0 {
+			entry.TimeShare = float64(p.Time) / float64(total)
+		}
+		report.Rules = append(report.Rules, entry)
+	}
+	sort.SliceStable(report.Rules, func(i, j int) bool { return report.Rules[i].TimeNanos > report.Rules[j].TimeNanos })
+	return report
+}
+
+// encodeRuleProfile writes the report as JSON if path ends in .json, as
+// text tables otherwise
+func encodeRuleProfile(w io.Writer, path string, report ruleProfileReport) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "CodeHunter rule profile: %d rules, %d URLs, %s elapsed, %s in regexes (summed over workers)\n",
+		len(report.Rules), report.URLs, time.Duration(report.Elapsed*float64(time.Second)).Round(time.Millisecond),
+		time.Duration(report.RegexTime*float64(time.Second)).Round(time.Microsecond))
+
+	fmt.Fprintf(out, "\nMost expensive rules (regex and scoring time)\n")
+	writeProfileTable(out, report.Rules)
+
+	noisy := append([]ruleProfileEntry(nil), report.Rules...)
+	sort.SliceStable(noisy, func(i, j int) bool {
+		if noisy[i].URLs != noisy[j].URLs {
+			return noisy[i].URLs > noisy[j].URLs
+		}
+		return noisy[i].Hits > noisy[j].Hits
+	})
+	for len(noisy) > 0 && noisy[len(noisy)-1].URLs == 0 {
+		noisy = noisy[:len(noisy)-1]
+	}
+	fmt.Fprintf(out, "\nNoisiest rules (share of URLs hit)\n")
+	writeProfileTable(out, noisy)
+
+	var silent []string
+	for _, e := range report.Rules {
+		if e.URLs == 0 {
+			silent = append(silent, e.name())
+		}
+	}
+	sort.Strings(silent)
+	fmt.Fprintf(out, "\nRules that matched no URL: %d of %d\n", len(silent), len(report.Rules))
+	for _, rule := range silent {
+		fmt.Fprintf(out, "  %s\n", rule)
+	}
+	return out.Flush()
+}
+
+// name is the rule ID, with its file and line unless the ID already is
+// "<file>:<line>"
+func (e ruleProfileEntry) name() string {
+	if strings.HasSuffix(e.RuleID, fmt.Sprintf(":%d", e.Line)) {
+		return e.RuleID
+	}
+	return fmt.Sprintf("%s (%s:%d)", e.RuleID, e.SourceFile, e.Line)
+}
+
+func writeProfileTable(out io.Writer, entries []ruleProfileEntry) {
+	if len(entries) > profileTop {
+		entries = entries[:profileTop]
+	}
+	fmt.Fprintf(out, "  %-4s %10s %6s %11s %9s %9s %9s %7s  %s\n", "#", "TIME", "SHARE", "EVALS", "NS/EVAL", "HITS", "URLS", "URL%", "RULE")
+	for i, e := range entries {
+		pattern := e.Pattern
+		if len(pattern) > 50 {
+			pattern = pattern[:47] + "..."
+		}
+		fmt.Fprintf(out, "  %-4d %10s %5.1f%% %11d %9d %9d %9d %6.2f%%  %s %s\n",
+			i+1, time.Duration(e.TimeNanos).Round(time.Microsecond), e.TimeShare*100, e.Evaluations, e.NanosPerRun,
+			e.Hits, e.URLs, e.URLRate*100, e.name(), pattern)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(out, "  (none)\n")
+	}
+}
+
+// writeRuleProfile writes the --profile-rules report, also after an
+// interrupt: the counters then cover the URLs scanned so far
+func (s *Runner) writeRuleProfile() {
+	if s.profileFile == nil {
+		return
+	}
+	report := newRuleProfileReport(s.scanner.RuleProfiles(), s.Stats.URLsProcessed-s.Stats.Resumed, s.Stats.EndTime.Sub(s.Stats.StartTime))
+	err := encodeRuleProfile(s.profileFile, s.Config.ProfileFile, report)
+	if closeErr := s.profileFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.profileFile = nil
+		s.Stats.Errors++
+		s.logGeneralMessage(fmt.Sprintf("%s[ERROR]%s Writing rule profile '%s': %v\n", ColorRed, ColorReset, s.Config.ProfileFile, err), true)
+	}
+}